@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"strings"
+
+	"src/tools.app/utils/logging"
 )
 
 const configFile = "/mock_conf.json"
@@ -13,18 +16,208 @@ type Configs struct {
 	Meta   string        `json:"meta"`
 	RunEnv string        `json:"run_env"`
 	Server ServerConfigs `json:"server"`
+	Tools  ToolsConfigs  `json:"tools"`
+	Auth   AuthConfigs   `json:"auth"`
+	Cors   CorsConfigs   `json:"cors"`
+	Mirror MirrorConfigs `json:"mirror"`
+	Chaos  ChaosConfigs  `json:"chaos"`
+	// Log logging configs, and it's replaced by config file of -log-config flag or LOG_CONFIG env.
+	Log logging.Config `json:"log"`
+	// RateLimits rate limit and quota rules, and all rules which path matched are checked.
+	RateLimits []RateLimitRule `json:"rate_limits"`
 }
 
 // ServerConfigs server configs.
 type ServerConfigs struct {
 	RedisURI string `json:"redis_uri"`
+	// Counter backend of access counters: memory, file or redis.
+	Counter     string `json:"counter"`
+	CounterFile string `json:"counter_file"`
+	// StaticDir dir of dashboard static files.
+	StaticDir string `json:"static_dir"`
+	// JournalSize max requests are kept in request journal.
+	JournalSize int `json:"journal_size"`
+	// JournalRedactHeaders values of these request and response headers are redacted in request journal.
+	JournalRedactHeaders []string `json:"journal_redact_headers"`
+	// JournalRedactFields values of these form and json fields in request and response bodies are redacted in request journal.
+	JournalRedactFields []string `json:"journal_redact_fields"`
+	// ShutdownTimeout seconds to drain in-flight requests on SIGINT or SIGTERM.
+	ShutdownTimeout int `json:"shutdown_timeout"`
+	// StateFile in-memory state (scenarios, journal and memory counters) is saved to on shutdown,
+	// and loaded from on start. State is not persisted if empty.
+	StateFile string `json:"state_file"`
+}
+
+// ToolsConfigs tools apis configs. Tools apis are rejected when neither auth token nor hmac secret is set.
+type ToolsConfigs struct {
+	AuthToken     string        `json:"auth_token"`
+	HMACSecret    string        `json:"hmac_secret"`
+	Commands      []CmdTemplate `json:"commands"`
+	MailReceivers []string      `json:"mail_receivers"`
+	AuditLog      string        `json:"audit_log"`
+	MaxJobs       int           `json:"max_jobs"`
+	// MaxBodySize max bytes of tools request body, and default 1MB if 0.
+	MaxBodySize int64 `json:"max_body_size"`
+}
+
+// CmdTemplate an allowed command template for tools cmd api.
+type CmdTemplate struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	// Args maps template arg name to the regexp which arg value must fully match.
+	Args      map[string]string `json:"args"`
+	Timeout   int               `json:"timeout"`
+	MaxOutput int               `json:"max_output"`
+}
+
+// AuthConfigs simulated auth providers configs.
+type AuthConfigs struct {
+	Issuer string `json:"issuer"`
+	// PrivateKey rsa private key pem file to sign jwt, and a key is generated if empty.
+	PrivateKey string `json:"private_key"`
+	// TokenExpiry expiry of tokens and sessions in seconds.
+	TokenExpiry int                    `json:"token_expiry"`
+	Claims      map[string]interface{} `json:"claims"`
+	// Clients maps client id to secret.
+	Clients map[string]string `json:"clients"`
+	// Users maps user name to password.
+	Users map[string]string `json:"users"`
+	Rules []AuthRule        `json:"rules"`
+}
+
+// AuthRule requires auth for requests which path has prefix.
+type AuthRule struct {
+	Path string `json:"path"`
+	// Type of auth: basic, bearer or session, and it's case-insensitive.
+	Type string `json:"type"`
+	// Users allowed users (basic, session, and "sub" of bearer), and all authenticated users are allowed if empty.
+	Users []string `json:"users"`
+	// Scopes required scopes of bearer token.
+	Scopes []string `json:"scopes"`
+}
+
+// CorsConfigs cors policy applied to all routes.
+type CorsConfigs struct {
+	// Enabled cors policy is enabled, and default is enabled except in prod.
+	Enabled *bool `json:"enabled"`
+	// Origins allowed origins, "*" matches any part of origin, like "https://*.example.com".
+	Origins []string `json:"origins"`
+	Methods []string `json:"methods"`
+	// Headers allowed request headers, and "*" allows all requested headers.
+	Headers        []string `json:"headers"`
+	ExposedHeaders []string `json:"exposed_headers"`
+	// MaxAge seconds of preflight result can be cached.
+	MaxAge      int  `json:"max_age"`
+	Credentials bool `json:"credentials"`
+}
+
+// MirrorConfigs traffic mirror configs, requests to "/mirror/*path" are sent to both upstreams.
+type MirrorConfigs struct {
+	// Primary and Secondary upstream base urls, like "http://127.0.0.1:8080".
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary"`
+	// Timeout of upstream requests in seconds.
+	Timeout int `json:"timeout"`
+	// IgnoreHeaders response headers not compared.
+	IgnoreHeaders []string `json:"ignore_headers"`
+	// IgnoreFields json body fields not compared, like "data.*.updated_at".
+	IgnoreFields []string `json:"ignore_fields"`
+	// MaxReports max diff reports are kept.
+	MaxReports int `json:"max_reports"`
+}
+
+// ChaosConfigs chaos proxy configs, requests to "/chaos/*path" are forwarded to upstream with faults by rules.
+type ChaosConfigs struct {
+	// Upstream http upstream base url, like "http://127.0.0.1:8080".
+	Upstream string `json:"upstream"`
+	// Timeout of upstream requests in seconds.
+	Timeout int `json:"timeout"`
+	// Rules http fault rules, and the first matched rule is applied.
+	Rules []ChaosRule `json:"rules"`
+	// TCPProxies raw tcp proxies with faults.
+	TCPProxies []TCPProxy `json:"tcp_proxies"`
+}
+
+// ChaosFault faults injected into a proxied request or connection.
+type ChaosFault struct {
+	// Probability of faults applied, and faults are always applied if 0.
+	Probability float64 `json:"probability"`
+	// Latency and random Jitter in milliseconds, before response (http) or each read (tcp).
+	Latency int `json:"latency"`
+	Jitter  int `json:"jitter"`
+	// Reset connection is reset before response (http) or after first read (tcp).
+	Reset bool `json:"reset"`
+	// Status http status returned instead of upstream response.
+	Status int `json:"status"`
+	// Truncate connection is closed after bytes of response sent.
+	Truncate int64 `json:"truncate"`
+	// Bandwidth bytes per second of response.
+	Bandwidth int `json:"bandwidth"`
+}
+
+// ChaosRule http fault rule for requests which path has prefix, and any method matches if method is empty.
+type ChaosRule struct {
+	Path   string `json:"path"`
+	Method string `json:"method"`
+	ChaosFault
+}
+
+// TCPProxy a raw tcp proxy listens and forwards connections to upstream with faults.
+type TCPProxy struct {
+	Listen   string `json:"listen"`
+	Upstream string `json:"upstream"`
+	ChaosFault
+}
+
+// RateLimitRule limits requests which path has prefix per client.
+type RateLimitRule struct {
+	Path string `json:"path"`
+	// Key of client: "ip" (default), "header:<name>", "query:<name>" or "cookie:<name>".
+	Key string `json:"key"`
+	// Rate tokens per second, and Burst size of token bucket.
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+	// Quota max requests in each window of seconds.
+	Quota  int `json:"quota"`
+	Window int `json:"window"`
 }
 
 // RunConfigs stores configs of mock server.
 var RunConfigs Configs = Configs{
 	RunEnv: "test", // test, prod
 	Server: ServerConfigs{
-		RedisURI: "http://localhost:6379",
+		RedisURI:    "http://localhost:6379",
+		Counter:     "memory",
+		CounterFile: "mock_counter.json",
+		StaticDir:   "static",
+		JournalSize: 200,
+		JournalRedactHeaders: []string{
+			"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Tools-Token", "X-Tools-Signature",
+		},
+		JournalRedactFields: []string{
+			"client_secret", "password", "pwd", "access_token", "id_token", "refresh_token",
+		},
+		ShutdownTimeout: 10,
+	},
+	Tools: ToolsConfigs{
+		AuditLog: "tools_audit.log",
+	},
+	Auth: AuthConfigs{
+		Issuer:      "http://127.0.0.1:17891",
+		TokenExpiry: 3600,
+	},
+	Mirror: MirrorConfigs{
+		Timeout:       10,
+		IgnoreHeaders: []string{"Date", "Content-Length"},
+		MaxReports:    100,
+	},
+	Chaos: ChaosConfigs{
+		Timeout: 10,
+	},
+	Cors: CorsConfigs{
+		Origins: []string{"*"},
+		Methods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		Headers: []string{"Accept", "Origin", "Content-Type", "Authorization", "X-Custom-Header", "X-Test"},
 	},
 }
 
@@ -38,5 +231,16 @@ func InitConfigs() error {
 	if data == nil || len(data) == 0 {
 		data = []byte("{}")
 	}
-	return json.Unmarshal(data, &RunConfigs)
+	if err := json.Unmarshal(data, &RunConfigs); err != nil {
+		return err
+	}
+	RunConfigs.normalize()
+	return nil
+}
+
+// normalize normalizes loaded configs, and types of auth rules are matched in lower case.
+func (c *Configs) normalize() {
+	for i := range c.Auth.Rules {
+		c.Auth.Rules[i].Type = strings.ToLower(c.Auth.Rules[i].Type)
+	}
 }
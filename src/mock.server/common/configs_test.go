@@ -4,9 +4,27 @@ import (
 	"testing"
 )
 
+// setRunConfigs updates global configs by fn, and restores them when test and its subtests finish.
+func setRunConfigs(t *testing.T, fn func(configs *Configs)) {
+	origin := RunConfigs
+	t.Cleanup(func() {
+		RunConfigs = origin
+	})
+	fn(&RunConfigs)
+}
+
 func TestInitConfigs(t *testing.T) {
 	if err := InitConfigs(); err != nil {
 		t.Fatal(err)
 	}
 	t.Logf("configs: %+v", RunConfigs)
 }
+
+func TestNormalizeConfigs(t *testing.T) {
+	t.Log("Case01: types of auth rules are lower-cased.")
+	cfg := Configs{Auth: AuthConfigs{Rules: []AuthRule{{Path: "/a", Type: "Basic"}, {Path: "/b", Type: "BEARER"}}}}
+	cfg.normalize()
+	if cfg.Auth.Rules[0].Type != "basic" || cfg.Auth.Rules[1].Type != "bearer" {
+		t.Errorf("Unexpected auth rules: %+v", cfg.Auth.Rules)
+	}
+}
@@ -3,6 +3,8 @@ package common
 import (
 	"encoding/base64"
 	"math/rand"
+
+	"src/tools.app/utils/httpresp"
 )
 
 const (
@@ -17,11 +19,11 @@ const (
 	TextContentEncoding = "Content-Encoding"
 
 	// ContentTypeJSON http content type application/json.
-	ContentTypeJSON = "application/json; charset=utf-8"
+	ContentTypeJSON = httpresp.ContentTypeJSON
 	// ContentTypeTEXT http content type text/plain.
-	ContentTypeTEXT = "text/plain; charset=utf-8"
+	ContentTypeTEXT = httpresp.ContentTypeText
 	// ContentTypeHTML http content type text/html.
-	ContentTypeHTML = "text/html; charset=uft-8"
+	ContentTypeHTML = httpresp.ContentTypeHTML
 	// ContentTypeForm http content type form.
 	ContentTypeForm = "application/x-www-form-urlencoded"
 
@@ -0,0 +1,246 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	redis "gopkg.in/redis.v5"
+
+	myutils "src/tools.app/utils"
+)
+
+const (
+	// CounterMemory counter backend stores in memory.
+	CounterMemory = "memory"
+	// CounterFile counter backend stores in a json file.
+	CounterFile = "file"
+	// CounterRedis counter backend stores in redis.
+	CounterRedis = "redis"
+
+	redisCounterKeyPrefix = "mock_counter:"
+)
+
+// redisUnprefixedCounters counters keep redis keys before prefix is added, and counts are not reset.
+var redisUnprefixedCounters = []string{"server_total_access"}
+
+// Counter a store of named access counters.
+type Counter interface {
+	// Incr increases counter by 1, and returns the new value.
+	Incr(key string) (int64, error)
+	// Get returns counter value, and 0 if counter not exist.
+	Get(key string) (int64, error)
+	// Reset removes the counter.
+	Reset(key string) error
+	Close() error
+}
+
+var (
+	sharedCounter      Counter
+	sharedCounterMutex sync.Mutex
+)
+
+// GetCounter returns the shared counter created from run configs. Counter is created again on next call
+// if it's failed (like redis is down), instead of the error is kept until restart.
+func GetCounter() (Counter, error) {
+	sharedCounterMutex.Lock()
+	defer sharedCounterMutex.Unlock()
+	if sharedCounter != nil {
+		return sharedCounter, nil
+	}
+	counter, err := NewCounter(&RunConfigs.Server)
+	if err != nil {
+		return nil, err
+	}
+	sharedCounter = counter
+	return sharedCounter, nil
+}
+
+// NewCounter returns a counter by backend of server configs, default is memory.
+func NewCounter(cfg *ServerConfigs) (Counter, error) {
+	switch cfg.Counter {
+	case "", CounterMemory:
+		return NewMemoryCounter(), nil
+	case CounterFile:
+		return NewFileCounter(cfg.CounterFile)
+	case CounterRedis:
+		return NewRedisCounter(cfg.RedisURI)
+	default:
+		return nil, fmt.Errorf("invalid counter backend: %s", cfg.Counter)
+	}
+}
+
+/* Memory Counter */
+
+type memoryCounter struct {
+	mutex  sync.Mutex
+	counts map[string]int64
+}
+
+// NewMemoryCounter returns a counter stores in memory.
+func NewMemoryCounter() Counter {
+	return &memoryCounter{counts: make(map[string]int64)}
+}
+
+func (c *memoryCounter) Incr(key string) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[key]++
+	return c.counts[key], nil
+}
+
+func (c *memoryCounter) Get(key string) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.counts[key], nil
+}
+
+func (c *memoryCounter) Reset(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.counts, key)
+	return nil
+}
+
+func (c *memoryCounter) Close() error {
+	return nil
+}
+
+// Dump returns a copy of counts.
+func (c *memoryCounter) Dump() map[string]int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	counts := make(map[string]int64, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// Restore replaces counts.
+func (c *memoryCounter) Restore(counts map[string]int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts = make(map[string]int64, len(counts))
+	for k, v := range counts {
+		c.counts[k] = v
+	}
+}
+
+/* File Counter */
+
+type fileCounter struct {
+	memoryCounter
+	path string
+}
+
+// NewFileCounter returns a counter stores in json file, and loads counters from file if exist.
+func NewFileCounter(path string) (Counter, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("counter file path is empty")
+	}
+
+	c := &fileCounter{
+		memoryCounter: memoryCounter{counts: make(map[string]int64)},
+		path:          path,
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &c.counts); err != nil {
+			return nil, fmt.Errorf("invalid counter file (%s): %v", path, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *fileCounter) Incr(key string) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[key]++
+	return c.counts[key], c.save()
+}
+
+func (c *fileCounter) Reset(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.counts, key)
+	return c.save()
+}
+
+// save writes counters to file, and must be called with counter locked.
+func (c *fileCounter) save() error {
+	data, err := json.Marshal(c.counts)
+	if err != nil {
+		return err
+	}
+	return myutils.WriteFileAtomic(c.path, data, 0644)
+}
+
+/* Redis Counter */
+
+type redisCounter struct {
+	client *redis.Client
+}
+
+// NewRedisCounter returns a counter stores in redis, and the client keeps a connection pool.
+func NewRedisCounter(uri string) (Counter, error) {
+	if len(uri) == 0 {
+		return nil, fmt.Errorf("config redis uri is empty")
+	}
+	for _, scheme := range []string{"redis://", "http://"} {
+		uri = strings.TrimPrefix(uri, scheme)
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     uri,
+		Password: "",
+	})
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &redisCounter{client: client}, nil
+}
+
+// redisCounterKey returns redis key of counter.
+func redisCounterKey(key string) string {
+	for _, k := range redisUnprefixedCounters {
+		if key == k {
+			return key
+		}
+	}
+	return redisCounterKeyPrefix + key
+}
+
+func (c *redisCounter) Incr(key string) (int64, error) {
+	return c.client.Incr(redisCounterKey(key)).Result()
+}
+
+func (c *redisCounter) Get(key string) (int64, error) {
+	val, err := c.client.Get(redisCounterKey(key)).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
+
+func (c *redisCounter) Reset(key string) error {
+	return c.client.Del(redisCounterKey(key)).Err()
+}
+
+func (c *redisCounter) Close() error {
+	return c.client.Close()
+}
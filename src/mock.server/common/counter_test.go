@@ -0,0 +1,92 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryCounter(t *testing.T) {
+	t.Log("Case01: test incr, get and reset memory counter.")
+	testCounter(t, NewMemoryCounter())
+}
+
+func TestFileCounter(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "mock_counter_test.json")
+	os.Remove(path)
+	defer os.Remove(path)
+
+	t.Log("Case01: test incr, get and reset file counter.")
+	counter, err := NewFileCounter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCounter(t, counter)
+
+	t.Log("Case02: test file counter loads counters from file.")
+	if _, err := counter.Incr("key2"); err != nil {
+		t.Fatal(err)
+	}
+	counter, err = NewFileCounter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := counter.Get("key2"); val != 1 {
+		t.Error("Unexpected counter value from file:", val)
+	}
+}
+
+func TestNewCounter(t *testing.T) {
+	t.Log("Case01: test new counter with invalid backend.")
+	if _, err := NewCounter(&ServerConfigs{Counter: "invalid"}); err == nil {
+		t.Error("Expected error for invalid counter backend.")
+	}
+}
+
+func TestGetCounterRetry(t *testing.T) {
+	t.Log("Case01: shared counter is created again after failure.")
+	setRunConfigs(t, func(configs *Configs) {
+		configs.Server.Counter = "invalid"
+	})
+	if _, err := GetCounter(); err == nil {
+		t.Fatal("Expected error for invalid counter backend.")
+	}
+	RunConfigs.Server.Counter = CounterMemory
+	counter, err := GetCounter()
+	if err != nil || counter == nil {
+		t.Fatalf("Unexpected shared counter: %v, %v", counter, err)
+	}
+}
+
+func testCounter(t *testing.T, counter Counter) {
+	defer counter.Close()
+	for i := 1; i <= 3; i++ {
+		val, err := counter.Incr("key1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val != int64(i) {
+			t.Errorf("Unexpected incr value: %d, expected: %d", val, i)
+		}
+	}
+
+	if val, err := counter.Get("key1"); err != nil || val != 3 {
+		t.Errorf("Unexpected counter value: %d, %v", val, err)
+	}
+	if err := counter.Reset("key1"); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := counter.Get("key1"); err != nil || val != 0 {
+		t.Errorf("Unexpected counter value after reset: %d, %v", val, err)
+	}
+}
+
+func TestRedisCounterKey(t *testing.T) {
+	t.Log("Case01: redis key of counter is prefixed, and key of /demo/5 counter is not changed.")
+	if key := redisCounterKey("key1"); key != "mock_counter:key1" {
+		t.Error("Unexpected redis key:", key)
+	}
+	if key := redisCounterKey("server_total_access"); key != "server_total_access" {
+		t.Error("Unexpected redis key:", key)
+	}
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/* Http CORS */
+
+// IsEnabled returns whether cors policy is applied.
+func (c *CorsConfigs) IsEnabled() bool {
+	if c.Enabled != nil {
+		return *c.Enabled
+	}
+	return !IsProd()
+}
+
+// matchOrigin returns allowed origin value for response, or empty if origin is not allowed.
+// "*" is returned if any origin is allowed, and credentials are never allowed for it.
+func (c *CorsConfigs) matchOrigin(origin string) string {
+	for _, pattern := range c.Origins {
+		if pattern == "*" {
+			return "*"
+		}
+		if len(origin) > 0 && matchWildcard(pattern, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func matchWildcard(pattern, text string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.EqualFold(pattern, text)
+	}
+	expr := "(?i)^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+	ok, err := regexp.MatchString(expr, text)
+	return err == nil && ok
+}
+
+// AddCorsHeaders writes cors headers by cors policy in configs.
+func AddCorsHeaders(r *http.Request, w http.ResponseWriter) {
+	policy := &RunConfigs.Cors
+	if !policy.IsEnabled() {
+		return
+	}
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	allowOrigin := policy.matchOrigin(r.Header.Get("Origin"))
+	if len(allowOrigin) == 0 {
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	// "*" can not be used with credentials, and any origin is not echoed back with credentials
+	if policy.Credentials && allowOrigin != "*" {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	reqMethod := r.Header.Get("Access-Control-Request-Method")
+	if r.Method != "OPTIONS" || len(reqMethod) == 0 {
+		if len(policy.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ","))
+		}
+		return
+	}
+
+	// preflight request
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if len(policy.Methods) > 0 {
+		header.Set("Access-Control-Allow-Methods", strings.Join(policy.Methods, ","))
+	}
+	if len(policy.Headers) > 0 {
+		allowHeaders := strings.Join(policy.Headers, ",")
+		if allowHeaders == "*" {
+			allowHeaders = r.Header.Get("Access-Control-Request-Headers")
+		}
+		if len(allowHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+	}
+	if policy.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+	}
+}
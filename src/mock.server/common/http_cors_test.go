@@ -0,0 +1,73 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddCorsHeaders(t *testing.T) {
+	setRunConfigs(t, func(configs *Configs) {
+		configs.Cors = CorsConfigs{
+			Origins:        []string{"https://*.example.com", "http://localhost:8080"},
+			Methods:        []string{"GET", "POST"},
+			Headers:        []string{"*"},
+			ExposedHeaders: []string{"X-Request-Id"},
+			MaxAge:         600,
+			Credentials:    true,
+		}
+	})
+
+	t.Log("Case01: simple request from allowed origin pattern.")
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	AddCorsHeaders(req, rr)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rr.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		rr.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Errorf("Unexpected cors headers: %v", rr.Header())
+	}
+
+	t.Log("Case02: preflight request.")
+	req = httptest.NewRequest("OPTIONS", "/test", nil)
+	req.Header.Set("Origin", "http://localhost:8080")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "X-Test,Content-Type")
+	rr = httptest.NewRecorder()
+	AddCorsHeaders(req, rr)
+	if rr.Header().Get("Access-Control-Allow-Methods") != "GET,POST" ||
+		rr.Header().Get("Access-Control-Allow-Headers") != "X-Test,Content-Type" ||
+		rr.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Unexpected cors headers: %v", rr.Header())
+	}
+
+	t.Log("Case03: request from not allowed origin.")
+	req = httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://example.org")
+	rr = httptest.NewRecorder()
+	AddCorsHeaders(req, rr)
+	if len(rr.Header().Get("Access-Control-Allow-Origin")) > 0 {
+		t.Errorf("Unexpected cors headers: %v", rr.Header())
+	}
+
+	t.Log("Case04: credentials are not allowed for any origin.")
+	RunConfigs.Cors.Origins = []string{"*"}
+	req = httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://evil.example.org")
+	rr = httptest.NewRecorder()
+	AddCorsHeaders(req, rr)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "*" || len(rr.Header().Get("Access-Control-Allow-Credentials")) > 0 {
+		t.Errorf("Unexpected cors headers: %v", rr.Header())
+	}
+
+	t.Log("Case05: cors policy disabled.")
+	disabled := false
+	RunConfigs.Cors.Enabled = &disabled
+	req = httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr = httptest.NewRecorder()
+	AddCorsHeaders(req, rr)
+	if len(rr.Header().Get("Access-Control-Allow-Origin")) > 0 {
+		t.Errorf("Unexpected cors headers: %v", rr.Header())
+	}
+}
@@ -0,0 +1,344 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// EncodingGzip content encoding gzip.
+	EncodingGzip = "gzip"
+	// EncodingDeflate content encoding deflate (zlib format).
+	EncodingDeflate = "deflate"
+	// EncodingBrotli content encoding br.
+	EncodingBrotli = "br"
+	// EncodingZstd content encoding zstd.
+	EncodingZstd = "zstd"
+	// EncodingIdentity content encoding identity (no compression).
+	EncodingIdentity = "identity"
+
+	// EncodingModeAuto compresses by request "Accept-Encoding".
+	EncodingModeAuto = "auto"
+	// EncodingModeNone disables compression.
+	EncodingModeNone = "none"
+	// EncodingModeWrong compresses body, but sends another encoding in header "Content-Encoding".
+	EncodingModeWrong = "wrong"
+	// EncodingModeCorrupt sends a corrupt compressed body.
+	EncodingModeCorrupt = "corrupt"
+
+	// EncodingQueryArg query arg of mock api to force response encoding.
+	EncodingQueryArg = "encoding"
+	// EncodingModeQueryArg query arg of mock api to set response encoding mode.
+	EncodingModeQueryArg = "encoding_mode"
+
+	// TextAcceptEncoding http header "Accept-Encoding".
+	TextAcceptEncoding = "Accept-Encoding"
+
+	// MaxDecodedBodySize max size of decompressed request body.
+	MaxDecodedBodySize = 32 << 20
+)
+
+// SupportedEncodings supported content encodings by server preference.
+var SupportedEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+
+// ErrDecodedBodyTooLarge decompressed request body exceeds MaxDecodedBodySize, like a decompression bomb.
+var ErrDecodedBodyTooLarge = fmt.Errorf("decoded request body exceeds max size %d", MaxDecodedBodySize)
+
+// IsSupportedEncoding returns whether content encoding is supported.
+func IsSupportedEncoding(encoding string) bool {
+	for _, enc := range SupportedEncodings {
+		if enc == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEncodingWriter returns a writer which compresses data to w by content encoding.
+func NewEncodingWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzip.NewWriter(w), nil
+	case EncodingDeflate:
+		return zlib.NewWriter(w), nil
+	case EncodingBrotli:
+		return brotli.NewWriter(w), nil
+	case EncodingZstd:
+		// one goroutine per response, instead of goroutines by number of cpus
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("content encoding not support: %s", encoding)
+	}
+}
+
+// NewEncodingReader returns a reader which decompresses data from r by content encoding.
+func NewEncodingReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzip.NewReader(r)
+	case EncodingDeflate:
+		return zlib.NewReader(r)
+	case EncodingBrotli:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case EncodingZstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("content encoding not support: %s", encoding)
+	}
+}
+
+// NegotiateEncoding returns the supported encoding with highest q value from header "Accept-Encoding",
+// and returns empty if none is acceptable.
+func NegotiateEncoding(acceptEncoding string) string {
+	if len(acceptEncoding) == 0 {
+		return ""
+	}
+
+	qvalues := make(map[string]float64)
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if val, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = val
+				}
+			}
+		}
+		if len(name) > 0 {
+			qvalues[name] = q
+		}
+	}
+
+	candidates := make([]string, 0, len(SupportedEncodings))
+	for _, enc := range SupportedEncodings {
+		q, ok := qvalues[enc]
+		if !ok {
+			q, ok = qvalues["*"]
+		}
+		if ok && q > 0 {
+			candidates = append(candidates, enc)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return getQValue(qvalues, candidates[i]) > getQValue(qvalues, candidates[j])
+	})
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0]
+}
+
+func getQValue(qvalues map[string]float64, encoding string) float64 {
+	if q, ok := qvalues[encoding]; ok {
+		return q
+	}
+	return qvalues["*"]
+}
+
+// DecodeRequestBody replaces request body by decompressed body if request has a supported "Content-Encoding",
+// and returns ErrDecodedBodyTooLarge if decompressed body exceeds MaxDecodedBodySize.
+func DecodeRequestBody(r *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(TextContentEncoding)))
+	if len(encoding) == 0 || encoding == EncodingIdentity || r.Body == nil {
+		return nil
+	}
+	if !IsSupportedEncoding(encoding) {
+		return fmt.Errorf("request content encoding not support: %s", encoding)
+	}
+
+	body, err := NewEncodingReader(encoding, r.Body)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(body, MaxDecodedBodySize+1))
+	if err != nil {
+		return fmt.Errorf("decode request body (%s) failed: %v", encoding, err)
+	}
+	if len(b) > MaxDecodedBodySize {
+		return ErrDecodedBodyTooLarge
+	}
+	r.Body.Close()
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Del(TextContentEncoding)
+	r.Header.Set(TextContentLength, strconv.Itoa(len(b)))
+	return nil
+}
+
+/* Compress Response Writer */
+
+// EncodingResponseWriter compresses response body by negotiated or forced content encoding.
+type EncodingResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	mode        string
+	writer      io.WriteCloser
+	corruptBuf  *bytes.Buffer
+	wroteHeader bool
+	hijacked    bool
+}
+
+// NewEncodingResponseWriter returns a response writer which compresses body by request "Accept-Encoding",
+// and handlers may override encoding by SetResponseEncoding (like query args of mock apis).
+func NewEncodingResponseWriter(w http.ResponseWriter, r *http.Request) *EncodingResponseWriter {
+	return &EncodingResponseWriter{
+		ResponseWriter: w,
+		encoding:       NegotiateEncoding(r.Header.Get(TextAcceptEncoding)),
+		mode:           EncodingModeAuto,
+	}
+}
+
+// SetEncoding overrides response encoding (if not empty) and mode (if not empty), and takes effect before header is written.
+func (ew *EncodingResponseWriter) SetEncoding(encoding, mode string) {
+	if ew.wroteHeader {
+		return
+	}
+	if len(encoding) > 0 {
+		ew.encoding = strings.ToLower(encoding)
+		if ew.encoding == EncodingIdentity {
+			ew.encoding = ""
+		}
+	}
+	if len(mode) > 0 {
+		ew.mode = strings.ToLower(mode)
+	}
+}
+
+// SetResponseEncoding overrides encoding and mode of response if w is (or wraps) an EncodingResponseWriter.
+func SetResponseEncoding(w http.ResponseWriter, encoding, mode string) {
+	for {
+		switch rw := w.(type) {
+		case *EncodingResponseWriter:
+			rw.SetEncoding(encoding, mode)
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// WriteHeader writes header with "Content-Encoding" if body should be compressed.
+func (ew *EncodingResponseWriter) WriteHeader(code int) {
+	if ew.wroteHeader {
+		return
+	}
+	ew.wroteHeader = true
+
+	header := ew.Header()
+	if ew.shouldEncode(code) {
+		writer, err := NewEncodingWriter(ew.encoding, ew.ResponseWriter)
+		if err == nil {
+			declared := ew.encoding
+			switch ew.mode {
+			case EncodingModeWrong:
+				declared = getWrongEncoding(ew.encoding)
+			case EncodingModeCorrupt:
+				ew.corruptBuf = new(bytes.Buffer)
+				writer, _ = NewEncodingWriter(ew.encoding, ew.corruptBuf)
+			}
+			ew.writer = writer
+			header.Set(TextContentEncoding, declared)
+			header.Del(TextContentLength)
+			header.Add("Vary", TextAcceptEncoding)
+		}
+	}
+	ew.ResponseWriter.WriteHeader(code)
+}
+
+func (ew *EncodingResponseWriter) shouldEncode(code int) bool {
+	if len(ew.encoding) == 0 || ew.mode == EncodingModeNone || !IsSupportedEncoding(ew.encoding) {
+		return false
+	}
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		return false
+	}
+	// handler compresses body itself
+	return len(ew.Header().Get(TextContentEncoding)) == 0
+}
+
+func getWrongEncoding(encoding string) string {
+	for _, enc := range SupportedEncodings {
+		if enc != encoding {
+			return enc
+		}
+	}
+	return EncodingIdentity
+}
+
+// Write writes compressed data.
+func (ew *EncodingResponseWriter) Write(b []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.writer == nil {
+		return ew.ResponseWriter.Write(b)
+	}
+	return ew.writer.Write(b)
+}
+
+// Flush flushes compressed data to client.
+func (ew *EncodingResponseWriter) Flush() {
+	if f, ok := ew.writer.(interface{ Flush() error }); ok && ew.corruptBuf == nil {
+		f.Flush()
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets handler take over the connection, and response is not compressed.
+func (ew *EncodingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := ew.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijack")
+	}
+	ew.hijacked = true
+	return h.Hijack()
+}
+
+// Close finishes compressed stream, and must be called after handler returns.
+func (ew *EncodingResponseWriter) Close() error {
+	if ew.writer == nil || ew.hijacked {
+		return nil
+	}
+	if err := ew.writer.Close(); err != nil {
+		return err
+	}
+	if ew.corruptBuf == nil {
+		return nil
+	}
+
+	// flips bytes in middle, and drops tail bytes of compressed stream
+	b := ew.corruptBuf.Bytes()
+	for i := len(b) / 2; i < len(b) && i < len(b)/2+8; i++ {
+		b[i] ^= 0xff
+	}
+	if len(b) > 4 {
+		b = b[:len(b)-4]
+	}
+	_, err := ew.ResponseWriter.Write(b)
+	return err
+}
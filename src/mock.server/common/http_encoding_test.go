@@ -0,0 +1,167 @@
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		accept string
+		expect string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, deflate, br", EncodingBrotli},
+		{"gzip;q=1.0, br;q=0.5", EncodingGzip},
+		{"zstd, br;q=0", EncodingZstd},
+		{"*", EncodingBrotli},
+		{"*;q=0.1, deflate", EncodingDeflate},
+	}
+	for i, c := range cases {
+		t.Logf("Case%02d: negotiate encoding for %q.", i+1, c.accept)
+		if ret := NegotiateEncoding(c.accept); ret != c.expect {
+			t.Errorf("Unexpected encoding: %q, expected: %q", ret, c.expect)
+		}
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	text := strings.Repeat("mock server encoding test. ", 100)
+	for _, enc := range SupportedEncodings {
+		t.Logf("Case: encode and decode by %s.", enc)
+		var buf bytes.Buffer
+		w, err := NewEncodingWriter(enc, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(text))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewEncodingReader(enc, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != text {
+			t.Errorf("decoded text not matched for %s.", enc)
+		}
+	}
+}
+
+func newEncodingRecorder(t *testing.T, accept, encoding, mode, body string) (*httptest.ResponseRecorder, string) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set(TextAcceptEncoding, accept)
+	rr := httptest.NewRecorder()
+
+	ew := NewEncodingResponseWriter(rr, req)
+	SetResponseEncoding(ew, encoding, mode)
+	ew.Write([]byte(body))
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := rr.Header().Get(TextContentEncoding)
+	if len(enc) == 0 {
+		return rr, rr.Body.String()
+	}
+	r, err := NewEncodingReader(enc, bytes.NewReader(rr.Body.Bytes()))
+	if err != nil {
+		return rr, ""
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return rr, ""
+	}
+	return rr, string(b)
+}
+
+func TestEncodingResponseWriter(t *testing.T) {
+	const body = "encoding response writer test."
+
+	t.Log("Case01: compress by Accept-Encoding.")
+	rr, text := newEncodingRecorder(t, "gzip", "", "", body)
+	if rr.Header().Get(TextContentEncoding) != EncodingGzip || text != body {
+		t.Errorf("Unexpected response: encoding=%s, body=%q", rr.Header().Get(TextContentEncoding), text)
+	}
+
+	t.Log("Case02: no compression without Accept-Encoding.")
+	rr, text = newEncodingRecorder(t, "", "", "", body)
+	if len(rr.Header().Get(TextContentEncoding)) > 0 || text != body {
+		t.Errorf("Unexpected response: encoding=%s, body=%q", rr.Header().Get(TextContentEncoding), text)
+	}
+
+	t.Log("Case03: force encoding.")
+	rr, text = newEncodingRecorder(t, "gzip", EncodingZstd, "", body)
+	if rr.Header().Get(TextContentEncoding) != EncodingZstd || text != body {
+		t.Errorf("Unexpected response: encoding=%s, body=%q", rr.Header().Get(TextContentEncoding), text)
+	}
+
+	t.Log("Case04: send wrong encoding.")
+	rr, text = newEncodingRecorder(t, "gzip", "", EncodingModeWrong, body)
+	if rr.Header().Get(TextContentEncoding) == EncodingGzip || text == body {
+		t.Errorf("Unexpected response: encoding=%s, body=%q", rr.Header().Get(TextContentEncoding), text)
+	}
+
+	t.Log("Case05: send corrupt compressed stream.")
+	rr, text = newEncodingRecorder(t, "gzip", "", EncodingModeCorrupt, body)
+	if rr.Header().Get(TextContentEncoding) != EncodingGzip || text == body {
+		t.Errorf("Unexpected response: encoding=%s, body=%q", rr.Header().Get(TextContentEncoding), text)
+	}
+
+	t.Log("Case06: disable compression.")
+	rr, text = newEncodingRecorder(t, "gzip", "", EncodingModeNone, body)
+	if len(rr.Header().Get(TextContentEncoding)) > 0 || text != body {
+		t.Errorf("Unexpected response: encoding=%s, body=%q", rr.Header().Get(TextContentEncoding), text)
+	}
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	const body = `{"key":"value"}`
+	var buf bytes.Buffer
+	w, _ := NewEncodingWriter(EncodingBrotli, &buf)
+	w.Write([]byte(body))
+	w.Close()
+
+	t.Log("Case01: decode br request body.")
+	req, err := http.NewRequest("POST", "/test", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(TextContentEncoding, EncodingBrotli)
+	if err := DecodeRequestBody(req); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(req.Body)
+	if string(b) != body {
+		t.Errorf("Unexpected decoded body: %q", string(b))
+	}
+
+	t.Log("Case02: decode request body with not supported encoding.")
+	req, _ = http.NewRequest("POST", "/test", strings.NewReader(body))
+	req.Header.Set(TextContentEncoding, "compress")
+	if err := DecodeRequestBody(req); err == nil {
+		t.Error("Expected error for not supported encoding.")
+	}
+
+	t.Log("Case03: decode request body larger than max size.")
+	buf.Reset()
+	w, _ = NewEncodingWriter(EncodingGzip, &buf)
+	w.Write(make([]byte, MaxDecodedBodySize+1))
+	w.Close()
+	req, _ = http.NewRequest("POST", "/test", &buf)
+	req.Header.Set(TextContentEncoding, EncodingGzip)
+	if err := DecodeRequestBody(req); err != ErrDecodedBodyTooLarge {
+		t.Errorf("Expected too large error, got: %v", err)
+	}
+}
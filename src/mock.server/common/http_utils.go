@@ -1,7 +1,6 @@
 package common
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
@@ -11,18 +10,22 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"src/tools.app/utils/httpresp"
 )
 
 /* Logger */
 
-// LogRequestData logs http resquest.
-func LogRequestData(r *http.Request) error {
-	req, err := httputil.DumpRequest(r, true)
+// LogRequestData logs request line of http request with given headers and body, and body of request is not read.
+func LogRequestData(r *http.Request, header http.Header, body string) error {
+	dump := r.WithContext(r.Context())
+	dump.Header = header
+	req, err := httputil.DumpRequest(dump, false)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Request:\n", strings.Trim(string(req), "\n"))
+	log.Println("Request:\n", strings.Trim(string(req)+body, "\n"))
 	return nil
 }
 
@@ -34,70 +37,30 @@ func LogDivLine() {
 /* Http Response */
 
 // JSONResponse json http response.
-type JSONResponse struct {
-	// Reserved field to add some meta information to the API response.
-	Meta interface{} `json:"meta"`
-	Data interface{} `json:"data"`
-}
+type JSONResponse = httpresp.Response
 
 // JSONErrResponse json http error response.
-type JSONErrResponse struct {
-	Error *ErrorDesc `json:"error"`
-}
-
-// ErrorDesc json http error description.
-type ErrorDesc struct {
-	Status int    `json:"status"`
-	Desc   string `json:"desc"`
-}
+type JSONErrResponse = httpresp.ErrorResponse
 
 // WriteOKJSONResp writes http ok response as a standard JSON.
 func WriteOKJSONResp(w http.ResponseWriter, data interface{}) error {
-	w.Header().Set(TextContentType, ContentTypeJSON)
-	w.WriteHeader(http.StatusOK)
-	return json.NewEncoder(w).Encode(&JSONResponse{Data: data})
+	return httpresp.WriteOK(w, nil, data)
 }
 
 // WriteOKHTMLResp writes http ok response as html.
 func WriteOKHTMLResp(w http.ResponseWriter, data []byte) error {
-	w.Header().Set(TextContentType, ContentTypeHTML)
-	w.Header().Set(TextContentLength, strconv.Itoa(len(data)))
-	w.WriteHeader(http.StatusOK)
-
-	if _, err := w.Write(data); err != nil {
-		return err
-	}
-	return nil
-}
-
-// AddCorsHeaders writes headers to fix CORS issue.
-func AddCorsHeaders(r *http.Request, w http.ResponseWriter) {
-	if IsProd() {
-		return
-	}
-
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	if r.Method == "OPTIONS" {
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept,Origin,Content-Type,X-Custom-Header,X-Test")
-	}
+	return httpresp.WriteHTML(w, http.StatusOK, data)
 }
 
 // WriteErrJSONResp writes http error response as a Standard API JSON with a resp code.
 func WriteErrJSONResp(w http.ResponseWriter, errCode int, errMsg string) error {
-	w.Header().Set(TextContentType, ContentTypeJSON)
-	w.WriteHeader(errCode)
-	return json.NewEncoder(w).Encode(
-		&JSONErrResponse{Error: &ErrorDesc{Status: errCode, Desc: errMsg}})
+	return httpresp.WriteError(w, nil, httpresp.NewError(errCode, errMsg))
 }
 
-// ErrHandler handles "internal server error".
+// ErrHandler handles "internal server error", and err is logged instead of written.
 func ErrHandler(w http.ResponseWriter, err error) {
-	log.Println(strings.Repeat("*", 6), err)
-	if err := WriteErrJSONResp(w, http.StatusInternalServerError, SvrErrRespMsg); err != nil {
+	if err := httpresp.WriteError(w, nil, httpresp.Internal(err)); err != nil {
 		log.Println(strings.Repeat("*", 6), err)
-		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
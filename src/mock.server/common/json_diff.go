@@ -0,0 +1,112 @@
+package common
+
+import (
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+/* JSON Diff */
+
+// Types of json diff.
+const (
+	DiffChanged = "changed"
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+)
+
+// JSONDiff a difference between two json values at path, like "data.items.0.name".
+type JSONDiff struct {
+	Path  string      `json:"path"`
+	Type  string      `json:"type"`
+	Left  interface{} `json:"left,omitempty"`
+	Right interface{} `json:"right,omitempty"`
+}
+
+// DiffJSON compares decoded json values, and paths matched ignore patterns (by path.Match, like "data.*.time") are skipped.
+func DiffJSON(left, right interface{}, ignores []string) []JSONDiff {
+	diffs := make([]JSONDiff, 0)
+	diffJSONValue("", left, right, ignores, &diffs)
+	return diffs
+}
+
+func diffJSONValue(p string, left, right interface{}, ignores []string, diffs *[]JSONDiff) {
+	if isIgnoredPath(p, ignores) {
+		return
+	}
+
+	switch l := left.(type) {
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(l)+len(r))
+		for k := range l {
+			keys = append(keys, k)
+		}
+		for k := range r {
+			if _, ok := l[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			subPath := joinJSONPath(p, k)
+			lv, lok := l[k]
+			rv, rok := r[k]
+			if lok && rok {
+				diffJSONValue(subPath, lv, rv, ignores, diffs)
+			} else if !isIgnoredPath(subPath, ignores) {
+				if lok {
+					*diffs = append(*diffs, JSONDiff{Path: subPath, Type: DiffRemoved, Left: lv})
+				} else {
+					*diffs = append(*diffs, JSONDiff{Path: subPath, Type: DiffAdded, Right: rv})
+				}
+			}
+		}
+		return
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(l) || i < len(r); i++ {
+			subPath := joinJSONPath(p, strconv.Itoa(i))
+			if i >= len(r) {
+				if !isIgnoredPath(subPath, ignores) {
+					*diffs = append(*diffs, JSONDiff{Path: subPath, Type: DiffRemoved, Left: l[i]})
+				}
+			} else if i >= len(l) {
+				if !isIgnoredPath(subPath, ignores) {
+					*diffs = append(*diffs, JSONDiff{Path: subPath, Type: DiffAdded, Right: r[i]})
+				}
+			} else {
+				diffJSONValue(subPath, l[i], r[i], ignores, diffs)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(left, right) {
+		*diffs = append(*diffs, JSONDiff{Path: p, Type: DiffChanged, Left: left, Right: right})
+	}
+}
+
+func joinJSONPath(p, key string) string {
+	if len(p) == 0 {
+		return key
+	}
+	return p + "." + key
+}
+
+func isIgnoredPath(p string, ignores []string) bool {
+	for _, pattern := range ignores {
+		if ok, err := path.Match(pattern, p); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
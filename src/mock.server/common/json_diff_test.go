@@ -0,0 +1,49 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, text string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDiffJSON(t *testing.T) {
+	left := decodeJSON(t, `{"code":0,"data":{"name":"a","time":1,"items":[1,2,3],"old":true}}`)
+	right := decodeJSON(t, `{"code":0,"data":{"name":"b","time":2,"items":[1,2],"new":true}}`)
+
+	t.Log("Case01: diff json values.")
+	diffs := DiffJSON(left, right, nil)
+	expected := []JSONDiff{
+		{Path: "data.items.2", Type: DiffRemoved},
+		{Path: "data.name", Type: DiffChanged},
+		{Path: "data.new", Type: DiffAdded},
+		{Path: "data.old", Type: DiffRemoved},
+		{Path: "data.time", Type: DiffChanged},
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("Unexpected diffs: %+v", diffs)
+	}
+	for i, diff := range diffs {
+		if diff.Path != expected[i].Path || diff.Type != expected[i].Type {
+			t.Errorf("Unexpected diff: %+v, expected: %+v", diff, expected[i])
+		}
+	}
+
+	t.Log("Case02: diff json values with ignore rules.")
+	diffs = DiffJSON(left, right, []string{"data.time", "data.items", "*.old", "data.new"})
+	if len(diffs) != 1 || diffs[0].Path != "data.name" {
+		t.Errorf("Unexpected diffs: %+v", diffs)
+	}
+
+	t.Log("Case03: diff json values of different types.")
+	diffs = DiffJSON(decodeJSON(t, `{"a":[1]}`), decodeJSON(t, `{"a":{"0":1}}`), nil)
+	if len(diffs) != 1 || diffs[0].Path != "a" || diffs[0].Type != DiffChanged {
+		t.Errorf("Unexpected diffs: %+v", diffs)
+	}
+}
@@ -0,0 +1,160 @@
+package common
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTSigner signs and verifies RS256 json web tokens.
+type JWTSigner struct {
+	key *rsa.PrivateKey
+	kid string
+}
+
+// JWK a json web key of rsa public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var (
+	sharedSigner      *JWTSigner
+	sharedSignerMutex sync.Mutex
+)
+
+// GetJWTSigner returns the shared signer, key is loaded from "auth.private_key" or generated. Signer is
+// created again on next call if it's failed (like invalid key file), instead of the error is kept until restart.
+func GetJWTSigner() (*JWTSigner, error) {
+	sharedSignerMutex.Lock()
+	defer sharedSignerMutex.Unlock()
+	if sharedSigner != nil {
+		return sharedSigner, nil
+	}
+	signer, err := NewJWTSigner(RunConfigs.Auth.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	sharedSigner = signer
+	return sharedSigner, nil
+}
+
+// NewJWTSigner returns a signer with rsa private key from pem file, or a generated key if path is empty.
+func NewJWTSigner(keyPath string) (*JWTSigner, error) {
+	var (
+		key *rsa.PrivateKey
+		err error
+	)
+	if len(keyPath) == 0 {
+		if key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			return nil, err
+		}
+	} else if key, err = loadRSAPrivateKey(keyPath); err != nil {
+		return nil, err
+	}
+
+	pub := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	sum := sha256.Sum256(pub)
+	return &JWTSigner{key: key, kid: base64.RawURLEncoding.EncodeToString(sum[:8])}, nil
+}
+
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem file: %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not rsa: %s", path)
+	}
+	return rsaKey, nil
+}
+
+// Sign returns a signed jwt of claims.
+func (s *JWTSigner) Sign(claims map[string]interface{}) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT", "kid": s.kid}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	hashed := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Verify checks signature and "exp" of jwt, and returns claims.
+func (s *JWTSigner) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid jwt format")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt signature: %v", err)
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return nil, fmt.Errorf("invalid jwt signature: %v", err)
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt claims: %v", err)
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, fmt.Errorf("invalid jwt claims: %v", err)
+	}
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return nil, fmt.Errorf("jwt is expired")
+	}
+	return claims, nil
+}
+
+// JWKS returns public json web keys of signer.
+func (s *JWTSigner) JWKS() []JWK {
+	return []JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: s.kid,
+		N:   base64.RawURLEncoding.EncodeToString(s.key.PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.PublicKey.E)).Bytes()),
+	}}
+}
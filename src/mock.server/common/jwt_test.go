@@ -0,0 +1,63 @@
+package common
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWTSigner(t *testing.T) {
+	signer, err := NewJWTSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Case01: sign and verify jwt.")
+	token, err := signer.Sign(map[string]interface{}{"sub": "user01", "exp": time.Now().Unix() + 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["sub"] != "user01" {
+		t.Error("Unexpected claims:", claims)
+	}
+
+	t.Log("Case02: verify tampered jwt.")
+	parts := strings.Split(token, ".")
+	parts[1] = parts[1][:len(parts[1])-2] + "AA"
+	if _, err := signer.Verify(strings.Join(parts, ".")); err == nil {
+		t.Error("Expected error for tampered jwt.")
+	}
+
+	t.Log("Case03: verify expired jwt.")
+	token, err = signer.Sign(map[string]interface{}{"sub": "user01", "exp": time.Now().Unix() - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.Verify(token); err == nil {
+		t.Error("Expected error for expired jwt.")
+	}
+
+	t.Log("Case04: get jwks.")
+	if keys := signer.JWKS(); len(keys) != 1 || keys[0].E != "AQAB" {
+		t.Error("Unexpected jwks:", keys)
+	}
+}
+
+func TestGetJWTSignerRetry(t *testing.T) {
+	t.Log("Case01: shared signer is created again after failure.")
+	setRunConfigs(t, func(configs *Configs) {
+		configs.Auth.PrivateKey = "/not/exist/key.pem"
+	})
+	if _, err := GetJWTSigner(); err == nil {
+		t.Fatal("Expected error for not exist private key.")
+	}
+	RunConfigs.Auth.PrivateKey = ""
+	signer, err := GetJWTSigner()
+	if err != nil || signer == nil {
+		t.Fatalf("Unexpected shared signer: %v, %v", signer, err)
+	}
+}
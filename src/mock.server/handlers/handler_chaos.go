@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"src/mock.server/common"
+
+	"github.com/golib/httprouter"
+)
+
+/* Chaos proxy: requests are forwarded to a real upstream with faults by rules. */
+
+// chaosFault wraps fault configs with helpers shared by http and tcp proxies.
+type chaosFault struct {
+	common.ChaosFault
+}
+
+// hit returns whether faults are applied by probability.
+func (f *chaosFault) hit() bool {
+	return f.Probability <= 0 || rand.Float64() < f.Probability
+}
+
+// delay returns latency with random jitter.
+func (f *chaosFault) delay() time.Duration {
+	d := time.Duration(f.Latency) * time.Millisecond
+	if f.Jitter > 0 {
+		d += time.Duration(rand.Intn(f.Jitter)) * time.Millisecond
+	}
+	return d
+}
+
+// throttledWriter writes by bandwidth (bytes per second) in chunks of 100ms.
+type throttledWriter struct {
+	w         io.Writer
+	bandwidth int
+	flush     func()
+}
+
+func (tw *throttledWriter) Write(b []byte) (int, error) {
+	if tw.bandwidth <= 0 {
+		return tw.w.Write(b)
+	}
+
+	chunk := tw.bandwidth / 10
+	if chunk <= 0 {
+		chunk = 1
+	}
+	written := 0
+	for written < len(b) {
+		end := written + chunk
+		if end > len(b) {
+			end = len(b)
+		}
+		n, err := tw.w.Write(b[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if tw.flush != nil {
+			tw.flush()
+		}
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(tw.bandwidth))
+	}
+	return written, nil
+}
+
+// resetConn closes connection with RST instead of FIN.
+func resetConn(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// matchChaosRule returns fault of the first rule matched by method and upstream path, or nil.
+func matchChaosRule(method, path string) *chaosFault {
+	for _, rule := range common.RunConfigs.Chaos.Rules {
+		if len(rule.Method) > 0 && !strings.EqualFold(rule.Method, method) {
+			continue
+		}
+		if strings.HasPrefix(path, rule.Path) {
+			return &chaosFault{rule.ChaosFault}
+		}
+	}
+	return nil
+}
+
+// hijackConn hijacks connection of response writer, and data not flushed is dropped.
+func hijackConn(w http.ResponseWriter) net.Conn {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		log.Println("http.ResponseWriter not http.Hijacker.")
+		return nil
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		log.Println("hijack connection failed:", err)
+		return nil
+	}
+	return conn
+}
+
+// ChaosProxyHandler forwards request to chaos upstream, and injects faults of matched rule.
+// ANY /chaos/*path
+func ChaosProxyHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	cfg := &common.RunConfigs.Chaos
+	if len(cfg.Upstream) == 0 {
+		common.WriteErrJSONResp(w, http.StatusServiceUnavailable, "chaos upstream is not configured")
+		return
+	}
+
+	path := "/" + strings.TrimPrefix(params.ByName("path"), "/")
+	fault := matchChaosRule(r.Method, path)
+	if fault != nil && !fault.hit() {
+		fault = nil
+	}
+	if fault != nil {
+		if d := fault.delay(); d > 0 {
+			log.Printf("chaos wait %v before forward.\n", d)
+			time.Sleep(d)
+		}
+		if fault.Reset {
+			log.Println("chaos reset connection for:", path)
+			if conn := hijackConn(w); conn != nil {
+				resetConn(conn)
+			}
+			return
+		}
+		if fault.Status > 0 {
+			common.WriteErrJSONResp(w, fault.Status, "chaos fault status")
+			return
+		}
+	}
+
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			common.ErrHandler(w, err)
+			return
+		}
+		body = b
+	}
+	uri := path
+	if len(r.URL.RawQuery) > 0 {
+		uri += "?" + r.URL.RawQuery
+	}
+	client := &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second}
+	resp := sendUpstreamRequest(client, r, cfg.Upstream+uri, body)
+	if resp.err != nil {
+		common.WriteErrJSONResp(w, http.StatusBadGateway, "chaos upstream error: "+resp.err.Error())
+		return
+	}
+
+	for k, values := range resp.header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set(common.TextContentLength, strconv.Itoa(len(resp.body)))
+	if fault == nil {
+		w.WriteHeader(resp.status)
+		if _, err := w.Write(resp.body); err != nil {
+			log.Println("write chaos response failed:", err)
+		}
+		return
+	}
+
+	// body is sent as it is, to keep truncation and bandwidth by bytes
+	common.SetResponseEncoding(w, "", common.EncodingModeNone)
+	w.WriteHeader(resp.status)
+	tw := &throttledWriter{w: w, bandwidth: fault.Bandwidth}
+	if flusher, ok := w.(http.Flusher); ok {
+		tw.flush = flusher.Flush
+	}
+	data := resp.body
+	if fault.Truncate > 0 && fault.Truncate < int64(len(data)) {
+		data = data[:fault.Truncate]
+	}
+	if _, err := tw.Write(data); err != nil {
+		log.Println("write chaos response failed:", err)
+		return
+	}
+	if len(data) < len(resp.body) {
+		log.Printf("chaos truncate response at %d bytes for: %s\n", len(data), path)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		if conn := hijackConn(w); conn != nil {
+			conn.Close()
+		}
+	}
+}
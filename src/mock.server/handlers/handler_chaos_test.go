@@ -0,0 +1,218 @@
+package handlers_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"src/mock.server/common"
+	"src/mock.server/handlers"
+)
+
+func setChaosConfigs(t *testing.T, upstream string, rules ...common.ChaosRule) {
+	setRunConfigs(t, func(configs *common.Configs) {
+		configs.Chaos = common.ChaosConfigs{Upstream: upstream, Timeout: 3, Rules: rules}
+	})
+}
+
+func TestChaosProxy(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+	setChaosConfigs(t, upstream.URL,
+		common.ChaosRule{Path: "/status", ChaosFault: common.ChaosFault{Status: 503}},
+		common.ChaosRule{Path: "/truncate", ChaosFault: common.ChaosFault{Truncate: 10}},
+		common.ChaosRule{Path: "/reset", ChaosFault: common.ChaosFault{Reset: true}},
+		common.ChaosRule{Path: "/slow", ChaosFault: common.ChaosFault{Latency: 100, Bandwidth: 500}},
+	)
+	server := httptest.NewServer(handlers.NewHTTPRouter())
+	defer server.Close()
+
+	t.Log("Case01: forward without fault.")
+	resp, err := http.Get(server.URL + "/chaos/normal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(b) != body {
+		t.Errorf("Unexpected response: %d, %s", resp.StatusCode, string(b))
+	}
+
+	t.Log("Case02: fault status.")
+	resp, err = http.Get(server.URL + "/chaos/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected status code: %d", resp.StatusCode)
+	}
+
+	t.Log("Case03: truncated response.")
+	resp, err = http.Get(server.URL + "/chaos/truncate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil || len(b) != 10 {
+		t.Errorf("Unexpected truncated response: %d bytes, err=%v", len(b), err)
+	}
+
+	t.Log("Case04: reset connection.")
+	if _, err := http.Get(server.URL + "/chaos/reset"); err == nil {
+		t.Error("Expected error for reset connection.")
+	}
+
+	t.Log("Case05: latency and bandwidth limit.")
+	start := time.Now()
+	resp, err = http.Get(server.URL + "/chaos/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if elapsed := time.Since(start); string(b) != body || elapsed < 150*time.Millisecond {
+		t.Errorf("Unexpected slow response: %v, %s", elapsed, string(b))
+	}
+}
+
+func TestChaosProxyRawBody(t *testing.T) {
+	var gotEncoding string
+	var gotBody []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get(common.TextContentEncoding)
+		gotBody, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer upstream.Close()
+	setChaosConfigs(t, upstream.URL)
+	server := httptest.NewServer(handlers.NewHTTPRouter())
+	defer server.Close()
+
+	t.Log("Case01: encoded request body is forwarded byte for byte, instead of decoded by hooks.")
+	const body = "\x1f\x8b not a valid compressed body"
+	req, _ := http.NewRequest("POST", server.URL+"/chaos/raw", strings.NewReader(body))
+	req.Header.Set(common.TextContentEncoding, "compress")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || gotEncoding != "compress" || string(gotBody) != body {
+		t.Errorf("Unexpected forwarded request: %d, %s, %q", resp.StatusCode, gotEncoding, string(gotBody))
+	}
+}
+
+func newEchoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					conn.Write([]byte("echo: " + scanner.Text() + "\n"))
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func TestChaosTCPProxy(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+
+	t.Log("Case01: forward tcp connection with truncation.")
+	proxy, err := handlers.NewChaosTCPProxy(common.TCPProxy{
+		Listen:     "127.0.0.1:0",
+		Upstream:   echo.Addr().String(),
+		ChaosFault: common.ChaosFault{Truncate: 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("hello\n"))
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	b, _ := ioutil.ReadAll(conn)
+	conn.Close()
+	if string(b) != "echo: he" {
+		t.Errorf("Unexpected truncated data: %q", string(b))
+	}
+
+	t.Log("Case02: reply of upstream is forwarded after client half-closed.")
+	replier, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replier.Close()
+	go func() {
+		conn, err := replier.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// reply after request is fully read
+		b, _ := ioutil.ReadAll(conn)
+		time.Sleep(50 * time.Millisecond)
+		conn.Write([]byte("reply: " + string(b)))
+	}()
+	halfProxy, err := handlers.NewChaosTCPProxy(common.TCPProxy{Listen: "127.0.0.1:0", Upstream: replier.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer halfProxy.Close()
+	conn, err = net.Dial("tcp", halfProxy.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("hello"))
+	conn.(*net.TCPConn).CloseWrite()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	b, _ = ioutil.ReadAll(conn)
+	conn.Close()
+	if string(b) != "reply: hello" {
+		t.Errorf("Unexpected reply after half close: %q", string(b))
+	}
+
+	t.Log("Case03: reset tcp connection.")
+	resetProxy, err := handlers.NewChaosTCPProxy(common.TCPProxy{
+		Listen:     "127.0.0.1:0",
+		Upstream:   echo.Addr().String(),
+		ChaosFault: common.ChaosFault{Reset: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resetProxy.Close()
+	conn, err = net.Dial("tcp", resetProxy.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("hello\n"))
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 16)); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected reset error, got: %v", err)
+	}
+	conn.Close()
+}
@@ -4,32 +4,53 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 
 	"src/mock.server/common"
+	"src/tools.app/utils/httpresp"
 
 	"github.com/golib/httprouter"
 )
 
-var count int
-
 // MockDefault sends a default message.
 func MockDefault(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	count = count + 1
+	counter, err := common.GetCounter()
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	count, err := counter.Incr("mock_default_access")
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+
 	msg := fmt.Sprintf("Mock Server, access count: %d", count)
 	if err := common.WriteOKHTMLResp(w, []byte(msg)); err != nil {
 		common.ErrHandler(w, err)
 	}
 }
 
-// MockNotFound sends a server not found page.
+// notFoundResponse not found response with closest stubs.
+type notFoundResponse struct {
+	Error      *httpresp.Error `json:"error"`
+	NearMisses []NearMiss      `json:"near_misses"`
+}
+
+// MockNotFound sends not found with closest stubs of request, and request is marked as unmatched in journal.
 func MockNotFound(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ret := fmt.Sprintf("Page not found for path: %s", r.RequestURI)
-	w.Header().Set(common.TextContentLength, strconv.Itoa(len(ret)))
-	w.WriteHeader(http.StatusNotFound)
-	log.Printf("Page not found: %s\n", r.URL.Path)
+	misses := findNearMisses(r)
+	markUnmatched(r, misses)
+	log.Printf("Page not found: %s, near misses: %d\n", r.URL.Path, len(misses))
 
-	if _, err := w.Write([]byte(ret)); err != nil {
-		common.ErrHandler(w, err)
+	e := httpresp.Errorf(http.StatusNotFound, "Page not found for path: %s", r.RequestURI)
+	e.RequestID = httpresp.RequestID(r)
+	if err := httpresp.WriteJSON(w, r, http.StatusNotFound, &notFoundResponse{Error: e, NearMisses: misses}); err != nil {
+		log.Println("write not found response failed:", err)
 	}
 }
+
+// DashboardHandler redirects to dashboard page.
+// GET /dashboard
+func DashboardHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	http.Redirect(w, r, "/static/dashboard.html", http.StatusFound)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"src/mock.server/common"
+
+	"github.com/golib/httprouter"
+)
+
+// serverReady is 1 after stubs (mock tree and state) are loaded, and 0 again when server is shutting down.
+var serverReady int32
+
+// SetReady sets whether mock server is ready to serve requests.
+func SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&serverReady, v)
+}
+
+// IsReady returns whether mock server is ready to serve requests.
+func IsReady() bool {
+	return atomic.LoadInt32(&serverReady) == 1
+}
+
+// HealthzHandler liveness probe, and returns ok as long as server is running.
+// GET /healthz
+func HealthzHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: "ok"})
+}
+
+// ReadyzHandler readiness probe, and returns 503 before stubs are loaded or when server is shutting down.
+// GET /readyz
+func ReadyzHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !IsReady() {
+		common.WriteErrJSONResp(w, http.StatusServiceUnavailable, "not ready")
+		return
+	}
+	common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: "ready"})
+}
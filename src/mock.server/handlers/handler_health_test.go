@@ -0,0 +1,93 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"src/mock.server/handlers"
+)
+
+func TestHealthProbes(t *testing.T) {
+	t.Log("Case01: healthz is ok.")
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Errorf("Unexpected healthz status code: %d", rr.Code)
+	}
+
+	t.Log("Case02: readyz before and after ready.")
+	handlers.SetReady(false)
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	if rr := serveRouter(req); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected readyz status code before ready: %d", rr.Code)
+	}
+	handlers.SetReady(true)
+	defer handlers.SetReady(false)
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Errorf("Unexpected readyz status code after ready: %d", rr.Code)
+	}
+}
+
+func TestServerState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mock_state.json")
+
+	t.Log("Case01: state file not exist.")
+	if err := handlers.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Case02: save state, and restore scenarios and journal.")
+	req, _ := http.NewRequest("POST", "/scenarios/state_test?state=saved", strings.NewReader(""))
+	serveRouter(req)
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	serveRouter(req)
+	if err := handlers.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/scenarios", nil)
+	serveRouter(req)
+	req, _ = http.NewRequest("DELETE", "/journal", nil)
+	serveRouter(req)
+	if err := handlers.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ = http.NewRequest("GET", "/scenarios", nil)
+	if body := serveRouter(req).Body.String(); !strings.Contains(body, `"state_test":"saved"`) {
+		t.Errorf("Scenario not restored: %s", body)
+	}
+	req, _ = http.NewRequest("GET", "/journal", nil)
+	resp := struct {
+		Data []handlers.JournalEntry `json:"data"`
+	}{}
+	if err := json.Unmarshal(serveRouter(req).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, entry := range resp.Data {
+		if entry.URL == "/ping" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Journal not restored: %+v", resp.Data)
+	}
+
+	t.Log("Case03: invalid state file.")
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := handlers.LoadState(path); err == nil {
+		t.Error("Want error for invalid state file")
+	}
+}
@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"src/mock.server/common"
+
+	"github.com/golib/httprouter"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	jobStatusRunning   = "running"
+	jobStatusSucceeded = "succeeded"
+	jobStatusFailed    = "failed"
+	jobStatusCancelled = "cancelled"
+
+	defaultMaxJobs = 20
+)
+
+/* Cmd Job */
+
+// jobChunk a chunk of stdout or stderr output of job.
+type jobChunk struct {
+	Stream string `json:"stream"`
+	Data   string `json:"data"`
+}
+
+// cmdJob an async job which runs allowed commands.
+type cmdJob struct {
+	id        string
+	commands  []*allowedCmd
+	cancel    context.CancelFunc
+	maxOutput int
+
+	mutex    sync.Mutex
+	status   string
+	err      string
+	startAt  time.Time
+	endAt    time.Time
+	chunks   []jobChunk
+	size     int
+	truncate bool
+	// changed is closed and replaced when output appended or job done.
+	changed chan struct{}
+}
+
+// CmdJobJSON job status json.
+type CmdJobJSON struct {
+	ID        string   `json:"id"`
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+	Commands  []string `json:"commands"`
+	StartAt   string   `json:"start_at"`
+	EndAt     string   `json:"end_at,omitempty"`
+	Stdout    string   `json:"stdout,omitempty"`
+	Stderr    string   `json:"stderr,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+func (job *cmdJob) isDone() bool {
+	return job.status != jobStatusRunning
+}
+
+// notify wakes up output followers, and must be called with job locked.
+func (job *cmdJob) notify() {
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+func (job *cmdJob) appendOutput(stream string, p []byte) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	if remain := job.maxOutput - job.size; remain < len(p) {
+		job.truncate = true
+		if remain <= 0 {
+			return
+		}
+		p = p[:remain]
+	}
+	job.size += len(p)
+	job.chunks = append(job.chunks, jobChunk{Stream: stream, Data: string(p)})
+	job.notify()
+}
+
+func (job *cmdJob) finish(status string, err error) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	job.status = status
+	if err != nil {
+		job.err = err.Error()
+	}
+	job.endAt = time.Now()
+	job.notify()
+}
+
+// readChunks returns output chunks from offset, whether job is done, and a channel to wait for changes.
+func (job *cmdJob) readChunks(offset int) ([]jobChunk, bool, <-chan struct{}) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	var chunks []jobChunk
+	if offset < len(job.chunks) {
+		chunks = job.chunks[offset:]
+	}
+	return chunks, job.isDone(), job.changed
+}
+
+func (job *cmdJob) toJSON(withOutput bool) *CmdJobJSON {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	ret := &CmdJobJSON{
+		ID:        job.id,
+		Status:    job.status,
+		Error:     job.err,
+		StartAt:   job.startAt.Format(time.RFC3339),
+		Truncated: job.truncate,
+	}
+	for _, cmd := range job.commands {
+		ret.Commands = append(ret.Commands, cmd.line)
+	}
+	if !job.endAt.IsZero() {
+		ret.EndAt = job.endAt.Format(time.RFC3339)
+	}
+	if withOutput {
+		var stdout, stderr strings.Builder
+		for _, chunk := range job.chunks {
+			if chunk.Stream == "stderr" {
+				stderr.WriteString(chunk.Data)
+			} else {
+				stdout.WriteString(chunk.Data)
+			}
+		}
+		ret.Stdout = stdout.String()
+		ret.Stderr = stderr.String()
+	}
+	return ret
+}
+
+// jobWriter writes command output into job by stream name.
+type jobWriter struct {
+	job    *cmdJob
+	stream string
+}
+
+func (w *jobWriter) Write(p []byte) (int, error) {
+	w.job.appendOutput(w.stream, p)
+	return len(p), nil
+}
+
+func (job *cmdJob) run(ctx context.Context, audit *toolsAuditEntry) {
+	defer job.cancel()
+
+	status := jobStatusSucceeded
+	var err error
+	for _, cmd := range job.commands {
+		log.Printf("Job %s run cmd: %s\n", job.id, cmd.line)
+		if err = runAllowedCmd(ctx, cmd, &jobWriter{job, "stdout"}, &jobWriter{job, "stderr"}); err != nil {
+			status = jobStatusFailed
+			if ctx.Err() == context.Canceled {
+				status = jobStatusCancelled
+			}
+			break
+		}
+	}
+	// audit is written before job is finished, and waiters of job see a completed audit
+	audit.Status = http.StatusOK
+	if err != nil {
+		audit.Status = http.StatusInternalServerError
+		audit.Error = err.Error()
+	}
+	writeToolsAudit(audit)
+	job.finish(status, err)
+	log.Printf("Job %s done: %s\n", job.id, status)
+}
+
+/* Job Store */
+
+// jobStore keeps the last max jobs in memory.
+type jobStore struct {
+	mutex sync.Mutex
+	jobs  map[string]*cmdJob
+	ids   []string
+	seq   int64
+}
+
+var cmdJobs = &jobStore{jobs: make(map[string]*cmdJob)}
+
+// create starts a job, and returns error if running jobs reach max jobs.
+func (s *jobStore) create(cmds []*allowedCmd, audit *toolsAuditEntry) (*cmdJob, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	max := getMaxJobs()
+	running := 0
+	for _, job := range s.jobs {
+		job.mutex.Lock()
+		if !job.isDone() {
+			running++
+		}
+		job.mutex.Unlock()
+	}
+	if running >= max {
+		return nil, fmt.Errorf("too many running jobs: %d", running)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(getCmdsTimeout(cmds))*time.Second)
+	job := &cmdJob{
+		id:        strconv.FormatInt(time.Now().Unix(), 36) + "-" + strconv.FormatInt(atomic.AddInt64(&s.seq, 1), 36),
+		commands:  cmds,
+		cancel:    cancel,
+		maxOutput: getCmdsMaxOutput(cmds),
+		status:    jobStatusRunning,
+		startAt:   time.Now(),
+		changed:   make(chan struct{}),
+	}
+	audit.Job = job.id
+	s.jobs[job.id] = job
+	s.ids = append(s.ids, job.id)
+	s.evict()
+
+	go job.run(ctx, audit)
+	return job, nil
+}
+
+func getMaxJobs() int {
+	if max := common.RunConfigs.Tools.MaxJobs; max > 0 {
+		return max
+	}
+	return defaultMaxJobs
+}
+
+// evict removes the oldest finished jobs when jobs count exceeds max, and must be called with store locked.
+func (s *jobStore) evict() {
+	max := getMaxJobs()
+	ids := make([]string, 0, len(s.ids))
+	remove := len(s.ids) - max
+	for _, id := range s.ids {
+		job := s.jobs[id]
+		job.mutex.Lock()
+		done := job.isDone()
+		job.mutex.Unlock()
+		if remove > 0 && done {
+			delete(s.jobs, id)
+			remove--
+			continue
+		}
+		ids = append(ids, id)
+	}
+	s.ids = ids
+}
+
+func (s *jobStore) get(id string) (*cmdJob, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+func (s *jobStore) list() []*cmdJob {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.evict()
+
+	jobs := make([]*cmdJob, 0, len(s.ids))
+	for _, id := range s.ids {
+		jobs = append(jobs, s.jobs[id])
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].startAt.After(jobs[j].startAt)
+	})
+	return jobs
+}
+
+func getCmdsMaxOutput(cmds []*allowedCmd) int {
+	total := 0
+	for _, cmd := range cmds {
+		total += cmd.maxOutput
+	}
+	return total
+}
+
+/* Job Handlers */
+
+// ToolsJobsHandler router for tools cmd jobs handlers.
+// GET /tools/jobs, GET /tools/jobs/:id, GET /tools/jobs/:id/:action, DELETE /tools/jobs/:id
+func ToolsJobsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// signature covers method and path with empty body, so it's not valid for other jobs
+	if code, err := authToolsRequest(r, nil); err != nil {
+		common.WriteErrJSONResp(w, code, err.Error())
+		return
+	}
+
+	id := params.ByName("id")
+	if len(id) == 0 {
+		jobs := cmdJobs.list()
+		ret := make([]*CmdJobJSON, 0, len(jobs))
+		for _, job := range jobs {
+			ret = append(ret, job.toJSON(false))
+		}
+		if err := common.WriteOKJSONResp(w, ret); err != nil {
+			common.ErrHandler(w, err)
+		}
+		return
+	}
+
+	job, ok := cmdJobs.get(id)
+	if !ok {
+		common.WriteErrJSONResp(w, http.StatusNotFound, fmt.Sprintf("job not found: %s", id))
+		return
+	}
+
+	if r.Method == "DELETE" {
+		cancelCmdJob(w, r, job)
+		return
+	}
+	switch action := params.ByName("action"); action {
+	case "":
+		if err := common.WriteOKJSONResp(w, job.toJSON(true)); err != nil {
+			common.ErrHandler(w, err)
+		}
+	case "output":
+		streamJobOutput(w, r, job)
+	case "ws":
+		streamJobOutputWS(w, r, job)
+	default:
+		common.WriteErrJSONResp(w, http.StatusNotFound, fmt.Sprintf("invalid job action: %s", action))
+	}
+}
+
+// cancelCmdJob cancels a running job.
+// DELETE /tools/jobs/:id
+func cancelCmdJob(w http.ResponseWriter, r *http.Request, job *cmdJob) {
+	writeToolsAudit(&toolsAuditEntry{
+		Time:       time.Now().Format(time.RFC3339),
+		RemoteAddr: r.RemoteAddr,
+		Tool:       "cancel",
+		Job:        job.id,
+		Status:     http.StatusOK,
+	})
+	job.cancel()
+	if err := common.WriteOKJSONResp(w, job.toJSON(false)); err != nil {
+		common.ErrHandler(w, err)
+	}
+}
+
+// streamJobOutput writes job output by chunked http until job done, or returns current output if follow=false.
+// GET /tools/jobs/:id/output?follow=true
+func streamJobOutput(w http.ResponseWriter, r *http.Request, job *cmdJob) {
+	follow, err := common.GetBoolArgFromQuery(r, "follow")
+	if err != nil {
+		follow = true
+	}
+
+	w.Header().Set(common.TextContentType, common.ContentTypeTEXT)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	offset := 0
+	for {
+		chunks, done, changed := job.readChunks(offset)
+		for _, chunk := range chunks {
+			if _, err := w.Write([]byte(chunk.Data)); err != nil {
+				log.Println("write job output failed:", err)
+				return
+			}
+		}
+		offset += len(chunks)
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if done || !follow {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// streamJobOutputWS sends job output chunks as json messages by websocket until job done.
+// GET /tools/jobs/:id/ws
+func streamJobOutputWS(w http.ResponseWriter, r *http.Request, job *cmdJob) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("websocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+
+	// reads client messages to detect close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	offset := 0
+	for {
+		chunks, done, changed := job.readChunks(offset)
+		for _, chunk := range chunks {
+			if err := conn.WriteJSON(&chunk); err != nil {
+				log.Println("write job output by websocket failed:", err)
+				return
+			}
+		}
+		offset += len(chunks)
+
+		if done {
+			conn.WriteJSON(job.toJSON(false))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+		select {
+		case <-changed:
+		case <-closed:
+			return
+		}
+	}
+}
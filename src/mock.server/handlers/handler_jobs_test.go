@@ -0,0 +1,159 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"src/mock.server/common"
+	"src/mock.server/handlers"
+)
+
+func createTestJob(t *testing.T, body string) string {
+	req := newToolsRequest(t, "cmd", body)
+	req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+	rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected returned code: %d, response: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp struct {
+		Data handlers.CmdRespJSON `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.JobID) == 0 {
+		t.Fatal("job id is empty:", rr.Body.String())
+	}
+	return resp.Data.JobID
+}
+
+func getTestJob(t *testing.T, method, url, route string) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+	rr := newRequestRecorder(req, method, route, handlers.ToolsJobsHandler)
+	return rr.Code, rr.Body.String()
+}
+
+func waitTestJobDone(t *testing.T, id string) *handlers.CmdJobJSON {
+	var resp struct {
+		Data handlers.CmdJobJSON `json:"data"`
+	}
+	for i := 0; i < 50; i++ {
+		code, body := getTestJob(t, "GET", "/tools/jobs/"+id, "/tools/jobs/:id")
+		if code != http.StatusOK {
+			t.Fatalf("Unexpected returned code: %d, response: %s", code, body)
+		}
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Data.Status != "running" {
+			return &resp.Data
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("job is not done:", id)
+	return nil
+}
+
+func TestToolsCmdJob(t *testing.T) {
+	setToolsConfigs(t)
+	common.RunConfigs.Tools.Commands = append(common.RunConfigs.Tools.Commands,
+		common.CmdTemplate{Name: "outerr", Template: "echo out; echo err 1>&2"})
+
+	t.Log("Case01: create async job and poll status.")
+	id := createTestJob(t, `{"name":"outerr","async":true}`)
+	job := waitTestJobDone(t, id)
+	if job.Status != "succeeded" {
+		t.Error("Unexpected job status:", job.Status)
+	}
+	if job.Stdout != "out\n" || job.Stderr != "err\n" {
+		t.Errorf("Unexpected job output: stdout=%q, stderr=%q", job.Stdout, job.Stderr)
+	}
+
+	t.Log("Case02: get job output by chunked http.")
+	code, body := getTestJob(t, "GET", "/tools/jobs/"+id+"/output", "/tools/jobs/:id/:action")
+	if code != http.StatusOK {
+		t.Error("Unexpected returned code:", code)
+	}
+	if !strings.Contains(body, "out\n") || !strings.Contains(body, "err\n") {
+		t.Errorf("Unexpected job output: %q", body)
+	}
+
+	t.Log("Case03: list jobs.")
+	code, body = getTestJob(t, "GET", "/tools/jobs", "/tools/jobs")
+	if code != http.StatusOK || !strings.Contains(body, id) {
+		t.Errorf("Unexpected jobs list: %d, %s", code, body)
+	}
+}
+
+func TestToolsCmdJobCancel(t *testing.T) {
+	setToolsConfigs(t)
+	common.RunConfigs.Tools.Commands = append(common.RunConfigs.Tools.Commands,
+		common.CmdTemplate{Name: "sleep", Template: "sleep 5", Timeout: 10})
+
+	t.Log("Case01: create job when running jobs reach max jobs.")
+	common.RunConfigs.Tools.MaxJobs = 1
+	id := createTestJob(t, `{"name":"sleep","async":true}`)
+	req := newToolsRequest(t, "cmd", `{"name":"sleep","async":true}`)
+	req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+	if rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Unexpected returned code: %d, response: %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case02: cancel a running job.")
+	if code, body := getTestJob(t, "DELETE", "/tools/jobs/"+id, "/tools/jobs/:id"); code != http.StatusOK {
+		t.Fatalf("Unexpected returned code: %d, response: %s", code, body)
+	}
+	if job := waitTestJobDone(t, id); job.Status != "cancelled" {
+		t.Error("Unexpected job status:", job.Status)
+	}
+
+	t.Log("Case03: get a not exist job.")
+	if code, _ := getTestJob(t, "GET", "/tools/jobs/not-exist", "/tools/jobs/:id"); code != http.StatusNotFound {
+		t.Error("Unexpected returned code:", code)
+	}
+}
+
+func TestToolsJobsSignature(t *testing.T) {
+	setToolsConfigs(t)
+	id := createTestJob(t, `{"name":"hello","async":true}`)
+	waitTestJobDone(t, id)
+
+	newSignedRequest := func(method, url, timestamp, sign string) *http.Request {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(handlers.ToolsTimestampHeader, timestamp)
+		req.Header.Set(handlers.ToolsSignHeader, "sha256="+sign)
+		return req
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	sign := handlers.SignToolsRequest("test-secret", "GET", "/tools/jobs", timestamp, nil)
+
+	t.Log("Case01: list jobs with signature of method and path.")
+	req := newSignedRequest("GET", "/tools/jobs", timestamp, sign)
+	if rr := newRequestRecorder(req, "GET", "/tools/jobs", handlers.ToolsJobsHandler); rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected returned code: %d, response: %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case02: signature of list jobs is not valid to replay, or for other jobs apis.")
+	req = newSignedRequest("GET", "/tools/jobs", timestamp, sign)
+	if rr := newRequestRecorder(req, "GET", "/tools/jobs", handlers.ToolsJobsHandler); rr.Code != http.StatusUnauthorized {
+		t.Error("Unexpected returned code of replayed signature:", rr.Code)
+	}
+	for _, method := range []string{"GET", "DELETE"} {
+		req = newSignedRequest(method, "/tools/jobs/"+id, timestamp, sign)
+		if rr := newRequestRecorder(req, method, "/tools/jobs/:id", handlers.ToolsJobsHandler); rr.Code != http.StatusUnauthorized {
+			t.Errorf("Unexpected returned code of %s job with signature of other api: %d", method, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"src/mock.server/common"
+	myutils "src/tools.app/utils"
+
+	"github.com/golib/httprouter"
+)
+
+/* Traffic mirror: requests are sent to primary and secondary upstreams, and primary response is returned. */
+
+// hop-by-hop headers are not forwarded.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// upstreamResponse response of an upstream.
+type upstreamResponse struct {
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// MirrorDiff diff report of a mirrored request.
+type MirrorDiff struct {
+	ID              int64             `json:"id"`
+	Time            string            `json:"time"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	PrimaryStatus   int               `json:"primary_status"`
+	SecondaryStatus int               `json:"secondary_status"`
+	Matched         bool              `json:"matched"`
+	Error           string            `json:"error,omitempty"`
+	Status          *common.JSONDiff  `json:"status,omitempty"`
+	Headers         []common.JSONDiff `json:"headers,omitempty"`
+	Body            []common.JSONDiff `json:"body,omitempty"`
+}
+
+// MirrorReport summary and recent diffs of mirrored requests.
+type MirrorReport struct {
+	Total   int64         `json:"total"`
+	Matched int64         `json:"matched"`
+	Diffed  int64         `json:"diffed"`
+	Failed  int64         `json:"failed"`
+	Diffs   []*MirrorDiff `json:"diffs"`
+}
+
+// mirrorReportStore keeps summary and last diffs of mirrored requests.
+type mirrorReportStore struct {
+	mutex  sync.Mutex
+	report MirrorReport
+}
+
+var mirrorReports = &mirrorReportStore{}
+
+func (s *mirrorReportStore) add(diff *MirrorDiff) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.report.Total++
+	diff.ID = s.report.Total
+	if len(diff.Error) > 0 {
+		s.report.Failed++
+	} else if diff.Matched {
+		s.report.Matched++
+		return
+	} else {
+		s.report.Diffed++
+	}
+
+	s.report.Diffs = append(s.report.Diffs, diff)
+	maxReports := common.RunConfigs.Mirror.MaxReports
+	if maxReports > 0 && len(s.report.Diffs) > maxReports {
+		s.report.Diffs = s.report.Diffs[len(s.report.Diffs)-maxReports:]
+	}
+}
+
+func (s *mirrorReportStore) get() MirrorReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	report := s.report
+	report.Diffs = make([]*MirrorDiff, len(s.report.Diffs))
+	copy(report.Diffs, s.report.Diffs)
+	return report
+}
+
+func (s *mirrorReportStore) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.report = MirrorReport{}
+}
+
+// MirrorHandler sends request to primary and secondary upstreams, returns primary response, and records diffs.
+// ANY /mirror/*path
+func MirrorHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	cfg := &common.RunConfigs.Mirror
+	if len(cfg.Primary) == 0 || len(cfg.Secondary) == 0 {
+		common.WriteErrJSONResp(w, http.StatusServiceUnavailable, "mirror upstreams are not configured")
+		return
+	}
+
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			common.ErrHandler(w, err)
+			return
+		}
+		body = b
+	}
+
+	uri := "/" + strings.TrimPrefix(params.ByName("path"), "/")
+	if len(r.URL.RawQuery) > 0 {
+		uri += "?" + r.URL.RawQuery
+	}
+	client := &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second}
+	primaryCh := make(chan *upstreamResponse, 1)
+	secondaryCh := make(chan *upstreamResponse, 1)
+	go func() {
+		primaryCh <- sendUpstreamRequest(client, r, cfg.Primary+uri, body)
+	}()
+	go func() {
+		secondaryCh <- sendUpstreamRequest(client, r, cfg.Secondary+uri, body)
+	}()
+
+	primary := <-primaryCh
+	// compare when secondary returned, and primary response is not blocked
+	go func() {
+		diff := diffMirrorResponses(primary, <-secondaryCh, cfg)
+		diff.Time = time.Now().Format(time.RFC3339)
+		diff.Method = r.Method
+		diff.Path = uri
+		if !diff.Matched {
+			log.Printf("mirror diff found for %s %s\n", r.Method, uri)
+		}
+		mirrorReports.add(diff)
+	}()
+
+	if primary.err != nil {
+		common.WriteErrJSONResp(w, http.StatusBadGateway, "primary upstream error: "+primary.err.Error())
+		return
+	}
+	for k, values := range primary.header {
+		if k == common.TextContentLength {
+			continue
+		}
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(primary.status)
+	if _, err := w.Write(primary.body); err != nil {
+		log.Println("write mirror response failed:", err)
+	}
+}
+
+func sendUpstreamRequest(client *http.Client, r *http.Request, url string, body []byte) *upstreamResponse {
+	req, err := http.NewRequest(r.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return &upstreamResponse{err: err}
+	}
+	req.Header = r.Header.Clone()
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	// body is forwarded as it is with its encoding, and response is decompressed by client
+	req.Header.Del(common.TextAcceptEncoding)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &upstreamResponse{err: err}
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &upstreamResponse{err: err}
+	}
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	return &upstreamResponse{status: resp.StatusCode, header: resp.Header, body: b}
+}
+
+// diffMirrorResponses compares status, headers and bodies (as json if both are valid json) of responses.
+func diffMirrorResponses(primary, secondary *upstreamResponse, cfg *common.MirrorConfigs) *MirrorDiff {
+	diff := &MirrorDiff{PrimaryStatus: primary.status, SecondaryStatus: secondary.status}
+	if primary.err != nil {
+		diff.Error = "primary: " + primary.err.Error()
+	}
+	if secondary.err != nil {
+		diff.Error = strings.TrimPrefix(diff.Error+"; secondary: "+secondary.err.Error(), "; ")
+	}
+	if len(diff.Error) > 0 {
+		return diff
+	}
+
+	if primary.status != secondary.status {
+		diff.Status = &common.JSONDiff{Path: "status", Type: common.DiffChanged, Left: primary.status, Right: secondary.status}
+	}
+	diff.Headers = diffMirrorHeaders(primary.header, secondary.header, cfg.IgnoreHeaders)
+
+	var left, right interface{}
+	if json.Unmarshal(primary.body, &left) == nil && json.Unmarshal(secondary.body, &right) == nil {
+		diff.Body = common.DiffJSON(left, right, cfg.IgnoreFields)
+	} else if !bytes.Equal(primary.body, secondary.body) {
+		diff.Body = []common.JSONDiff{{
+			Path:  "body",
+			Type:  common.DiffChanged,
+			Left:  fmt.Sprintf("md5=%s, size=%d", myutils.GetMd5HexText(string(primary.body)), len(primary.body)),
+			Right: fmt.Sprintf("md5=%s, size=%d", myutils.GetMd5HexText(string(secondary.body)), len(secondary.body)),
+		}}
+	}
+	diff.Matched = diff.Status == nil && len(diff.Headers) == 0 && len(diff.Body) == 0
+	return diff
+}
+
+func diffMirrorHeaders(left, right http.Header, ignores []string) []common.JSONDiff {
+	ignored := make(map[string]bool, len(ignores))
+	for _, h := range ignores {
+		ignored[http.CanonicalHeaderKey(h)] = true
+	}
+
+	keys := make([]string, 0, len(left)+len(right))
+	for k := range left {
+		keys = append(keys, k)
+	}
+	for k := range right {
+		if _, ok := left[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	diffs := make([]common.JSONDiff, 0)
+	for _, k := range keys {
+		if ignored[k] {
+			continue
+		}
+		lv, rv := strings.Join(left[k], ","), strings.Join(right[k], ",")
+		if _, ok := left[k]; !ok {
+			diffs = append(diffs, common.JSONDiff{Path: k, Type: common.DiffAdded, Right: rv})
+		} else if _, ok := right[k]; !ok {
+			diffs = append(diffs, common.JSONDiff{Path: k, Type: common.DiffRemoved, Left: lv})
+		} else if lv != rv {
+			diffs = append(diffs, common.JSONDiff{Path: k, Type: common.DiffChanged, Left: lv, Right: rv})
+		}
+	}
+	return diffs
+}
+
+// MirrorReportHandler returns or clears diff report of mirrored requests.
+// GET /mirrors/report
+// DELETE /mirrors/report
+func MirrorReportHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	switch r.Method {
+	case "GET":
+		report := mirrorReports.get()
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(report.Diffs) {
+			report.Diffs = report.Diffs[len(report.Diffs)-limit:]
+		}
+		common.WriteOKJSONResp(w, report)
+	case "DELETE":
+		mirrorReports.reset()
+		common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: "mirror report is cleared"})
+	default:
+		common.ErrHandler(w, fmt.Errorf("Method not support: %s", r.Method))
+	}
+}
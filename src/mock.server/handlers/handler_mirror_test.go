@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"src/mock.server/common"
+)
+
+func newUpstream(version string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(common.TextContentType, common.ContentTypeJSON)
+		w.Header().Set("X-Version", version)
+		fmt.Fprintf(w, `{"path":%q,"version":%q,"time":%d}`, r.URL.Path, version, time.Now().UnixNano())
+	}))
+}
+
+func getMirrorReport(t *testing.T) map[string]interface{} {
+	req, _ := http.NewRequest("GET", "/mirrors/report", nil)
+	rr := serveRouter(req)
+	resp := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data
+}
+
+func TestMirror(t *testing.T) {
+	primary, secondary := newUpstream("v1"), newUpstream("v2")
+	defer primary.Close()
+	defer secondary.Close()
+
+	setRunConfigs(t, func(configs *common.Configs) {
+		configs.Mirror = common.MirrorConfigs{
+			Primary:       primary.URL,
+			Secondary:     secondary.URL,
+			Timeout:       3,
+			IgnoreHeaders: []string{"Date", "Content-Length"},
+			IgnoreFields:  []string{"time"},
+			MaxReports:    10,
+		}
+	})
+	req, _ := http.NewRequest("DELETE", "/mirrors/report", nil)
+	serveRouter(req)
+
+	t.Log("Case01: primary response is returned.")
+	req, _ = http.NewRequest("GET", "/mirror/v1/users?id=1", nil)
+	rr := serveRouter(req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"version":"v1"`) {
+		t.Fatalf("Unexpected response: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case02: diffs of headers and body are recorded.")
+	var report map[string]interface{}
+	for i := 0; i < 50; i++ {
+		if report = getMirrorReport(t); report["total"].(float64) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	diffs, _ := report["diffs"].([]interface{})
+	if report["diffed"].(float64) != 1 || len(diffs) != 1 {
+		t.Fatalf("Unexpected report: %v", report)
+	}
+	diff := diffs[0].(map[string]interface{})
+	headers, _ := diff["headers"].([]interface{})
+	body, _ := diff["body"].([]interface{})
+	if len(headers) != 1 || len(body) != 1 || body[0].(map[string]interface{})["path"] != "version" {
+		t.Errorf("Unexpected diff: %v", diff)
+	}
+}
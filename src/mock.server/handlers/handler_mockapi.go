@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"path/filepath"
+	"strings"
 
 	"src/mock.server/common"
 	myutils "src/tools.app/utils"
@@ -64,14 +66,12 @@ func MockAPIRegisterHandler(w http.ResponseWriter, r *http.Request, params httpr
 // MockAPIHandler sends templated json response by register params and body.
 // Post /mock/:uri
 func MockAPIHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	w.Header().Set(common.TextContentType, common.ContentTypeJSON)
-	if err := common.MockReturnCode(r, w); err != nil {
-		common.ErrHandler(w, err)
-		return
-	}
-
 	uri := params.ByName(uriName)
 	filePath := fmt.Sprintf(bodyFilePathPattern, dataDirPath, uri)
+	if exist, _ := myutils.IsFileExist(filePath); !exist {
+		MockNotFound(w, r, params)
+		return
+	}
 	body, err := myutils.ReadFileContentBuf(filePath)
 	if err != nil {
 		common.ErrHandler(w, err)
@@ -90,10 +90,14 @@ func MockAPIHandler(w http.ResponseWriter, r *http.Request, params httprouter.Pa
 	for k, v := range r.URL.Query() {
 		queryMap[k] = v
 	}
-	if len(queryMap) == 0 {
-		if _, err := w.Write([]byte(body)); err != nil {
-			common.ErrHandler(w, err)
+
+	uploads, form, err := receiveUploads(uri, r)
+	if err != nil {
+		if _, ok := err.(*uploadMismatchError); ok {
+			common.WriteErrJSONResp(w, http.StatusBadRequest, err.Error())
+			return
 		}
+		common.ErrHandler(w, err)
 		return
 	}
 
@@ -104,12 +108,107 @@ func MockAPIHandler(w http.ResponseWriter, r *http.Request, params httprouter.Pa
 		return
 	}
 
-	tmpl, err := template.New("mockapi").Parse(string(body))
+	// upload meta, like {{range .uploads}}{{.Filename}}:{{.Size}}:{{.MD5}}{{end}}
+	tmplData := make(map[string]interface{}, len(tmplParams)+2)
+	for k, v := range tmplParams {
+		tmplData[k] = v
+	}
+	if uploads != nil {
+		tmplData["uploads"] = uploads
+		tmplData["form"] = form
+	}
+	funcs := newTemplateFuncMap(uri, r)
+
+	// status, headers, cookies and trailers by registered response spec
+	var resp *renderedResponse
+	spec, err := loadResponseSpec(uri)
 	if err != nil {
 		common.ErrHandler(w, err)
 		return
 	}
-	if err := tmpl.Execute(w, tmplParams); err != nil {
-		log.Fatalln(err)
+	if spec != nil {
+		if resp, err = spec.render(tmplData, funcs); err != nil {
+			common.ErrHandler(w, err)
+			return
+		}
+	}
+
+	// body without params and template actions (like {{count}}) is returned as it is, and template
+	// is executed before status is written, so error (like counter backend is down) is returned as 500
+	out := body
+	if len(queryMap) > 0 || strings.Contains(string(body), "{{") {
+		tmpl, err := template.New("mockapi").Funcs(funcs).Parse(string(body))
+		if err != nil {
+			common.ErrHandler(w, err)
+			return
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, tmplData); err != nil {
+			log.Println("execute mock api template failed:", err)
+			common.ErrHandler(w, err)
+			return
+		}
+		out = buf.Bytes()
+	}
+
+	// response encoding of stub by registered or current query args
+	common.SetResponseEncoding(w, getFirstValue(queryMap, common.EncodingQueryArg), getFirstValue(queryMap, common.EncodingModeQueryArg))
+	w.Header().Set(common.TextContentType, common.ContentTypeJSON)
+	if resp != nil {
+		resp.writeHeader(w)
+		defer resp.writeTrailer(w)
+	}
+	// "code" query arg takes precedence over status of response spec
+	if _, ok := r.URL.Query()["code"]; resp != nil && resp.status > 0 && !ok {
+		w.WriteHeader(resp.status)
+	} else if err := common.MockReturnCode(r, w); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+
+	if _, err := w.Write(out); err != nil {
+		common.ErrHandler(w, err)
+	}
+}
+
+// newTemplateFuncMap returns template funcs: "count" increases and returns access counter of uri,
+// "scenario" returns state of a scenario, "setScenario" changes state of a scenario,
+// and "header", "cookie", "method" and "path" return values of request.
+func newTemplateFuncMap(uri string, r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"scenario": scenarios.get,
+		"setScenario": func(name, state string) string {
+			scenarios.set(name, state)
+			return ""
+		},
+		"count": func() (int64, error) {
+			counter, err := common.GetCounter()
+			if err != nil {
+				return 0, err
+			}
+			return counter.Incr("mockapi:" + uri)
+		},
+		"header": func(key string) string {
+			return r.Header.Get(key)
+		},
+		"cookie": func(name string) string {
+			if c, err := r.Cookie(name); err == nil {
+				return c.Value
+			}
+			return ""
+		},
+		"method": func() string {
+			return r.Method
+		},
+		"path": func() string {
+			return r.URL.Path
+		},
+	}
+}
+
+func getFirstValue(values map[string][]string, key string) string {
+	if val, ok := values[key]; ok && len(val) > 0 {
+		return val[0]
 	}
+	return ""
 }
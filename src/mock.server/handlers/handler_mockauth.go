@@ -0,0 +1,537 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"src/mock.server/common"
+
+	"github.com/golib/httprouter"
+)
+
+const (
+	authTypeBasic   = "basic"
+	authTypeBearer  = "bearer"
+	authTypeSession = "session"
+
+	sessionCookieName = "mock_session"
+	defaultAuthUser   = "mock_user"
+)
+
+/* OAuth2 / OIDC */
+
+// MockOIDCConfigHandler sends openid provider configuration.
+// GET /.well-known/openid-configuration
+func MockOIDCConfigHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	issuer := common.RunConfigs.Auth.Issuer
+	writeAuthJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"userinfo_endpoint":                     issuer + "/oauth/userinfo",
+		"jwks_uri":                              issuer + "/oauth/jwks",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials", "password"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+// MockOAuthHandler router for mock oauth2 handlers.
+func MockOAuthHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	if r.Method == "GET" {
+		switch name {
+		case "jwks":
+			mockOAuthJWKS(w, r)
+		case "authorize":
+			mockOAuthAuthorize(w, r)
+		case "userinfo":
+			mockOAuthUserInfo(w, r)
+		default:
+			common.ErrHandler(w, fmt.Errorf("GET for invalid path: %s", r.URL.Path))
+		}
+	} else if r.Method == "POST" {
+		switch name {
+		case "token":
+			mockOAuthToken(w, r)
+		default:
+			common.ErrHandler(w, fmt.Errorf("POST for invalid path: %s", r.URL.Path))
+		}
+	} else {
+		common.ErrHandler(w, fmt.Errorf("Method not support: %s", r.Method))
+	}
+}
+
+// mockOAuthJWKS sends public keys to verify issued jwt.
+// GET /oauth/jwks
+func mockOAuthJWKS(w http.ResponseWriter, r *http.Request) {
+	signer, err := common.GetJWTSigner()
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	writeAuthJSON(w, http.StatusOK, map[string]interface{}{"keys": signer.JWKS()})
+}
+
+// authCode an issued authorization code.
+type authCode struct {
+	clientID string
+	user     string
+	scope    string
+	nonce    string
+	expireAt time.Time
+}
+
+var (
+	authCodes     = make(map[string]*authCode)
+	authCodesLock sync.Mutex
+)
+
+// purgeExpiredAuthCodes removes expired codes which are never used, and authCodesLock must be held.
+func purgeExpiredAuthCodes() {
+	now := time.Now()
+	for code, c := range authCodes {
+		if now.After(c.expireAt) {
+			delete(authCodes, code)
+		}
+	}
+}
+
+// mockOAuthAuthorize approves authorization request of user "login_hint" (or session user), and redirects with code.
+// GET /oauth/authorize?response_type=code&client_id=xxx&redirect_uri=xxx&state=xxx&scope=openid&login_hint=xxx
+func mockOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || len(redirectURI.String()) == 0 {
+		writeOAuthErr(w, http.StatusBadRequest, "invalid_request", "redirect_uri is required")
+		return
+	}
+
+	user := query.Get("login_hint")
+	if sess, ok := getSession(r); ok && len(user) == 0 {
+		user = sess.user
+	}
+	if len(user) == 0 {
+		user = defaultAuthUser
+	}
+
+	code := newRandomID()
+	authCodesLock.Lock()
+	purgeExpiredAuthCodes()
+	authCodes[code] = &authCode{
+		clientID: query.Get("client_id"),
+		user:     user,
+		scope:    query.Get("scope"),
+		nonce:    query.Get("nonce"),
+		expireAt: time.Now().Add(time.Minute),
+	}
+	authCodesLock.Unlock()
+
+	values := redirectURI.Query()
+	values.Set("code", code)
+	if state := query.Get("state"); len(state) > 0 {
+		values.Set("state", state)
+	}
+	redirectURI.RawQuery = values.Encode()
+	log.Println("mock authorize redirect to:", redirectURI.String())
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// mockOAuthToken issues signed jwt by grant type client_credentials, password or authorization_code.
+// Optional form args: scope, expires_in (seconds), claims (json object of extra claims).
+// POST /oauth/token
+func mockOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthErr(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostFormValue("client_id")
+		clientSecret = r.PostFormValue("client_secret")
+	}
+
+	var (
+		sub   string
+		scope = r.PostFormValue("scope")
+		nonce string
+	)
+	switch grantType := r.PostFormValue("grant_type"); grantType {
+	case "client_credentials":
+		if !checkCredential(common.RunConfigs.Auth.Clients, clientID, clientSecret) {
+			writeOAuthErr(w, http.StatusUnauthorized, "invalid_client", "invalid client credentials")
+			return
+		}
+		sub = clientID
+	case "password":
+		user := r.PostFormValue("username")
+		if !checkCredential(common.RunConfigs.Auth.Users, user, r.PostFormValue("password")) {
+			writeOAuthErr(w, http.StatusBadRequest, "invalid_grant", "invalid user credentials")
+			return
+		}
+		sub = user
+	case "authorization_code":
+		code := r.PostFormValue("code")
+		authCodesLock.Lock()
+		c, ok := authCodes[code]
+		delete(authCodes, code)
+		authCodesLock.Unlock()
+		if !ok || time.Now().After(c.expireAt) || (len(c.clientID) > 0 && c.clientID != clientID) {
+			writeOAuthErr(w, http.StatusBadRequest, "invalid_grant", "invalid or expired code")
+			return
+		}
+		sub, nonce = c.user, c.nonce
+		if len(scope) == 0 {
+			scope = c.scope
+		}
+	default:
+		writeOAuthErr(w, http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("grant type not support: %s", grantType))
+		return
+	}
+
+	expiresIn := common.RunConfigs.Auth.TokenExpiry
+	if val, err := strconv.Atoi(r.PostFormValue("expires_in")); err == nil {
+		expiresIn = val
+	}
+	claims, err := newTokenClaims(sub, clientID, scope, expiresIn, r.PostFormValue("claims"))
+	if err != nil {
+		writeOAuthErr(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	signer, err := common.GetJWTSigner()
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	accessToken, err := signer.Sign(claims)
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	ret := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   expiresIn,
+		"scope":        scope,
+	}
+
+	if hasScope(scope, "openid") {
+		if len(nonce) > 0 {
+			claims["nonce"] = nonce
+		}
+		idToken, err := signer.Sign(claims)
+		if err != nil {
+			common.ErrHandler(w, err)
+			return
+		}
+		ret["id_token"] = idToken
+	}
+	writeAuthJSON(w, http.StatusOK, ret)
+}
+
+// newTokenClaims returns standard claims merged with configured and request claims.
+func newTokenClaims(sub, clientID, scope string, expiresIn int, extra string) (map[string]interface{}, error) {
+	now := time.Now().Unix()
+	claims := map[string]interface{}{
+		"iss": common.RunConfigs.Auth.Issuer,
+		"sub": sub,
+		"iat": now,
+		"exp": now + int64(expiresIn),
+	}
+	if len(clientID) > 0 {
+		claims["aud"] = clientID
+	}
+	if len(scope) > 0 {
+		claims["scope"] = scope
+	}
+	for k, v := range common.RunConfigs.Auth.Claims {
+		claims[k] = v
+	}
+
+	if len(extra) > 0 {
+		extraClaims := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(extra), &extraClaims); err != nil {
+			return nil, fmt.Errorf("invalid claims json: %v", err)
+		}
+		for k, v := range extraClaims {
+			claims[k] = v
+		}
+	}
+	return claims, nil
+}
+
+// mockOAuthUserInfo sends claims of bearer token.
+// GET /oauth/userinfo
+func mockOAuthUserInfo(w http.ResponseWriter, r *http.Request) {
+	claims, err := verifyBearerToken(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthErr(w, http.StatusUnauthorized, "invalid_token", err.Error())
+		return
+	}
+	writeAuthJSON(w, http.StatusOK, claims)
+}
+
+func verifyBearerToken(r *http.Request) (map[string]interface{}, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, fmt.Errorf("bearer token is required")
+	}
+	signer, err := common.GetJWTSigner()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Verify(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+}
+
+// checkCredential returns true if name and secret matched, or credentials are not configured.
+func checkCredential(credentials map[string]string, name, secret string) bool {
+	if len(credentials) == 0 {
+		return len(name) > 0
+	}
+	expected, ok := credentials[name]
+	return ok && subtle.ConstantTimeCompare([]byte(expected), []byte(secret)) == 1
+}
+
+func hasScope(scope, target string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
+
+func writeAuthJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set(common.TextContentType, common.ContentTypeJSON)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Println("write auth json failed:", err)
+	}
+}
+
+func writeOAuthErr(w http.ResponseWriter, code int, errType, desc string) {
+	writeAuthJSON(w, code, map[string]string{"error": errType, "error_description": desc})
+}
+
+func newRandomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+/* Cookie Session */
+
+type authSession struct {
+	user     string
+	expireAt time.Time
+}
+
+var (
+	sessions     = make(map[string]*authSession)
+	sessionsLock sync.Mutex
+)
+
+// purgeExpiredSessions removes expired sessions which are never used, and sessionsLock must be held.
+func purgeExpiredSessions() {
+	now := time.Now()
+	for id, sess := range sessions {
+		if now.After(sess.expireAt) {
+			delete(sessions, id)
+		}
+	}
+}
+
+func getSession(r *http.Request) (*authSession, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	sess, ok := sessions[cookie.Value]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(sess.expireAt) {
+		delete(sessions, cookie.Value)
+		return nil, false
+	}
+	return sess, true
+}
+
+// MockSessionHandler router for mock cookie session handlers.
+func MockSessionHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	action := params.ByName("action")
+	if r.Method == "GET" && action == "info" {
+		mockSessionInfo(w, r)
+	} else if r.Method == "POST" && action == "login" {
+		mockSessionLogin(w, r)
+	} else if r.Method == "POST" && action == "logout" {
+		mockSessionLogout(w, r)
+	} else {
+		common.ErrHandler(w, fmt.Errorf("%s for invalid path: %s", r.Method, r.URL.Path))
+	}
+}
+
+// mockSessionLogin creates a session by form (or cookies as /demo/4) "user" and "pwd", and sets session cookie.
+// POST /session/login
+func mockSessionLogin(w http.ResponseWriter, r *http.Request) {
+	user, pwd := r.PostFormValue("user"), r.PostFormValue("pwd")
+	if len(user) == 0 {
+		if cookie, err := r.Cookie("user"); err == nil {
+			user = cookie.Value
+		}
+		if cookie, err := r.Cookie("pwd"); err == nil {
+			pwd = cookie.Value
+		}
+	}
+	if !checkCredential(common.RunConfigs.Auth.Users, user, pwd) {
+		common.WriteErrJSONResp(w, http.StatusUnauthorized, "invalid user credentials")
+		return
+	}
+
+	id := newRandomID()
+	expiry := common.RunConfigs.Auth.TokenExpiry
+	sessionsLock.Lock()
+	purgeExpiredSessions()
+	sessions[id] = &authSession{user: user, expireAt: time.Now().Add(time.Duration(expiry) * time.Second)}
+	sessionsLock.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   expiry,
+		HttpOnly: true,
+	})
+	if err := common.WriteOKJSONResp(w, map[string]string{"user": user}); err != nil {
+		common.ErrHandler(w, err)
+	}
+}
+
+// mockSessionLogout removes session and clears session cookie.
+// POST /session/logout
+func mockSessionLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		sessionsLock.Lock()
+		delete(sessions, cookie.Value)
+		sessionsLock.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Value: "", Path: "/", MaxAge: -1})
+	if err := common.WriteOKJSONResp(w, map[string]string{"message": "logout"}); err != nil {
+		common.ErrHandler(w, err)
+	}
+}
+
+// mockSessionInfo sends user of current session.
+// GET /session/info
+func mockSessionInfo(w http.ResponseWriter, r *http.Request) {
+	sess, ok := getSession(r)
+	if !ok {
+		common.WriteErrJSONResp(w, http.StatusUnauthorized, "session not found")
+		return
+	}
+	ret := map[string]string{"user": sess.user, "expire_at": sess.expireAt.Format(time.RFC3339)}
+	if err := common.WriteOKJSONResp(w, ret); err != nil {
+		common.ErrHandler(w, err)
+	}
+}
+
+/* Auth Rules */
+
+// CheckAuthRules checks request by the first auth rule which path matched, and writes 401/403
+// response and returns false if failed.
+func CheckAuthRules(w http.ResponseWriter, r *http.Request) bool {
+	for _, rule := range common.RunConfigs.Auth.Rules {
+		if len(rule.Path) == 0 || !strings.HasPrefix(r.URL.Path, rule.Path) {
+			continue
+		}
+
+		user, scope, err := authenticate(&rule, r)
+		if err != nil {
+			log.Printf("auth failed for %s: %v\n", r.URL.Path, err)
+			switch rule.Type {
+			case authTypeBasic:
+				w.Header().Set("WWW-Authenticate", `Basic realm="mock"`)
+			case authTypeBearer:
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			}
+			common.WriteErrJSONResp(w, http.StatusUnauthorized, err.Error())
+			return false
+		}
+		if err := authorize(&rule, user, scope); err != nil {
+			log.Printf("auth forbidden for %s: %v\n", r.URL.Path, err)
+			common.WriteErrJSONResp(w, http.StatusForbidden, err.Error())
+			return false
+		}
+		return true
+	}
+	return true
+}
+
+// authenticate returns user and scope of request by auth type of rule.
+func authenticate(rule *common.AuthRule, r *http.Request) (string, string, error) {
+	switch rule.Type {
+	case authTypeBasic:
+		user, pwd, ok := r.BasicAuth()
+		if !ok || !checkCredential(common.RunConfigs.Auth.Users, user, pwd) {
+			return "", "", fmt.Errorf("invalid basic auth")
+		}
+		return user, "", nil
+	case authTypeBearer:
+		claims, err := verifyBearerToken(r)
+		if err != nil {
+			return "", "", err
+		}
+		sub, _ := claims["sub"].(string)
+		scope, _ := claims["scope"].(string)
+		return sub, scope, nil
+	case authTypeSession:
+		sess, ok := getSession(r)
+		if !ok {
+			return "", "", fmt.Errorf("session not found")
+		}
+		return sess.user, "", nil
+	default:
+		return "", "", fmt.Errorf("invalid auth type of rule: %s", rule.Type)
+	}
+}
+
+func authorize(rule *common.AuthRule, user, scope string) error {
+	if len(rule.Users) > 0 {
+		allowed := false
+		for _, u := range rule.Users {
+			if u == user {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("user is not allowed: %s", user)
+		}
+	}
+	for _, s := range rule.Scopes {
+		if !hasScope(scope, s) {
+			return fmt.Errorf("scope is required: %s", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"src/mock.server/common"
+)
+
+func setAuthConfigs(t *testing.T, rule common.AuthRule) {
+	setRunConfigs(t, func(configs *common.Configs) {
+		configs.Auth = common.AuthConfigs{
+			Issuer:      "http://127.0.0.1:17891",
+			TokenExpiry: 60,
+			Claims:      map[string]interface{}{"tenant": "mock"},
+			Clients:     map[string]string{"client01": "secret01"},
+			Users:       map[string]string{"user01": "pwd01", "user02": "pwd02"},
+			Rules:       []common.AuthRule{rule},
+		}
+	})
+}
+
+func getTestToken(t *testing.T, form url.Values) string {
+	req, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(common.TextContentType, common.ContentTypeForm)
+	rr := serveRouter(req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected returned code: %d, response: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp["access_token"].(string)
+}
+
+func TestMockOAuthBearer(t *testing.T) {
+	setAuthConfigs(t, common.AuthRule{Path: "/ping", Type: "bearer", Scopes: []string{"ping"}})
+
+	t.Log("Case01: get token with invalid client.")
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"client01"}, "client_secret": {"invalid"}}
+	req, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(common.TextContentType, common.ContentTypeForm)
+	if rr := serveRouter(req); rr.Code != http.StatusUnauthorized {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case02: access protected path without token.")
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	if rr := serveRouter(req); rr.Code != http.StatusUnauthorized {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case03: access protected path with token without required scope.")
+	form.Set("client_secret", "secret01")
+	token := getTestToken(t, form)
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if rr := serveRouter(req); rr.Code != http.StatusForbidden {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case04: access protected path with token of required scope.")
+	form.Set("scope", "ping")
+	token = getTestToken(t, form)
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case05: get user info with configured and request claims.")
+	form = url.Values{"grant_type": {"password"}, "username": {"user01"}, "password": {"pwd01"}, "claims": {`{"role":"admin"}`}}
+	token = getTestToken(t, form)
+	req, _ = http.NewRequest("GET", "/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := serveRouter(req)
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, `"tenant":"mock"`) || !strings.Contains(body, `"role":"admin"`) {
+		t.Errorf("Unexpected user info: %d, %s", rr.Code, body)
+	}
+}
+
+func TestMockOAuthAuthorizationCode(t *testing.T) {
+	setAuthConfigs(t, common.AuthRule{})
+
+	t.Log("Case01: authorize and exchange code for id token.")
+	req, _ := http.NewRequest("GET", "/oauth/authorize?response_type=code&client_id=client01&scope=openid&state=s1&login_hint=user02&redirect_uri="+
+		url.QueryEscape("http://localhost/callback"), nil)
+	rr := serveRouter(req)
+	if rr.Code != http.StatusFound {
+		t.Fatal("Unexpected returned code:", rr.Code)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Query().Get("state") != "s1" {
+		t.Error("Unexpected redirect location:", location)
+	}
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")},
+		"client_id": {"client01"}, "client_secret": {"secret01"}}
+	req, _ = http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(common.TextContentType, common.ContentTypeForm)
+	rr = serveRouter(req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "id_token") {
+		t.Errorf("Unexpected token response: %d, %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMockAuthBasicAndSession(t *testing.T) {
+	setAuthConfigs(t, common.AuthRule{Path: "/ping", Type: "basic", Users: []string{"user01"}})
+
+	t.Log("Case01: basic auth with invalid password.")
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	req.SetBasicAuth("user01", "invalid")
+	rr := serveRouter(req)
+	if rr.Code != http.StatusUnauthorized || len(rr.Header().Get("WWW-Authenticate")) == 0 {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case02: basic auth with not allowed user.")
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	req.SetBasicAuth("user02", "pwd02")
+	if rr := serveRouter(req); rr.Code != http.StatusForbidden {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case03: access by session cookie.")
+	common.RunConfigs.Auth.Rules[0].Type = "session"
+	req, _ = http.NewRequest("POST", "/session/login", strings.NewReader("user=user01&pwd=pwd01"))
+	req.Header.Set(common.TextContentType, common.ContentTypeForm)
+	rr = serveRouter(req)
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusOK || len(cookies) == 0 {
+		t.Fatalf("Unexpected login response: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	if rr := serveRouter(req); rr.Code != http.StatusUnauthorized {
+		t.Error("Unexpected returned code without session:", rr.Code)
+	}
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	req.AddCookie(cookies[0])
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Error("Unexpected returned code with session:", rr.Code)
+	}
+}
@@ -8,12 +8,10 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strings"
 
 	"src/mock.server/common"
 
 	"github.com/golib/httprouter"
-	redis "gopkg.in/redis.v5"
 )
 
 // MockDemoHandler router for mock demo handlers.
@@ -199,58 +197,22 @@ func printCookie(c *http.Cookie) {
 	log.Println("expires:", c.Expires)
 }
 
-// demo, get total access count from redis.
+// demo, get total access count from counter backend (memory, file or redis).
 // redis env: docker run --name redis -p 6379:6379 --rm -d redis:4.0
 // GET /demo/5
 func mockDemo05(w http.ResponseWriter, r *http.Request) {
-	if len(common.RunConfigs.Server.RedisURI) == 0 {
-		common.ErrHandler(w, fmt.Errorf("config redis uri is empty"))
+	log.Println("Counter backend:", common.RunConfigs.Server.Counter)
+	counter, err := common.GetCounter()
+	if err != nil {
+		common.ErrHandler(w, err)
 		return
 	}
 
-	log.Println("Redis server:", common.RunConfigs.Server.RedisURI)
-	accessCount, err := getAccessCountFromRedis()
+	accessCount, err := counter.Incr("server_total_access")
 	if err != nil {
 		common.ErrHandler(w, err)
 		return
 	}
 	log.Println("*Total Access:", accessCount)
-	common.WriteOKHTMLResp(w, []byte(fmt.Sprintln("Total Access: "+accessCount)))
-}
-
-func getAccessCountFromRedis() (string, error) {
-	errNum := "-1"
-	options := redis.Options{
-		Addr:     common.RunConfigs.Server.RedisURI,
-		Password: "",
-	}
-
-	client := redis.NewClient(&options)
-	defer client.Close()
-
-	pong, err := client.Ping().Result()
-	if err != nil {
-		return errNum, err
-	}
-	log.Println(pong)
-
-	const key = "server_total_access"
-	total, err := client.Get(key).Result()
-	if err != nil {
-		if strings.Contains(err.Error(), "nil") {
-			if err := client.Set(key, 1, 0).Err(); err != nil {
-				return errNum, err
-			}
-			total = "1"
-		} else {
-			return errNum, err
-		}
-	}
-
-	err = client.Incr(key).Err()
-	if err != nil {
-		return "-1", err
-	}
-
-	return total, nil
+	common.WriteOKHTMLResp(w, []byte(fmt.Sprintf("Total Access: %d\n", accessCount)))
 }
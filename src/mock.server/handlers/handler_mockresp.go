@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"src/mock.server/common"
+	myutils "src/tools.app/utils"
+
+	"github.com/golib/httprouter"
+)
+
+/*
+Response spec: templated status, headers, cookies and trailers of a registered mock api.
+
+  {
+    "status": "{{if .id}}200{{else}}404{{end}}",
+    "headers": {"X-Request-Id": "{{header \"X-Request-Id\"}}", "Link": ["</a>; rel=next", "</b>; rel=last"]},
+    "cookies": [{"name": "session", "value": "{{.user}}", "path": "/", "max_age": 3600, "http_only": true}],
+    "trailers": {"X-Checksum": "{{.id}}"}
+  }
+*/
+
+const responseSpecFilePathPattern = "%s/%s_response.json"
+
+// templateText a template text, and json number is accepted as text.
+type templateText string
+
+func (t *templateText) UnmarshalJSON(b []byte) error {
+	var text string
+	if err := json.Unmarshal(b, &text); err == nil {
+		*t = templateText(text)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return fmt.Errorf("template text should be string or number: %s", string(b))
+	}
+	*t = templateText(num.String())
+	return nil
+}
+
+// templateValues template texts of a header, and a single string is accepted.
+type templateValues []templateText
+
+func (v *templateValues) UnmarshalJSON(b []byte) error {
+	var text templateText
+	if err := json.Unmarshal(b, &text); err == nil {
+		*v = templateValues{text}
+		return nil
+	}
+	var texts []templateText
+	if err := json.Unmarshal(b, &texts); err != nil {
+		return fmt.Errorf("header values should be string or array: %s", string(b))
+	}
+	*v = texts
+	return nil
+}
+
+// ResponseCookie a templated Set-Cookie of stub response.
+type ResponseCookie struct {
+	Name   string       `json:"name"`
+	Value  templateText `json:"value"`
+	Path   string       `json:"path"`
+	Domain string       `json:"domain"`
+	// MaxAge in seconds, and cookie is deleted if negative.
+	MaxAge   int  `json:"max_age"`
+	Secure   bool `json:"secure"`
+	HTTPOnly bool `json:"http_only"`
+	// SameSite lax, strict or none.
+	SameSite string `json:"same_site"`
+}
+
+// ResponseSpec templated status, headers, cookies and trailers of a registered mock api.
+type ResponseSpec struct {
+	Status   templateText              `json:"status"`
+	Headers  map[string]templateValues `json:"headers"`
+	Cookies  []ResponseCookie          `json:"cookies"`
+	Trailers map[string]templateValues `json:"trailers"`
+}
+
+// renderedResponse status, headers and trailers rendered from response spec.
+type renderedResponse struct {
+	status   int
+	headers  http.Header
+	trailers http.Header
+}
+
+// MockResponseRegisterHandler registers response spec (json) of a mock api.
+// POST /mock/register/:uri/response
+func MockResponseRegisterHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+
+	spec := &ResponseSpec{}
+	if err := json.Unmarshal(body, spec); err != nil {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, "invalid response spec: "+err.Error())
+		return
+	}
+	if err := spec.validate(); err != nil {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, "invalid response spec: "+err.Error())
+		return
+	}
+
+	if err := myutils.MakeDir(dataDirPath); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	uri := params.ByName(uriName)
+	filePath := fmt.Sprintf(responseSpecFilePathPattern, dataDirPath, uri)
+	if err := myutils.WriteContentToFile(filePath, string(body), true); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+
+	respJSON := CmdRespJSON{
+		Status:  http.StatusOK,
+		Message: fmt.Sprintf("register response spec success: %s", uri),
+		Results: string(body),
+	}
+	common.WriteOKJSONResp(w, respJSON)
+}
+
+func loadResponseSpec(uri string) (*ResponseSpec, error) {
+	filePath := fmt.Sprintf(responseSpecFilePathPattern, dataDirPath, uri)
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	spec := &ResponseSpec{}
+	if err := json.Unmarshal(b, spec); err != nil {
+		return nil, fmt.Errorf("invalid response spec file (%s): %v", filePath, err)
+	}
+	return spec, nil
+}
+
+// validate checks templates can be parsed, and cookies are named.
+func (spec *ResponseSpec) validate() error {
+	texts := []templateText{spec.Status}
+	for _, values := range spec.Headers {
+		texts = append(texts, values...)
+	}
+	for _, values := range spec.Trailers {
+		texts = append(texts, values...)
+	}
+	for _, cookie := range spec.Cookies {
+		if len(cookie.Name) == 0 {
+			return fmt.Errorf("cookie name is required")
+		}
+		if _, err := parseSameSite(cookie.SameSite); err != nil {
+			return err
+		}
+		texts = append(texts, cookie.Value)
+	}
+
+	funcs := newTemplateFuncMap("", nil)
+	for _, text := range texts {
+		if _, err := texttemplate.New("validate").Funcs(texttemplate.FuncMap(funcs)).Parse(string(text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render executes templates of spec with data of request. Header and cookie values are not html escaped.
+func (spec *ResponseSpec) render(data map[string]interface{}, funcs template.FuncMap) (*renderedResponse, error) {
+	exec := func(text templateText) (string, error) {
+		if !strings.Contains(string(text), "{{") {
+			return string(text), nil
+		}
+		tmpl, err := texttemplate.New("response").Funcs(texttemplate.FuncMap(funcs)).Parse(string(text))
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	execHeader := func(values map[string]templateValues) (http.Header, error) {
+		header := make(http.Header, len(values))
+		for key, texts := range values {
+			for _, text := range texts {
+				value, err := exec(text)
+				if err != nil {
+					return nil, err
+				}
+				header.Add(key, value)
+			}
+		}
+		return header, nil
+	}
+
+	resp := &renderedResponse{}
+	status, err := exec(spec.Status)
+	if err != nil {
+		return nil, err
+	}
+	if status = strings.TrimSpace(status); len(status) > 0 {
+		if resp.status, err = strconv.Atoi(status); err != nil || resp.status < 100 || resp.status > 999 {
+			return nil, fmt.Errorf("invalid response status: %s", status)
+		}
+	}
+
+	if resp.headers, err = execHeader(spec.Headers); err != nil {
+		return nil, err
+	}
+	for _, c := range spec.Cookies {
+		value, err := exec(c.Value)
+		if err != nil {
+			return nil, err
+		}
+		sameSite, _ := parseSameSite(c.SameSite)
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			MaxAge:   c.MaxAge,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+			SameSite: sameSite,
+		}
+		if v := cookie.String(); len(v) > 0 {
+			resp.headers.Add("Set-Cookie", v)
+		}
+	}
+	if resp.trailers, err = execHeader(spec.Trailers); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// writeHeader sets headers and declares trailers. Headers of spec replace default headers (like Content-Type).
+func (resp *renderedResponse) writeHeader(w http.ResponseWriter) {
+	header := w.Header()
+	for key, values := range resp.headers {
+		if key != "Set-Cookie" {
+			header.Del(key)
+		}
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	for key := range resp.trailers {
+		header.Add("Trailer", key)
+	}
+}
+
+// writeTrailer sets trailers after body is written.
+func (resp *renderedResponse) writeTrailer(w http.ResponseWriter) {
+	header := w.Header()
+	for key, values := range resp.trailers {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+}
+
+func parseSameSite(text string) (http.SameSite, error) {
+	switch strings.ToLower(text) {
+	case "":
+		// zero value, and SameSite attribute is not set
+		return 0, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("invalid cookie same_site: %s", text)
+	}
+}
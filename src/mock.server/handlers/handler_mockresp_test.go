@@ -0,0 +1,103 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMockResponseSpec(t *testing.T) {
+	const uri = "response_test"
+	req, _ := http.NewRequest("POST", "/mock/register/"+uri+"?id=1", strings.NewReader(`{"id":"{{.id}}"}`))
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Register mock api failed: %d", rr.Code)
+	}
+
+	t.Log("Case01: invalid response spec.")
+	for _, spec := range []string{
+		`{"status":"{{if}}"}`,
+		`{"cookies":[{"value":"x"}]}`,
+		`{"cookies":[{"name":"a","same_site":"unknown"}]}`,
+		`{"headers":{"X-Test":{"a":"b"}}}`,
+	} {
+		req, _ = http.NewRequest("POST", "/mock/register/"+uri+"/response", strings.NewReader(spec))
+		if rr := serveRouter(req); rr.Code != http.StatusBadRequest {
+			t.Errorf("Want bad request for spec %s, got: %d", spec, rr.Code)
+		}
+	}
+
+	spec := `{
+  "status": "{{if eq .id \"404\"}}404{{else}}201{{end}}",
+  "headers": {
+    "Content-Type": "application/vnd.test+json",
+    "X-Request-Id": "{{header \"X-Request-Id\"}}",
+    "Link": ["</items?page=2>; rel=next", "</items?page={{.id}}>; rel=last"]
+  },
+  "cookies": [
+    {"name": "session", "value": "{{.id}}-{{cookie \"user\"}}", "path": "/", "max_age": 60, "http_only": true},
+    {"name": "theme", "value": "dark", "same_site": "lax"}
+  ],
+  "trailers": {"X-Checksum": "{{method}} {{path}}"}
+}`
+	req, _ = http.NewRequest("POST", "/mock/register/"+uri+"/response", strings.NewReader(spec))
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Register response spec failed: %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case02: templated status, headers, cookies and trailers.")
+	req, _ = http.NewRequest("GET", "/mock/api/"+uri, nil)
+	req.Header.Set("X-Request-Id", "req-001")
+	req.AddCookie(&http.Cookie{Name: "user", Value: "alice"})
+	rr := serveRouter(req)
+	resp := rr.Result()
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status code: %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if body := rr.Body.String(); body != `{"id":"1"}` {
+		t.Errorf("Unexpected body: %s", body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/vnd.test+json" {
+		t.Errorf("Unexpected content type: %s", ct)
+	}
+	if id := resp.Header.Get("X-Request-Id"); id != "req-001" {
+		t.Errorf("Unexpected request id: %s", id)
+	}
+	if links := resp.Header.Values("Link"); len(links) != 2 || links[1] != "</items?page=1>; rel=last" {
+		t.Errorf("Unexpected links: %v", links)
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("Unexpected cookies: %v", resp.Header.Values("Set-Cookie"))
+	}
+	if c := cookies[0]; c.Name != "session" || c.Value != "1-alice" || c.MaxAge != 60 || !c.HttpOnly {
+		t.Errorf("Unexpected session cookie: %+v", c)
+	}
+	if c := cookies[1]; c.Name != "theme" || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Unexpected theme cookie: %+v", c)
+	}
+	if checksum := resp.Trailer.Get("X-Checksum"); checksum != "GET /mock/api/"+uri {
+		t.Errorf("Unexpected trailer: %s", checksum)
+	}
+
+	t.Log("Case03: status by query params, and code query arg takes precedence.")
+	req, _ = http.NewRequest("GET", "/mock/api/"+uri+"?id=404", nil)
+	if rr := serveRouter(req); rr.Code != http.StatusNotFound || rr.Body.String() != `{"id":"404"}` {
+		t.Errorf("Unexpected response: %d, %s", rr.Code, rr.Body.String())
+	}
+	req, _ = http.NewRequest("GET", "/mock/api/"+uri+"?code=202", nil)
+	if rr := serveRouter(req); rr.Code != http.StatusAccepted {
+		t.Errorf("Unexpected status code: %d", rr.Code)
+	}
+
+	t.Log("Case04: response spec is listed with stub, and deleted with stub.")
+	req, _ = http.NewRequest("GET", "/mock/stubs/"+uri, nil)
+	if body := serveRouter(req).Body.String(); !strings.Contains(body, `"response":`) {
+		t.Errorf("Response spec not in stub: %s", body)
+	}
+	req, _ = http.NewRequest("DELETE", "/mock/stubs/"+uri, nil)
+	serveRouter(req)
+	req, _ = http.NewRequest("GET", "/mock/api/"+uri, nil)
+	if rr := serveRouter(req); rr.Code != http.StatusNotFound {
+		t.Errorf("Unexpected status code after delete: %d", rr.Code)
+	}
+}
@@ -172,36 +172,23 @@ func mockTest0104(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// mock test, returns gzip and chunked http response
-// Get /mocktest/one/5
+// mock test, returns gzip and chunked http response, encoding can be changed by query "encoding" and "encoding_mode".
+// Get /mocktest/one/5?size=4096
 func mockTest0105(w http.ResponseWriter, r *http.Request) {
-	var (
-		b   []byte
-		err error
-	)
-	b, err = ioutil.ReadFile("diskusage")
-	if err != nil {
-		common.ErrHandler(w, err)
-		return
-	}
-
-	// gzip encode
-	b, err = myutils.GzipEncode(b)
-	if err != nil {
-		b = []byte(fmt.Sprintln("gzip encode error:", err))
-		w.Header().Set(common.TextContentLength, strconv.Itoa(len(b)))
-		w.WriteHeader(http.StatusInternalServerError)
-	} else {
-		w.Header().Set(common.TextContentEncoding, "gzip")
-		w.WriteHeader(http.StatusOK)
+	size, err := common.GetIntArgFromQuery(r, "size")
+	if err != nil || size <= 0 {
+		size = 4096
 	}
+	b := []byte(common.CreateMockString(size))
 
+	// compressed by hooks
+	common.SetResponseEncoding(w, common.EncodingGzip, "")
+	w.WriteHeader(http.StatusOK)
 	if _, err := io.Copy(w, bufio.NewReader(bytes.NewReader(b))); err != nil {
 		common.ErrHandler(w, err)
 	}
 	// Response Header:
 	// < Content-Encoding: gzip
-	// < Content-Type: application/x-gzip
 	// < Transfer-Encoding: chunked
 }
 
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"src/mock.server/common"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golib/httprouter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+/*
+Static mock tree: each file in mock dir is served as an api.
+
+  mocks/GET/v1/users/{id}.json  =>  GET /v1/users/:id
+  mocks/v1/users.POST.json      =>  POST /v1/users
+  mocks/v1/users.json           =>  GET /v1/users
+  mocks/v1/users.POST.meta.yaml =>  sidecar meta (status, headers, delay) of mocks/v1/users.POST.json
+*/
+
+const metaFileSuffix = ".meta.yaml"
+
+// httpMethods methods served by mock tree, and OPTIONS is not included as it's answered by hooks (cors preflight).
+var httpMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+
+// mockTreeMeta sidecar meta of a mock tree file.
+type mockTreeMeta struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	// Delay duration before response, like "200ms", "1s".
+	Delay string `yaml:"delay"`
+}
+
+// mockTreeRoute a route loaded from mock tree file.
+type mockTreeRoute struct {
+	method   string
+	segments []string
+	file     string
+	meta     mockTreeMeta
+	delay    time.Duration
+	literals int
+}
+
+// match returns whether request path segments match route, and "{param}" matches any segment.
+func (route *mockTreeRoute) match(method string, segments []string) bool {
+	if route.method != method || len(route.segments) != len(segments) {
+		return false
+	}
+	for i, seg := range route.segments {
+		if !isPathParam(seg) && seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isPathParam(seg string) bool {
+	return strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
+
+// MockTree serves files in a directory as apis, and reloads when files changed.
+type MockTree struct {
+	dir    string
+	mutex  sync.RWMutex
+	routes []*mockTreeRoute
+}
+
+var mockTree *MockTree
+
+// LoadMockTree loads mock tree from dir and watches changes, then unmatched requests are served by mock tree.
+// OPTIONS files are not served, as hooks answer cors preflight with 200.
+func LoadMockTree(dir string) error {
+	tree := &MockTree{dir: dir}
+	if err := tree.Reload(); err != nil {
+		return err
+	}
+	if err := tree.watch(); err != nil {
+		return err
+	}
+	mockTree = tree
+	return nil
+}
+
+// Reload walks mock dir and rebuilds routes.
+func (tree *MockTree) Reload() error {
+	routes := make([]*mockTreeRoute, 0, 16)
+	err := filepath.Walk(tree.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, metaFileSuffix) || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		route, err := tree.newRoute(path)
+		if err != nil {
+			return err
+		}
+		routes = append(routes, route)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// literal segments take precedence over params
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].literals > routes[j].literals
+	})
+
+	tree.mutex.Lock()
+	tree.routes = routes
+	tree.mutex.Unlock()
+	log.Printf("Mock tree loaded from %s: %d routes\n", tree.dir, len(routes))
+	for _, route := range routes {
+		log.Printf("%s /%s => %s\n", route.method, strings.Join(route.segments, "/"), route.file)
+	}
+	return nil
+}
+
+// newRoute parses method and path from file path, and loads sidecar meta file if exist.
+func (tree *MockTree) newRoute(path string) (*mockTreeRoute, error) {
+	rel, err := filepath.Rel(tree.dir, path)
+	if err != nil {
+		return nil, err
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+
+	method := "GET"
+	if len(segments) > 1 && isHTTPMethod(segments[0]) {
+		method = segments[0]
+		segments = segments[1:]
+	}
+
+	last := len(segments) - 1
+	name := strings.TrimSuffix(segments[last], filepath.Ext(segments[last]))
+	if ext := filepath.Ext(name); len(ext) > 0 && isHTTPMethod(ext[1:]) {
+		method = ext[1:]
+		name = strings.TrimSuffix(name, ext)
+	}
+	segments[last] = name
+
+	route := &mockTreeRoute{
+		method:   method,
+		segments: segments,
+		file:     path,
+	}
+	for _, seg := range segments {
+		if !isPathParam(seg) {
+			route.literals++
+		}
+	}
+
+	metaPath := strings.TrimSuffix(path, filepath.Ext(path)) + metaFileSuffix
+	data, err := ioutil.ReadFile(metaPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return route, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &route.meta); err != nil {
+		return nil, fmt.Errorf("invalid meta file (%s): %v", metaPath, err)
+	}
+	if len(route.meta.Delay) > 0 {
+		if route.delay, err = time.ParseDuration(route.meta.Delay); err != nil {
+			return nil, fmt.Errorf("invalid delay in meta file (%s): %v", metaPath, err)
+		}
+	}
+	return route, nil
+}
+
+func isHTTPMethod(text string) bool {
+	for _, method := range httpMethods {
+		if method == text {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads mock tree when files in mock dir changed.
+func (tree *MockTree) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := tree.addWatchDirs(watcher); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		const debounce = 200 * time.Millisecond
+		var reload <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				log.Printf("Mock tree changed: Op=%v, Name=%s\n", event.Op, event.Name)
+				reload = time.After(debounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("Mock tree watch error:", err)
+			case <-reload:
+				if err := tree.addWatchDirs(watcher); err != nil {
+					log.Println("Mock tree watch error:", err)
+				}
+				if err := tree.Reload(); err != nil {
+					log.Println("Mock tree reload error:", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// addWatchDirs adds mock dir and all sub dirs to watcher.
+func (tree *MockTree) addWatchDirs(watcher *fsnotify.Watcher) error {
+	return filepath.Walk(tree.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// findRoute returns route matched by request method and path.
+func (tree *MockTree) findRoute(r *http.Request) *mockTreeRoute {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	tree.mutex.RLock()
+	defer tree.mutex.RUnlock()
+	for _, route := range tree.routes {
+		if route.match(r.Method, segments) {
+			return route
+		}
+	}
+	return nil
+}
+
+// ServeRoute sends file content of matched route, and returns false if no route matched.
+func (tree *MockTree) ServeRoute(w http.ResponseWriter, r *http.Request) bool {
+	route := tree.findRoute(r)
+	if route == nil {
+		return false
+	}
+
+	b, err := ioutil.ReadFile(route.file)
+	if err != nil {
+		common.ErrHandler(w, err)
+		return true
+	}
+	if route.delay > 0 {
+		log.Printf("mock tree wait %v before send body.\n", route.delay)
+		time.Sleep(route.delay)
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(route.file)); len(contentType) > 0 {
+		w.Header().Set(common.TextContentType, contentType)
+	}
+	for k, v := range route.meta.Headers {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set(common.TextContentLength, strconv.Itoa(len(b)))
+
+	status := route.meta.Status
+	if status <= 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(b); err != nil {
+		log.Println("write mock tree response failed:", err)
+	}
+	return true
+}
+
+// MockTreeHandler serves unmatched requests by mock tree if loaded, or sends not found.
+func MockTreeHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if mockTree != nil && mockTree.ServeRoute(w, r) {
+		return
+	}
+	MockNotFound(w, r, params)
+}
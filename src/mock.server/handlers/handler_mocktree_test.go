@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"src/mock.server/handlers"
+)
+
+func writeMockTreeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serveMockTree(t *testing.T, method, url string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handlers.MockTreeHandler(rr, req, nil)
+	return rr
+}
+
+func TestMockTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocktree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeMockTreeFile(t, filepath.Join(dir, "GET/v1/users/{id}.json"), `{"user":"any"}`)
+	writeMockTreeFile(t, filepath.Join(dir, "GET/v1/users/admin.json"), `{"user":"admin"}`)
+	writeMockTreeFile(t, filepath.Join(dir, "v1/users.POST.json"), `{"created":true}`)
+	writeMockTreeFile(t, filepath.Join(dir, "v1/users.POST.meta.yaml"), "status: 201\nheaders:\n  X-Mock: tree\ndelay: 10ms\n")
+	if err := handlers.LoadMockTree(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Case01: get file by path param.")
+	rr := serveMockTree(t, "GET", "/v1/users/001")
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"user":"any"}` {
+		t.Errorf("Unexpected response: %d, %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Error("Unexpected content type:", rr.Header().Get("Content-Type"))
+	}
+
+	t.Log("Case02: literal path takes precedence over param.")
+	rr = serveMockTree(t, "GET", "/v1/users/admin")
+	if rr.Body.String() != `{"user":"admin"}` {
+		t.Errorf("Unexpected response: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case03: post with method in file name and meta.")
+	rr = serveMockTree(t, "POST", "/v1/users")
+	if rr.Code != http.StatusCreated || rr.Header().Get("X-Mock") != "tree" {
+		t.Errorf("Unexpected response: %d, %v", rr.Code, rr.Header())
+	}
+
+	t.Log("Case04: not found for unmatched method.")
+	if rr = serveMockTree(t, "DELETE", "/v1/users"); rr.Code != http.StatusNotFound {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case05: reload mock tree when file added.")
+	writeMockTreeFile(t, filepath.Join(dir, "v2/items.json"), `[]`)
+	for i := 0; i < 30; i++ {
+		if rr = serveMockTree(t, "GET", "/v2/items"); rr.Code == http.StatusOK {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if rr.Code != http.StatusOK {
+		t.Error("Unexpected returned code after reload:", rr.Code)
+	}
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"src/mock.server/common"
+	myutils "src/tools.app/utils"
+
+	"github.com/golib/httprouter"
+)
+
+const (
+	uploadRulesFilePathPattern = "%s/%s_upload.json"
+	uploadMetaFileExt          = ".json"
+	uploadDataFileExt          = ".data"
+	maxUploadMemory            = 32 << 20
+)
+
+var (
+	uploadsDirPath = filepath.Join(dataDirPath, "uploads")
+	uploadIDRegexp = regexp.MustCompile(`^[0-9]+$`)
+	uploadSeq      uint32
+)
+
+// UploadPartRule a rule which multipart file part of upload must match.
+type UploadPartRule struct {
+	// Part form name of file part, and part is required.
+	Part string `json:"part"`
+	// Filename and ContentType patterns, like "*.png" and "image/*".
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	MinSize     int64  `json:"min_size"`
+	MaxSize     int64  `json:"max_size"`
+}
+
+// UploadRules upload rules of a registered mock api.
+type UploadRules struct {
+	Parts []UploadPartRule `json:"parts"`
+	// Fields required form fields.
+	Fields []string `json:"fields"`
+}
+
+// UploadFile meta of a received upload file.
+type UploadFile struct {
+	ID          string `json:"id"`
+	URI         string `json:"uri"`
+	Part        string `json:"part"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	MD5         string `json:"md5"`
+	Time        string `json:"time"`
+}
+
+// uploadMismatchError upload does not match rules of mock api.
+type uploadMismatchError struct {
+	reasons []string
+}
+
+func (e *uploadMismatchError) Error() string {
+	return "upload not matched: " + strings.Join(e.reasons, "; ")
+}
+
+// MockUploadRegisterHandler registers upload rules (json) of a mock api.
+// POST /mock/register/:uri/upload
+func MockUploadRegisterHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+
+	var rules UploadRules
+	if err := json.Unmarshal(body, &rules); err != nil {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, "invalid upload rules: "+err.Error())
+		return
+	}
+	for _, rule := range rules.Parts {
+		if len(rule.Part) == 0 {
+			common.WriteErrJSONResp(w, http.StatusBadRequest, "part name is required in upload rules")
+			return
+		}
+	}
+
+	if err := myutils.MakeDir(dataDirPath); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	uri := params.ByName(uriName)
+	filePath := fmt.Sprintf(uploadRulesFilePathPattern, dataDirPath, uri)
+	if err := myutils.WriteContentToFile(filePath, string(body), true); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+
+	respJSON := CmdRespJSON{
+		Status:  http.StatusOK,
+		Message: fmt.Sprintf("register upload rules success: %s", uri),
+		Results: string(body),
+	}
+	common.WriteOKJSONResp(w, respJSON)
+}
+
+// MockUploadsHandler lists, downloads or deletes received upload files of a mock api.
+// GET /mock/uploads/:uri
+// GET /mock/uploads/:uri/:id
+// DELETE /mock/uploads/:uri
+func MockUploadsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	uri := params.ByName(uriName)
+	if err := verifyUploadURI(uri); err != nil {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dir := filepath.Join(uploadsDirPath, uri)
+
+	switch r.Method {
+	case "GET":
+		id := params.ByName("id")
+		if len(id) == 0 {
+			files, err := listUploadFiles(dir)
+			if err != nil {
+				common.ErrHandler(w, err)
+				return
+			}
+			common.WriteOKJSONResp(w, files)
+			return
+		}
+		sendUploadFile(w, dir, id)
+	case "DELETE":
+		if err := os.RemoveAll(dir); err != nil {
+			common.ErrHandler(w, err)
+			return
+		}
+		common.WriteOKJSONResp(w, CmdRespJSON{
+			Status:  http.StatusOK,
+			Message: fmt.Sprintf("delete uploads success: %s", uri),
+		})
+	default:
+		common.ErrHandler(w, fmt.Errorf("Method not support: %s", r.Method))
+	}
+}
+
+// verifyUploadURI returns error if uri is not a single name, because uploads dir of uri is under data dir.
+func verifyUploadURI(uri string) error {
+	if len(uri) == 0 || uri == "." || uri == ".." || strings.ContainsAny(uri, `/\`) {
+		return fmt.Errorf("invalid mock api uri: %q", uri)
+	}
+	return nil
+}
+
+func listUploadFiles(dir string) ([]*UploadFile, error) {
+	files := make([]*UploadFile, 0, 16)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if filepath.Ext(info.Name()) != uploadMetaFileExt {
+			continue
+		}
+		file, err := readUploadMeta(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ID < files[j].ID
+	})
+	return files, nil
+}
+
+func readUploadMeta(path string) (*UploadFile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &UploadFile{}
+	if err := json.Unmarshal(b, file); err != nil {
+		return nil, fmt.Errorf("invalid upload meta file (%s): %v", path, err)
+	}
+	return file, nil
+}
+
+func sendUploadFile(w http.ResponseWriter, dir, id string) {
+	if !uploadIDRegexp.MatchString(id) {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, "invalid upload id: "+id)
+		return
+	}
+
+	file, err := readUploadMeta(filepath.Join(dir, id+uploadMetaFileExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			common.WriteErrJSONResp(w, http.StatusNotFound, "upload not found: "+id)
+			return
+		}
+		common.ErrHandler(w, err)
+		return
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, id+uploadDataFileExt))
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+
+	contentType := file.ContentType
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set(common.TextContentType, contentType)
+	w.Header().Set(common.TextContentLength, strconv.Itoa(len(b)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		log.Println("write upload file failed:", err)
+	}
+}
+
+// receiveUploads checks multipart upload by upload rules of mock api, and stores received files.
+// It returns nil files if request is not multipart, and an uploadMismatchError if rules not matched.
+func receiveUploads(uri string, r *http.Request) ([]*UploadFile, map[string][]string, error) {
+	rules, err := loadUploadRules(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(common.TextContentType))
+	if mediaType != "multipart/form-data" {
+		if rules != nil && (len(rules.Parts) > 0 || len(rules.Fields) > 0) {
+			return nil, nil, &uploadMismatchError{reasons: []string{"content type is not multipart/form-data"}}
+		}
+		return nil, nil, nil
+	}
+
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		return nil, nil, &uploadMismatchError{reasons: []string{"invalid multipart body: " + err.Error()}}
+	}
+	// temp files of large parts are removed after files are stored
+	defer r.MultipartForm.RemoveAll()
+	if rules != nil {
+		if reasons := rules.match(r); len(reasons) > 0 {
+			return nil, nil, &uploadMismatchError{reasons: reasons}
+		}
+	}
+
+	files := make([]*UploadFile, 0, len(r.MultipartForm.File))
+	dir := filepath.Join(uploadsDirPath, uri)
+	for part, headers := range r.MultipartForm.File {
+		for _, header := range headers {
+			file, err := storeUploadFile(dir, uri, part, header)
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ID < files[j].ID
+	})
+	return files, r.MultipartForm.Value, nil
+}
+
+func loadUploadRules(uri string) (*UploadRules, error) {
+	filePath := fmt.Sprintf(uploadRulesFilePathPattern, dataDirPath, uri)
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	rules := &UploadRules{}
+	if err := json.Unmarshal(b, rules); err != nil {
+		return nil, fmt.Errorf("invalid upload rules file (%s): %v", filePath, err)
+	}
+	return rules, nil
+}
+
+// match returns reasons why multipart form of request does not match rules.
+func (rules *UploadRules) match(r *http.Request) []string {
+	reasons := make([]string, 0)
+	form := r.MultipartForm
+	for _, field := range rules.Fields {
+		if _, ok := form.Value[field]; !ok {
+			reasons = append(reasons, fmt.Sprintf("field %q is required", field))
+		}
+	}
+
+	for _, rule := range rules.Parts {
+		headers, ok := form.File[rule.Part]
+		if !ok || len(headers) == 0 {
+			reasons = append(reasons, fmt.Sprintf("part %q is required", rule.Part))
+			continue
+		}
+		for _, header := range headers {
+			if len(rule.Filename) > 0 && !matchPattern(rule.Filename, header.Filename) {
+				reasons = append(reasons, fmt.Sprintf("part %q filename %q not matched %q", rule.Part, header.Filename, rule.Filename))
+			}
+			contentType := header.Header.Get(common.TextContentType)
+			if len(rule.ContentType) > 0 && !matchPattern(rule.ContentType, contentType) {
+				reasons = append(reasons, fmt.Sprintf("part %q content type %q not matched %q", rule.Part, contentType, rule.ContentType))
+			}
+			if rule.MinSize > 0 && header.Size < rule.MinSize {
+				reasons = append(reasons, fmt.Sprintf("part %q size %d less than %d", rule.Part, header.Size, rule.MinSize))
+			}
+			if rule.MaxSize > 0 && header.Size > rule.MaxSize {
+				reasons = append(reasons, fmt.Sprintf("part %q size %d greater than %d", rule.Part, header.Size, rule.MaxSize))
+			}
+		}
+	}
+	return reasons
+}
+
+func matchPattern(pattern, text string) bool {
+	ok, err := path.Match(pattern, text)
+	return err == nil && ok
+}
+
+// storeUploadFile saves data and meta of upload file in dir.
+func storeUploadFile(dir, uri, part string, header *multipart.FileHeader) (*UploadFile, error) {
+	if err := verifyUploadURI(uri); err != nil {
+		return nil, err
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	file := &UploadFile{
+		ID:          fmt.Sprintf("%d%04d", now.UnixNano(), atomic.AddUint32(&uploadSeq, 1)%10000),
+		URI:         uri,
+		Part:        part,
+		Filename:    header.Filename,
+		ContentType: header.Header.Get(common.TextContentType),
+		Size:        int64(len(b)),
+		MD5:         myutils.GetMd5HexText(string(b)),
+		Time:        now.Format(time.RFC3339),
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, file.ID+uploadDataFileExt), b, 0644); err != nil {
+		return nil, err
+	}
+	meta, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, file.ID+uploadMetaFileExt), meta, 0644); err != nil {
+		return nil, err
+	}
+	log.Printf("upload file received: uri=%s, part=%s, filename=%s, size=%d\n", uri, part, header.Filename, file.Size)
+	return file, nil
+}
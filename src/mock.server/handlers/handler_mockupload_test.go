@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"src/mock.server/common"
+)
+
+func newUploadRequest(t *testing.T, uri, filename, contentType, content string) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("desc", "upload test")
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="`+filename+`"`)
+	header.Set(common.TextContentType, contentType)
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	mw.Close()
+
+	req, _ := http.NewRequest("POST", "/mock/api/"+uri, &buf)
+	req.Header.Set(common.TextContentType, mw.FormDataContentType())
+	return req
+}
+
+func TestMockUpload(t *testing.T) {
+	const uri = "upload_test"
+	req, _ := http.NewRequest("POST", "/mock/register/"+uri, strings.NewReader(
+		`{"files":[{{range .uploads}}{"name":"{{.Filename}}","size":{{.Size}},"md5":"{{.MD5}}"}{{end}}]}`))
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Register mock api failed: %d", rr.Code)
+	}
+	req, _ = http.NewRequest("DELETE", "/mock/uploads/"+uri, nil)
+	serveRouter(req)
+
+	rules := `{"parts":[{"part":"file","filename":"*.txt","content_type":"text/*","max_size":64}],"fields":["desc"]}`
+	req, _ = http.NewRequest("POST", "/mock/register/"+uri+"/upload", strings.NewReader(rules))
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Register upload rules failed: %d", rr.Code)
+	}
+
+	t.Log("Case01: upload matched rules, and response is templated with upload meta.")
+	rr := serveRouter(newUploadRequest(t, uri, "test.txt", "text/plain", "hello"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %d, body: %s", rr.Code, rr.Body.String())
+	}
+	resp := struct {
+		Files []struct {
+			Name string `json:"name"`
+			Size int    `json:"size"`
+			MD5  string `json:"md5"`
+		} `json:"files"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].Size != 5 || resp.Files[0].MD5 != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("Unexpected response: %s", rr.Body.String())
+	}
+
+	t.Log("Case02: upload not matched rules.")
+	rr = serveRouter(newUploadRequest(t, uri, "test.png", "image/png", "hello"))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "filename") {
+		t.Errorf("Unexpected response: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case03: list and download received files.")
+	req, _ = http.NewRequest("GET", "/mock/uploads/"+uri, nil)
+	rr = serveRouter(req)
+	list := struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 {
+		t.Fatalf("Unexpected uploads: %s", rr.Body.String())
+	}
+	req, _ = http.NewRequest("GET", "/mock/uploads/"+uri+"/"+list.Data[0].ID, nil)
+	rr = serveRouter(req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "hello" {
+		t.Errorf("Unexpected download: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case04: invalid uri is rejected before uploads dir is accessed.")
+	for _, method := range []string{"GET", "DELETE"} {
+		req, _ = http.NewRequest(method, "/mock/uploads/"+uri, nil)
+		req.URL.Path = "/mock/uploads/.."
+		if rr := serveRouter(req); rr.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code of %s invalid uri: %d", method, rr.Code)
+		}
+	}
+	req, _ = http.NewRequest("GET", "/mock/stubs/"+uri, nil)
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Errorf("Registered mock api is removed: %d", rr.Code)
+	}
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"src/mock.server/common"
+)
+
+/* Near-miss: closest stubs of an unmatched request, with reasons why each stub is not matched. */
+
+const maxNearMisses = 5
+
+// NearMissField a field of stub not matched by request.
+type NearMissField struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// NearMiss a stub close to unmatched request.
+type NearMiss struct {
+	// Source of stub: route, mock_api or mock_tree.
+	Source string          `json:"source"`
+	Stub   string          `json:"stub"`
+	Score  float64         `json:"score"`
+	Fields []NearMissField `json:"fields"`
+}
+
+// stubCandidate a stub compared with unmatched request.
+type stubCandidate struct {
+	source      string
+	methods     []string
+	pattern     string
+	uploadRules *UploadRules
+}
+
+// routeEntries routes of router, and set when router is created.
+var routeEntries []RouterEntry
+
+// routes of mock server apis (register, stubs, dashboard, tools, etc.) are not stubs, and the generic
+// "/mock/api/:uri" is ranked by registered mock apis instead.
+var nearMissExcludedRoutes = []string{
+	"/mock/", "/healthz", "/readyz", "/scenarios", "/dashboard", "/journal", "/mirrors/", "/tools/",
+}
+
+// isNearMissRoute returns whether route is a stub ranked in near misses. Catch-all routes (like
+// "/mirror/*path" and "/chaos/*path") match any path, so they're excluded too.
+func isNearMissRoute(route *RouterEntry) bool {
+	if strings.Contains(route.Path, "*") {
+		return false
+	}
+	for _, prefix := range nearMissExcludedRoutes {
+		if strings.HasPrefix(route.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// findNearMisses returns closest stubs of request ranked by score.
+func findNearMisses(r *http.Request) []NearMiss {
+	// multipart form may be parsed to compare with upload rules
+	defer func() {
+		if r.MultipartForm != nil {
+			r.MultipartForm.RemoveAll()
+		}
+	}()
+
+	misses := make([]NearMiss, 0, maxNearMisses)
+	for _, c := range listStubCandidates() {
+		miss, pathScore := c.compare(r)
+		if pathScore <= 0 {
+			continue
+		}
+		misses = append(misses, miss)
+	}
+
+	sort.SliceStable(misses, func(i, j int) bool {
+		return misses[i].Score > misses[j].Score
+	})
+	if len(misses) > maxNearMisses {
+		misses = misses[:maxNearMisses]
+	}
+	return misses
+}
+
+func listStubCandidates() []*stubCandidate {
+	candidates := make([]*stubCandidate, 0, len(routeEntries))
+	byPattern := make(map[string]*stubCandidate, len(routeEntries))
+	for i := range routeEntries {
+		route := &routeEntries[i]
+		if !isNearMissRoute(route) {
+			continue
+		}
+		if c, ok := byPattern[route.Path]; ok {
+			c.methods = append(c.methods, route.Method)
+			continue
+		}
+		c := &stubCandidate{source: "route", methods: []string{route.Method}, pattern: route.Path}
+		byPattern[route.Path] = c
+		candidates = append(candidates, c)
+	}
+
+	if stubs, err := listMockStubs(); err == nil {
+		for _, stub := range stubs {
+			rules, _ := loadUploadRules(stub.URI)
+			candidates = append(candidates, &stubCandidate{
+				source:      "mock_api",
+				methods:     []string{"GET", "POST"},
+				pattern:     "/mock/api/" + stub.URI,
+				uploadRules: rules,
+			})
+		}
+	}
+
+	if mockTree != nil {
+		mockTree.mutex.RLock()
+		for _, route := range mockTree.routes {
+			candidates = append(candidates, &stubCandidate{
+				source:  "mock_tree",
+				methods: []string{route.method},
+				pattern: "/" + strings.Join(route.segments, "/"),
+			})
+		}
+		mockTree.mutex.RUnlock()
+	}
+	return candidates
+}
+
+// compare returns near miss of request, and path score which is 0 if path is not similar at all.
+func (c *stubCandidate) compare(r *http.Request) (NearMiss, float64) {
+	miss := NearMiss{
+		Source: c.source,
+		Stub:   strings.Join(c.methods, ",") + " " + c.pattern,
+		Fields: make([]NearMissField, 0, 4),
+	}
+
+	methodScore := 0.0
+	for _, method := range c.methods {
+		if method == r.Method {
+			methodScore = 1
+		}
+	}
+	if methodScore == 0 {
+		miss.Fields = append(miss.Fields, NearMissField{Field: "method", Expected: strings.Join(c.methods, ","), Actual: r.Method})
+	}
+
+	pathScore, reason := comparePath(c.pattern, r.URL.Path)
+	if len(reason) > 0 {
+		miss.Fields = append(miss.Fields, NearMissField{Field: "path", Expected: c.pattern, Actual: r.URL.Path, Reason: reason})
+	}
+
+	headerScore, bodyScore := 1.0, 1.0
+	if c.uploadRules != nil && (len(c.uploadRules.Parts) > 0 || len(c.uploadRules.Fields) > 0) {
+		contentType := r.Header.Get(common.TextContentType)
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != "multipart/form-data" {
+			headerScore = 0
+			miss.Fields = append(miss.Fields, NearMissField{Field: "header", Expected: "Content-Type: multipart/form-data", Actual: "Content-Type: " + contentType})
+		} else if r.ParseMultipartForm(maxUploadMemory) != nil {
+			bodyScore = 0
+			miss.Fields = append(miss.Fields, NearMissField{Field: "body", Expected: "multipart body", Actual: "invalid multipart body"})
+		} else if reasons := c.uploadRules.match(r); len(reasons) > 0 {
+			bodyScore = 0
+			miss.Fields = append(miss.Fields, NearMissField{Field: "body", Expected: "upload rules", Actual: "not matched", Reason: strings.Join(reasons, "; ")})
+		}
+	}
+
+	score := 0.3*methodScore + 0.5*pathScore + 0.1*headerScore + 0.1*bodyScore
+	miss.Score = float64(int(score*1000)) / 1000
+	return miss, pathScore
+}
+
+// comparePath returns similarity (0-1) of path and route pattern (":name", "*name" or "{name}" params),
+// and reason of the first segment not matched.
+func comparePath(pattern, path string) (float64, string) {
+	expected := splitPath(pattern)
+	actual := splitPath(path)
+
+	total, reason := 0.0, ""
+	for i, seg := range expected {
+		if strings.HasPrefix(seg, "*") {
+			// catch-all matches all left segments
+			if i < len(actual) {
+				return (total + float64(len(actual)-i)) / float64(len(actual)), reason
+			}
+			break
+		}
+		if i >= len(actual) {
+			break
+		}
+		if strings.HasPrefix(seg, ":") || isPathParam(seg) || seg == actual[i] {
+			total++
+			continue
+		}
+
+		total += 0.5 * stringSimilarity(seg, actual[i])
+		if len(reason) == 0 {
+			reason = fmt.Sprintf("segment %d: expected %q, got %q", i+1, seg, actual[i])
+		}
+	}
+
+	if len(expected) != len(actual) && !(len(expected) > 0 && strings.HasPrefix(expected[len(expected)-1], "*")) {
+		if len(reason) == 0 {
+			reason = fmt.Sprintf("expected %d segments, got %d", len(expected), len(actual))
+		}
+	}
+	maxLen := len(expected)
+	if len(actual) > maxLen {
+		maxLen = len(actual)
+	}
+	if maxLen == 0 {
+		return 1, reason
+	}
+	return total / float64(maxLen), reason
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if len(path) == 0 {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// stringSimilarity returns 1 - levenshtein distance / max length.
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+	return 1 - float64(prev[len(rb)])/float64(maxLen)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,82 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"src/mock.server/handlers"
+)
+
+type notFoundResp struct {
+	NearMisses []handlers.NearMiss `json:"near_misses"`
+}
+
+func getNearMisses(t *testing.T, req *http.Request) []handlers.NearMiss {
+	rr := serveRouter(req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Unexpected status code: %d", rr.Code)
+	}
+	resp := notFoundResp{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.NearMisses) == 0 {
+		t.Fatalf("No near misses: %s", rr.Body.String())
+	}
+	return resp.NearMisses
+}
+
+func TestNearMiss(t *testing.T) {
+	t.Log("Case01: closest route with path reason.")
+	req, _ := http.NewRequest("GET", "/mocktest/onee/1", nil)
+	misses := getNearMisses(t, req)
+	if misses[0].Stub != "GET /mocktest/one/:id" || len(misses[0].Fields) != 1 || misses[0].Fields[0].Field != "path" {
+		t.Errorf("Unexpected near miss: %+v", misses[0])
+	}
+
+	t.Log("Case02: closest route with method reason.")
+	req, _ = http.NewRequest("PUT", "/ping", strings.NewReader(""))
+	misses = getNearMisses(t, req)
+	if misses[0].Stub != "GET /ping" || misses[0].Fields[0].Field != "method" || misses[0].Fields[0].Actual != "PUT" {
+		t.Errorf("Unexpected near miss: %+v", misses[0])
+	}
+
+	t.Log("Case03: not registered mock api with registered mock api ranked.")
+	body := `{"name":"near miss"}`
+	req, _ = http.NewRequest("POST", "/mock/register/nearmiss_test", strings.NewReader(body))
+	serveRouter(req)
+	req, _ = http.NewRequest("GET", "/mock/api/nearmiss_tset", nil)
+	misses = getNearMisses(t, req)
+	found := false
+	for _, miss := range misses {
+		if miss.Source == "mock_api" && miss.Stub == "GET,POST /mock/api/nearmiss_test" {
+			found = len(miss.Fields) == 1 && strings.Contains(miss.Fields[0].Reason, "segment 3")
+		}
+	}
+	if !found {
+		t.Errorf("Registered mock api not in near misses: %+v", misses)
+	}
+}
+
+func TestNearMissExcludedRoutes(t *testing.T) {
+	for i, path := range []string{"/mock/api/unknown", "/v1/users/1/orders", "/tools/unknown/1"} {
+		t.Logf("Case%02d: internal, catch-all and generic routes are not ranked for %s.", i+1, path)
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := serveRouter(req)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("Unexpected status code: %d", rr.Code)
+		}
+		resp := notFoundResp{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		for _, miss := range resp.NearMisses {
+			if miss.Source == "route" && (strings.Contains(miss.Stub, "*") ||
+				strings.Contains(miss.Stub, " /mock/") || strings.Contains(miss.Stub, " /tools/")) {
+				t.Errorf("Unexpected near miss: %+v", miss)
+			}
+		}
+	}
+}
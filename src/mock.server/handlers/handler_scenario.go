@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"src/mock.server/common"
+
+	"github.com/golib/httprouter"
+)
+
+/*
+Scenario: a named state shared by mock apis, and templates switch response by state.
+
+  {{if eq (scenario "login") "locked"}}{"error":"locked"}{{else}}{"token":"xxx"}{{setScenario "login" "locked"}}{{end}}
+*/
+
+// ScenarioStarted initial state of a scenario.
+const ScenarioStarted = "started"
+
+// scenarioStore keeps states of scenarios.
+type scenarioStore struct {
+	mutex  sync.RWMutex
+	states map[string]string
+}
+
+var scenarios = &scenarioStore{states: make(map[string]string)}
+
+// get returns state of scenario, and ScenarioStarted if not set.
+func (s *scenarioStore) get(name string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if state, ok := s.states[name]; ok {
+		return state
+	}
+	return ScenarioStarted
+}
+
+func (s *scenarioStore) set(name, state string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.states[name] = state
+}
+
+func (s *scenarioStore) all() map[string]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	states := make(map[string]string, len(s.states))
+	for k, v := range s.states {
+		states[k] = v
+	}
+	return states
+}
+
+// reset resets state of scenario, or all scenarios if name is empty.
+func (s *scenarioStore) reset(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(name) == 0 {
+		s.states = make(map[string]string)
+		return
+	}
+	delete(s.states, name)
+}
+
+// ScenarioHandler lists, sets or resets scenario states.
+// GET /scenarios
+// POST /scenarios/:name?state=xxx
+// DELETE /scenarios
+// DELETE /scenarios/:name
+func ScenarioHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	switch r.Method {
+	case "GET":
+		common.WriteOKJSONResp(w, scenarios.all())
+	case "POST":
+		state := r.URL.Query().Get("state")
+		if len(name) == 0 || len(state) == 0 {
+			common.WriteErrJSONResp(w, http.StatusBadRequest, "scenario name and state are required")
+			return
+		}
+		scenarios.set(name, state)
+		common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: fmt.Sprintf("scenario %s state: %s", name, state)})
+	case "DELETE":
+		scenarios.reset(name)
+		common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: "scenario is reset"})
+	default:
+		common.ErrHandler(w, fmt.Errorf("Method not support: %s", r.Method))
+	}
+}
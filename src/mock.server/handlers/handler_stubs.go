@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"src/mock.server/common"
+	myutils "src/tools.app/utils"
+
+	"github.com/golib/httprouter"
+)
+
+// MockStub a registered mock api.
+type MockStub struct {
+	URI         string `json:"uri"`
+	Query       string `json:"query"`
+	Body        string `json:"body"`
+	UploadRules string `json:"upload_rules,omitempty"`
+	Response    string `json:"response,omitempty"`
+}
+
+// MockStubsHandler lists, gets or deletes registered mock apis, and stubs are created by "/mock/register/:uri".
+// GET /mock/stubs
+// GET /mock/stubs/:uri
+// DELETE /mock/stubs/:uri
+func MockStubsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	uri := params.ByName(uriName)
+	switch r.Method {
+	case "GET":
+		if len(uri) == 0 {
+			stubs, err := listMockStubs()
+			if err != nil {
+				common.ErrHandler(w, err)
+				return
+			}
+			common.WriteOKJSONResp(w, stubs)
+			return
+		}
+
+		stub, err := readMockStub(uri)
+		if err != nil {
+			if os.IsNotExist(err) {
+				common.WriteErrJSONResp(w, http.StatusNotFound, "stub not found: "+uri)
+				return
+			}
+			common.ErrHandler(w, err)
+			return
+		}
+		common.WriteOKJSONResp(w, stub)
+	case "DELETE":
+		for _, pattern := range []string{bodyFilePathPattern, queryFilePathPattern, uploadRulesFilePathPattern, responseSpecFilePathPattern} {
+			if err := os.Remove(fmt.Sprintf(pattern, dataDirPath, uri)); err != nil && !os.IsNotExist(err) {
+				common.ErrHandler(w, err)
+				return
+			}
+		}
+		common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: fmt.Sprintf("delete stub success: %s", uri)})
+	default:
+		common.ErrHandler(w, fmt.Errorf("Method not support: %s", r.Method))
+	}
+}
+
+func listMockStubs() ([]*MockStub, error) {
+	stubs := make([]*MockStub, 0, 16)
+	paths, err := filepath.Glob(fmt.Sprintf(bodyFilePathPattern, dataDirPath, "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	suffix := strings.TrimPrefix(bodyFilePathPattern, "%s/%s")
+	for _, path := range paths {
+		stub, err := readMockStub(strings.TrimSuffix(filepath.Base(path), suffix))
+		if err != nil {
+			return nil, err
+		}
+		stubs = append(stubs, stub)
+	}
+	return stubs, nil
+}
+
+func readMockStub(uri string) (*MockStub, error) {
+	body, err := ioutil.ReadFile(fmt.Sprintf(bodyFilePathPattern, dataDirPath, uri))
+	if err != nil {
+		return nil, err
+	}
+	stub := &MockStub{URI: uri, Body: string(body)}
+	if b, err := ioutil.ReadFile(fmt.Sprintf(queryFilePathPattern, dataDirPath, uri)); err == nil {
+		stub.Query = string(b)
+	}
+	if b, err := ioutil.ReadFile(fmt.Sprintf(uploadRulesFilePathPattern, dataDirPath, uri)); err == nil {
+		stub.UploadRules = string(b)
+	}
+	if b, err := ioutil.ReadFile(fmt.Sprintf(responseSpecFilePathPattern, dataDirPath, uri)); err == nil {
+		stub.Response = string(b)
+	}
+	return stub, nil
+}
+
+// MockTreeFileHandler writes a mock tree file for method and path, and mock tree is reloaded.
+// PUT /mock/tree/*path?method=GET
+func MockTreeFileHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if mockTree == nil {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, "mock tree is not loaded, and start mock server with -dir")
+		return
+	}
+
+	method := strings.ToUpper(r.URL.Query().Get("method"))
+	if len(method) == 0 {
+		method = "GET"
+	}
+	if !isHTTPMethod(method) {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, "invalid method: "+method)
+		return
+	}
+	path := filepath.Clean("/" + params.ByName("path"))
+	if path == "/" {
+		common.WriteErrJSONResp(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	if len(filepath.Ext(path)) == 0 {
+		path += ".json"
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	filePath := filepath.Join(mockTree.dir, method, path)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	// written atomically, so watcher of mock tree never loads a half-written file
+	if err := myutils.WriteFileAtomic(filePath, body, 0644); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	if err := mockTree.Reload(); err != nil {
+		common.ErrHandler(w, err)
+		return
+	}
+	common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: "mock tree file saved: " + filePath})
+}
@@ -0,0 +1,70 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"src/mock.server/handlers"
+)
+
+func TestMockStubs(t *testing.T) {
+	const uri = "stubs_test"
+	req, _ := http.NewRequest("POST", "/mock/register/"+uri+"?name=test", strings.NewReader(`{"name":"{{.name}}"}`))
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Register mock api failed: %d", rr.Code)
+	}
+
+	t.Log("Case01: list registered stubs.")
+	req, _ = http.NewRequest("GET", "/mock/stubs", nil)
+	rr := serveRouter(req)
+	resp := struct {
+		Data []handlers.MockStub `json:"data"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, stub := range resp.Data {
+		if stub.URI == uri && stub.Query == "name=test" && stub.Body == `{"name":"{{.name}}"}` {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Stub not found in list: %s", rr.Body.String())
+	}
+
+	t.Log("Case02: delete stub.")
+	req, _ = http.NewRequest("DELETE", "/mock/stubs/"+uri, nil)
+	serveRouter(req)
+	req, _ = http.NewRequest("GET", "/mock/stubs/"+uri, nil)
+	if rr := serveRouter(req); rr.Code != http.StatusNotFound {
+		t.Errorf("Unexpected status code: %d", rr.Code)
+	}
+}
+
+func TestScenario(t *testing.T) {
+	const uri = "scenario_test"
+	body := `{{if eq (scenario "login") "locked"}}locked{{else}}ok{{setScenario "login" "locked"}}{{end}}`
+	req, _ := http.NewRequest("POST", "/mock/register/"+uri, strings.NewReader(body))
+	serveRouter(req)
+	req, _ = http.NewRequest("DELETE", "/scenarios", nil)
+	serveRouter(req)
+
+	t.Log("Case01: response is switched by scenario state.")
+	for _, expected := range []string{"ok", "locked"} {
+		req, _ = http.NewRequest("GET", "/mock/api/"+uri, nil)
+		if rr := serveRouter(req); rr.Body.String() != expected {
+			t.Errorf("Unexpected response: %s, expected: %s", rr.Body.String(), expected)
+		}
+	}
+
+	t.Log("Case02: reset scenario state.")
+	req, _ = http.NewRequest("DELETE", "/scenarios/login", nil)
+	serveRouter(req)
+	req, _ = http.NewRequest("GET", "/mock/api/"+uri, nil)
+	if rr := serveRouter(req); rr.Body.String() != "ok" {
+		t.Errorf("Unexpected response: %s", rr.Body.String())
+	}
+}
@@ -1,12 +1,24 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"src/mock.server/common"
@@ -15,45 +27,215 @@ import (
 	"github.com/golib/httprouter"
 )
 
+const (
+	// ToolsTokenHeader header of auth token for tools apis.
+	ToolsTokenHeader = "X-Tools-Token"
+	// ToolsSignHeader header of hmac-sha256 hex signature of request (see SignToolsRequest) for tools apis.
+	ToolsSignHeader = "X-Tools-Signature"
+	// ToolsTimestampHeader header of unix seconds when request is signed.
+	ToolsTimestampHeader = "X-Tools-Timestamp"
+
+	// toolsSignWindow seconds of signed request is valid, and a signature is used only once in window.
+	toolsSignWindow = 300
+
+	defaultCmdTimeout   = 8
+	defaultMailTimeout  = 30
+	defaultCmdMaxOutput = 1024 * 1024
+	defaultCmdArgRegexp = `[\w./:=@-]+`
+	defaultToolsMaxBody = 1024 * 1024
+)
+
 // ToolsHandler router for tools handlers.
 func ToolsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	name := params.ByName("name")
-	ch := make(chan struct{})
-
-	if r.Method == "POST" {
-		switch name {
-		case "cmd":
-			go runSystemCmd(w, r, ch)
-		case "mail":
-			go sendMail(w, r, ch)
-		default:
-			common.ErrHandler(w, fmt.Errorf("GET for invalid path: %s", r.URL.Path))
-		}
-	} else {
+	if r.Method != "POST" {
 		common.ErrHandler(w, fmt.Errorf("Method not support: %s", r.Method))
+		return
 	}
 
-	timeout := 8
-	for {
-		select {
-		case <-ch:
+	audit := &toolsAuditEntry{
+		Time:       time.Now().Format(time.RFC3339),
+		RemoteAddr: r.RemoteAddr,
+		Tool:       name,
+	}
+	// body is limited before auth, as it's read to verify signature
+	maxBody := common.RunConfigs.Tools.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = defaultToolsMaxBody
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	defer r.Body.Close()
+	if err != nil {
+		if int64(len(body)) >= maxBody {
+			writeToolsErr(w, audit, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds max size %d", maxBody))
 			return
-		case <-time.After(time.Duration(timeout) * time.Second):
-			common.ErrHandler(w, fmt.Errorf("Time out"))
+		}
+		common.ErrHandler(w, err)
+		return
+	}
+
+	if code, err := authToolsRequest(r, body); err != nil {
+		writeToolsErr(w, audit, code, err)
+		return
+	}
+
+	switch name {
+	case "cmd":
+		var reqJSON CmdReqJSON
+		if err := json.Unmarshal(body, &reqJSON); err != nil {
+			writeToolsErr(w, audit, http.StatusBadRequest, err)
 			return
-		case <-r.Context().Done():
-			log.Println("request cancelled")
+		}
+		cmds, code, err := resolveAllowedCmds(&reqJSON)
+		if err != nil {
+			writeToolsErr(w, audit, code, err)
 			return
-		case <-time.Tick(time.Second):
-			log.Println("tools handler is processing...")
+		}
+		for _, cmd := range cmds {
+			audit.Commands = append(audit.Commands, cmd.line)
+		}
+		if reqJSON.Async {
+			createCmdJob(w, cmds, audit)
+			return
+		}
+		runSystemCmd(r.Context(), w, cmds, audit)
+	case "mail":
+		sendMail(r.Context(), w, body, audit)
+	default:
+		writeToolsErr(w, audit, http.StatusNotFound, fmt.Errorf("POST for invalid path: %s", r.URL.Path))
+	}
+}
+
+/* Tools Auth and Audit */
+
+// authToolsRequest verifies auth token or hmac signature of request, and returns http status code when failed.
+func authToolsRequest(r *http.Request, body []byte) (int, error) {
+	cfg := common.RunConfigs.Tools
+	if len(cfg.AuthToken) == 0 && len(cfg.HMACSecret) == 0 {
+		return http.StatusForbidden, fmt.Errorf("tools apis are disabled: auth is not configured")
+	}
+
+	if token := r.Header.Get(ToolsTokenHeader); len(token) > 0 && len(cfg.AuthToken) > 0 {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AuthToken)) == 1 {
+			return http.StatusOK, nil
+		}
+		return http.StatusUnauthorized, fmt.Errorf("invalid tools auth token")
+	}
+
+	if sign := r.Header.Get(ToolsSignHeader); len(sign) > 0 && len(cfg.HMACSecret) > 0 {
+		timestamp := r.Header.Get(ToolsTimestampHeader)
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return http.StatusUnauthorized, fmt.Errorf("header %s of unix seconds is required", ToolsTimestampHeader)
+		}
+		now := time.Now()
+		if diff := now.Unix() - ts; diff > toolsSignWindow || diff < -toolsSignWindow {
+			return http.StatusUnauthorized, fmt.Errorf("tools signature is expired: %s", timestamp)
+		}
+
+		got, err := hex.DecodeString(strings.TrimPrefix(sign, "sha256="))
+		if err != nil {
+			return http.StatusUnauthorized, fmt.Errorf("invalid tools signature: %v", err)
+		}
+		want, _ := hex.DecodeString(SignToolsRequest(cfg.HMACSecret, r.Method, r.URL.RequestURI(), timestamp, body))
+		if !hmac.Equal(got, want) {
+			return http.StatusUnauthorized, fmt.Errorf("invalid tools signature")
+		}
+		if !usedToolsSigns.use(hex.EncodeToString(got), now) {
+			return http.StatusUnauthorized, fmt.Errorf("tools signature is replayed")
+		}
+		return http.StatusOK, nil
+	}
+	return http.StatusUnauthorized, fmt.Errorf("header %s or %s is required", ToolsTokenHeader, ToolsSignHeader)
+}
+
+// SignToolsRequest returns hex hmac-sha256 signature of "<method>\n<request uri>\n<timestamp>\n<body>", and request uri
+// is path with query, like "/tools/jobs/kfz1q2-1/output".
+func SignToolsRequest(secret, method, uri, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// toolsSigns signatures used in sign window, so a captured request can not be replayed.
+type toolsSigns struct {
+	mutex sync.Mutex
+	signs map[string]time.Time
+}
+
+var usedToolsSigns = &toolsSigns{signs: make(map[string]time.Time)}
+
+// use returns false if signature is used in window, and expired signatures are purged.
+func (s *toolsSigns) use(sign string, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k, expireAt := range s.signs {
+		if now.After(expireAt) {
+			delete(s.signs, k)
 		}
 	}
+	if _, ok := s.signs[sign]; ok {
+		return false
+	}
+	// timestamp may be ahead of now in window, so signature is kept for 2 windows
+	s.signs[sign] = now.Add(2 * toolsSignWindow * time.Second)
+	return true
 }
 
-// CmdReqJSON run command request json.
+// toolsAuditEntry an audit log entry for tools apis.
+type toolsAuditEntry struct {
+	Time       string   `json:"time"`
+	RemoteAddr string   `json:"remote_addr"`
+	Tool       string   `json:"tool"`
+	Job        string   `json:"job,omitempty"`
+	Commands   []string `json:"commands,omitempty"`
+	Receivers  []string `json:"receivers,omitempty"`
+	Status     int      `json:"status"`
+	Error      string   `json:"error,omitempty"`
+}
+
+var auditMutex sync.Mutex
+
+func writeToolsAudit(entry *toolsAuditEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("marshal tools audit entry failed:", err)
+		return
+	}
+	log.Println("Tools audit:", string(b))
+
+	path := common.RunConfigs.Tools.AuditLog
+	if len(path) == 0 {
+		return
+	}
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	if _, err := myutils.AppendContentToFile(path, string(b)+"\n"); err != nil {
+		log.Println("write tools audit log failed:", err)
+	}
+}
+
+func writeToolsErr(w http.ResponseWriter, audit *toolsAuditEntry, code int, err error) {
+	audit.Status = code
+	audit.Error = err.Error()
+	writeToolsAudit(audit)
+	if err := common.WriteErrJSONResp(w, code, err.Error()); err != nil {
+		common.ErrHandler(w, err)
+	}
+}
+
+/* Tools: run command */
+
+// CmdReqJSON run command request json. Runs allowed template by name with args,
+// or a list of allowed commands which have no args. If async is true, a job is created
+// and job id is returned immediately.
 type CmdReqJSON struct {
-	Meta     string   `json:"meta,omitempty"`
-	Commands []string `json:"commands"`
+	Meta     string            `json:"meta,omitempty"`
+	Commands []string          `json:"commands,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Args     map[string]string `json:"args,omitempty"`
+	Async    bool              `json:"async,omitempty"`
 }
 
 // CmdRespJSON run command response json.
@@ -62,56 +244,216 @@ type CmdRespJSON struct {
 	Status  int    `json:"status"`
 	Message string `json:"message"`
 	Results string `json:"results"`
+	JobID   string `json:"job_id,omitempty"`
 }
 
-// runSystemCmd, runs shell command and returns results.
-// Post /tools/cmd
-func runSystemCmd(w http.ResponseWriter, r *http.Request, ch chan<- struct{}) {
-	defer func() {
-		ch <- struct{}{}
-	}()
+type allowedCmd struct {
+	line      string
+	timeout   int
+	maxOutput int
+}
+
+// resolveAllowedCmds matches request commands against allowed command templates.
+func resolveAllowedCmds(req *CmdReqJSON) ([]*allowedCmd, int, error) {
+	if len(req.Meta) > 0 {
+		log.Println("Run cmd meta:", req.Meta)
+	}
 
-	body, err := ioutil.ReadAll(r.Body)
+	templates := common.RunConfigs.Tools.Commands
+	if len(req.Name) > 0 {
+		for _, tmpl := range templates {
+			if tmpl.Name == req.Name {
+				cmd, err := renderCmdTemplate(&tmpl, req.Args)
+				if err != nil {
+					return nil, http.StatusBadRequest, err
+				}
+				return []*allowedCmd{cmd}, http.StatusOK, nil
+			}
+		}
+		return nil, http.StatusForbidden, fmt.Errorf("command is not allowed: %s", req.Name)
+	}
+
+	if len(req.Commands) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("input commands is empty")
+	}
+	cmds := make([]*allowedCmd, 0, len(req.Commands))
+	for _, line := range req.Commands {
+		var cmd *allowedCmd
+		for _, tmpl := range templates {
+			if len(tmpl.Args) == 0 && strings.TrimSpace(line) == strings.TrimSpace(tmpl.Template) {
+				cmd = newAllowedCmd(&tmpl, tmpl.Template)
+				break
+			}
+		}
+		if cmd == nil {
+			return nil, http.StatusForbidden, fmt.Errorf("command is not allowed: %s", line)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, http.StatusOK, nil
+}
+
+// renderCmdTemplate validates args by template arg patterns, and renders command line with shell quoted args.
+func renderCmdTemplate(tmpl *common.CmdTemplate, args map[string]string) (*allowedCmd, error) {
+	for k := range args {
+		if _, ok := tmpl.Args[k]; !ok {
+			return nil, fmt.Errorf("arg is not allowed for command %s: %s", tmpl.Name, k)
+		}
+	}
+
+	quoted := make(map[string]string, len(tmpl.Args))
+	for k, pattern := range tmpl.Args {
+		val, ok := args[k]
+		if !ok {
+			return nil, fmt.Errorf("arg is required for command %s: %s", tmpl.Name, k)
+		}
+		if len(pattern) == 0 {
+			pattern = defaultCmdArgRegexp
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid arg pattern for command %s: %v", tmpl.Name, err)
+		}
+		if !re.MatchString(val) {
+			return nil, fmt.Errorf("invalid arg value for command %s: %s=%s", tmpl.Name, k, val)
+		}
+		quoted[k] = shellQuote(val)
+	}
+
+	t, err := template.New(tmpl.Name).Option("missingkey=error").Parse(tmpl.Template)
 	if err != nil {
-		common.ErrHandler(w, err)
-		return
+		return nil, err
 	}
-	defer r.Body.Close()
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, quoted); err != nil {
+		return nil, err
+	}
+	return newAllowedCmd(tmpl, buf.String()), nil
+}
 
-	var reqJSON CmdReqJSON
-	if err := json.Unmarshal(body, &reqJSON); err != nil {
-		common.ErrHandler(w, err)
-		return
+func newAllowedCmd(tmpl *common.CmdTemplate, line string) *allowedCmd {
+	cmd := &allowedCmd{
+		line:      line,
+		timeout:   tmpl.Timeout,
+		maxOutput: tmpl.MaxOutput,
 	}
+	if cmd.timeout <= 0 {
+		cmd.timeout = defaultCmdTimeout
+	}
+	if cmd.maxOutput <= 0 {
+		cmd.maxOutput = defaultCmdMaxOutput
+	}
+	return cmd
+}
 
-	if len(reqJSON.Meta) > 0 {
-		log.Println("Run cmd meta:", reqJSON.Meta)
+func getCmdsTimeout(cmds []*allowedCmd) int {
+	total := 0
+	for _, cmd := range cmds {
+		total += cmd.timeout
 	}
-	log.Println("Run cmd:", strings.Join(reqJSON.Commands, ","))
+	return total
+}
 
-	var result string
-	if len(reqJSON.Commands) == 1 {
-		if result, err = myutils.RunShellCmdBuf(reqJSON.Commands[0]); err != nil {
-			common.ErrHandler(w, err)
-			return
+func shellQuote(text string) string {
+	return "'" + strings.Replace(text, "'", `'\''`, -1) + "'"
+}
+
+// runSystemCmd, runs allowed shell commands and returns results.
+// Post /tools/cmd
+func runSystemCmd(ctx context.Context, w http.ResponseWriter, cmds []*allowedCmd, audit *toolsAuditEntry) {
+	results := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		log.Println("Run cmd:", cmd.line)
+		out := &limitedBuffer{limit: cmd.maxOutput}
+		err := runAllowedCmd(ctx, cmd, out, out)
+		result := strings.TrimRight(out.buf.String(), "\n")
+		if out.truncated {
+			result += fmt.Sprintf("\n...(output truncated at %d bytes)", cmd.maxOutput)
 		}
-	} else {
-		if result, err = myutils.RunShellCmds(reqJSON.Commands); err != nil {
-			common.ErrHandler(w, err)
+		if err != nil {
+			if len(result) > 0 {
+				err = fmt.Errorf("%v, output:\n%s", err, result)
+			}
+			writeToolsErr(w, audit, http.StatusInternalServerError, err)
 			return
 		}
+		results = append(results, result)
 	}
 
+	audit.Status = http.StatusOK
+	writeToolsAudit(audit)
 	retJSON := CmdRespJSON{
 		Status:  http.StatusOK,
 		Message: "success",
-		Results: result,
+		Results: strings.Join(results, "\n"),
 	}
 	if err := common.WriteOKJSONResp(w, &retJSON); err != nil {
 		common.ErrHandler(w, err)
 	}
 }
 
+// createCmdJob, runs allowed shell commands in an async job and returns job id.
+// Post /tools/cmd {"async": true}
+func createCmdJob(w http.ResponseWriter, cmds []*allowedCmd, audit *toolsAuditEntry) {
+	job, err := cmdJobs.create(cmds, audit)
+	if err != nil {
+		writeToolsErr(w, audit, http.StatusTooManyRequests, err)
+		return
+	}
+	log.Println("Create cmd job:", job.id)
+
+	retJSON := CmdRespJSON{
+		Status:  http.StatusOK,
+		Message: "job created",
+		JobID:   job.id,
+	}
+	if err := common.WriteOKJSONResp(w, &retJSON); err != nil {
+		common.ErrHandler(w, err)
+	}
+}
+
+// runAllowedCmd runs command with timeout, and writes output to stdout and stderr.
+func runAllowedCmd(ctx context.Context, cmd *allowedCmd, stdout, stderr io.Writer) error {
+	c := myutils.NewShellCommand(cmd.line)
+	c.Stdout = stdout
+	c.Stderr = stderr
+	c.MaxOutput = -1
+	c.Timeout = time.Duration(cmd.timeout) * time.Second
+	if _, err := c.Run(ctx); err != nil {
+		if errors.Is(err, myutils.ErrCmdTimeout) {
+			return fmt.Errorf("command timeout after %ds: %s", cmd.timeout, cmd.line)
+		}
+		if errors.Is(err, myutils.ErrCmdCanceled) {
+			return fmt.Errorf("command cancelled: %s", cmd.line)
+		}
+		if exitErr, ok := err.(*myutils.ExitError); ok {
+			return fmt.Errorf("command (%s) exit with code %d", cmd.line, exitErr.ExitCode)
+		}
+		return fmt.Errorf("command (%s) failed: %v", cmd.line, err)
+	}
+	return nil
+}
+
+// limitedBuffer keeps at most limit bytes, and discards the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.buf.Len(); remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.buf.Write(p[:remain])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+/* Tools: send mail */
+
 // MainRespJSON send mail response json.
 type MainRespJSON struct {
 	Meta    string `json:"meta,omitempty"`
@@ -119,30 +461,60 @@ type MainRespJSON struct {
 	Message string `json:"message"`
 }
 
-// sendMail, sends mail.
-// Post /tools/mail
-func sendMail(w http.ResponseWriter, r *http.Request, ch chan<- struct{}) {
-	defer func() {
-		ch <- struct{}{}
-	}()
+// mailReqJSON send mail request json. Attachments, archive and password of MailEntry are not accepted,
+// otherwise any file of server (like mailer config) could be sent by callers.
+type mailReqJSON struct {
+	Meta    string   `json:"meta"`
+	MailTo  []string `json:"receivers"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+}
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		common.ErrHandler(w, err)
+// sendMail, sends mail to allowed receivers.
+// Post /tools/mail
+func sendMail(ctx context.Context, w http.ResponseWriter, body []byte, audit *toolsAuditEntry) {
+	var req mailReqJSON
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeToolsErr(w, audit, http.StatusBadRequest, err)
 		return
 	}
-	defer r.Body.Close()
+	entry := myutils.MailEntry{
+		Meta:    req.Meta,
+		MailTo:  req.MailTo,
+		Subject: req.Subject,
+		Body:    req.Body,
+	}
+	audit.Receivers = entry.MailTo
+	for _, receiver := range entry.MailTo {
+		if !isMailReceiverAllowed(receiver) {
+			writeToolsErr(w, audit, http.StatusForbidden, fmt.Errorf("mail receiver is not allowed: %s", receiver))
+			return
+		}
+	}
 
-	entry := myutils.MailEntry{ServerPwd: "*******"}
-	if err := json.Unmarshal(body, &entry); err != nil {
-		common.ErrHandler(w, err)
+	// only the result is sent back by channel, so response is not written after timeout
+	ch := make(chan error, 1)
+	go func() {
+		ch <- myutils.SendMail(&entry)
+	}()
+	select {
+	case err := <-ch:
+		if err != nil {
+			writeToolsErr(w, audit, http.StatusInternalServerError, err)
+			return
+		}
+	case <-time.After(time.Duration(defaultMailTimeout) * time.Second):
+		writeToolsErr(w, audit, http.StatusGatewayTimeout, fmt.Errorf("send mail timeout"))
 		return
-	}
-	if err := myutils.SendMail(&entry); err != nil {
-		common.ErrHandler(w, err)
+	case <-ctx.Done():
+		log.Println("request cancelled")
 		return
 	}
 
+	audit.Status = http.StatusOK
+	writeToolsAudit(audit)
 	retJSON := MainRespJSON{
 		Status:  http.StatusOK,
 		Message: "success",
@@ -151,3 +523,19 @@ func sendMail(w http.ResponseWriter, r *http.Request, ch chan<- struct{}) {
 		common.ErrHandler(w, err)
 	}
 }
+
+// isMailReceiverAllowed checks receiver by allowed addresses, or domains which start with "@".
+func isMailReceiverAllowed(receiver string) bool {
+	receiver = strings.ToLower(strings.TrimSpace(receiver))
+	for _, allowed := range common.RunConfigs.Tools.MailReceivers {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "@") {
+			if strings.HasSuffix(receiver, allowed) {
+				return true
+			}
+		} else if receiver == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package handlers_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"src/mock.server/common"
+	"src/mock.server/handlers"
+)
+
+func setToolsConfigs(t *testing.T) {
+	setRunConfigs(t, func(configs *common.Configs) {
+		configs.Tools = common.ToolsConfigs{
+			AuthToken:  "test-token",
+			HMACSecret: "test-secret",
+			Commands: []common.CmdTemplate{
+				{Name: "hello", Template: "echo hello"},
+				{Name: "echo", Template: "echo {{.text}}", Args: map[string]string{"text": `[a-z]+`}},
+			},
+			MailReceivers: []string{"@example.com"},
+		}
+	})
+}
+
+func newToolsRequest(t *testing.T, name, body string) *http.Request {
+	req, err := http.NewRequest("POST", "/tools/"+name, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(common.TextContentType, common.ContentTypeJSON)
+	return req
+}
+
+func TestToolsAuth(t *testing.T) {
+	setToolsConfigs(t)
+	body := `{"commands":["echo hello"]}`
+
+	t.Log("Case01: tools request without auth headers.")
+	req := newToolsRequest(t, "cmd", body)
+	rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusUnauthorized {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case02: tools request with invalid token.")
+	req = newToolsRequest(t, "cmd", body)
+	req.Header.Set(handlers.ToolsTokenHeader, "invalid")
+	rr = newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusUnauthorized {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case03: tools request with hmac signature of method, path, timestamp and body.")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	sign := "sha256=" + handlers.SignToolsRequest("test-secret", "POST", "/tools/cmd", timestamp, []byte(body))
+	req = newToolsRequest(t, "cmd", body)
+	req.Header.Set(handlers.ToolsSignHeader, sign)
+	req.Header.Set(handlers.ToolsTimestampHeader, timestamp)
+	rr = newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusOK {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "hello") {
+		t.Error("Unexpected response:", rr.Body.String())
+	}
+
+	t.Log("Case04: replayed, expired, body only and other path signatures are rejected.")
+	expired := strconv.FormatInt(time.Now().Unix()-3600, 10)
+	mac := hmac.New(sha256.New, []byte("test-secret"))
+	mac.Write([]byte(body))
+	for _, c := range []struct {
+		desc, name, timestamp, sign string
+	}{
+		{"replayed", "cmd", timestamp, sign},
+		{"expired", "cmd", expired, "sha256=" + handlers.SignToolsRequest("test-secret", "POST", "/tools/cmd", expired, []byte(body))},
+		{"body only", "cmd", timestamp, "sha256=" + hex.EncodeToString(mac.Sum(nil))},
+		{"other path", "mail", timestamp, sign},
+		{"no timestamp", "cmd", "", sign},
+	} {
+		req = newToolsRequest(t, c.name, body)
+		req.Header.Set(handlers.ToolsSignHeader, c.sign)
+		req.Header.Set(handlers.ToolsTimestampHeader, c.timestamp)
+		if rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler); rr.Code != http.StatusUnauthorized {
+			t.Errorf("Unexpected returned code of %s signature: %d", c.desc, rr.Code)
+		}
+	}
+
+	t.Log("Case05: tools request when auth is not configured.")
+	common.RunConfigs.Tools.AuthToken = ""
+	common.RunConfigs.Tools.HMACSecret = ""
+	req = newToolsRequest(t, "cmd", body)
+	req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+	rr = newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusForbidden {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case06: request body over max size is rejected before auth.")
+	common.RunConfigs.Tools.MaxBodySize = 16
+	req = newToolsRequest(t, "cmd", strings.Repeat("x", 17))
+	rr = newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+}
+
+func TestToolsCmdAllowList(t *testing.T) {
+	setToolsConfigs(t)
+
+	cases := []struct {
+		desc string
+		body string
+		code int
+	}{
+		{"allowed template with arg", `{"name":"echo","args":{"text":"world"}}`, http.StatusOK},
+		{"not allowed command", `{"commands":["rm -rf /tmp/x"]}`, http.StatusForbidden},
+		{"not allowed template name", `{"name":"rm"}`, http.StatusForbidden},
+		{"arg not match pattern", `{"name":"echo","args":{"text":"a;id"}}`, http.StatusBadRequest},
+		{"unknown arg", `{"name":"echo","args":{"text":"a","other":"b"}}`, http.StatusBadRequest},
+	}
+	for i, c := range cases {
+		t.Logf("Case%02d: %s.", i+1, c.desc)
+		req := newToolsRequest(t, "cmd", c.body)
+		req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+		rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+		if rr.Code != c.code {
+			t.Errorf("Unexpected returned code: %d, response: %s", rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestToolsCmdFailed(t *testing.T) {
+	setToolsConfigs(t)
+	common.RunConfigs.Tools.Commands = append(common.RunConfigs.Tools.Commands,
+		common.CmdTemplate{Name: "fail", Template: "echo out; echo err 1>&2; exit 2"})
+
+	t.Log("Case01: exit error and output of failed command are returned.")
+	req := newToolsRequest(t, "cmd", `{"name":"fail"}`)
+	req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+	rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Unexpected returned code: %d, response: %s", rr.Code, rr.Body.String())
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "exit with code 2") || !strings.Contains(body, "out") ||
+		!strings.Contains(body, "err") {
+		t.Errorf("Unexpected response: %s", body)
+	}
+}
+
+func TestToolsMailAllowList(t *testing.T) {
+	setToolsConfigs(t)
+
+	t.Log("Case01: send mail to not allowed receiver.")
+	req := newToolsRequest(t, "mail", `{"receivers":["foo@example.com","bar@other.com"],"subject":"test"}`)
+	req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+	rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+	if rr.Code != http.StatusForbidden {
+		t.Error("Unexpected returned code:", rr.Code)
+	}
+
+	t.Log("Case02: attachments, archive and password are rejected.")
+	for _, body := range []string{
+		`{"receivers":["foo@example.com"],"subject":"test","attachments":["/etc/passwd"]}`,
+		`{"receivers":["foo@example.com"],"subject":"test","archive":true}`,
+		`{"receivers":["foo@example.com"],"subject":"test","pwd":"secret"}`,
+	} {
+		req := newToolsRequest(t, "mail", body)
+		req.Header.Set(handlers.ToolsTokenHeader, "test-token")
+		rr := newRequestRecorder(req, "POST", "/tools/:name", handlers.ToolsHandler)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected returned code for %s: %d", body, rr.Code)
+		}
+	}
+}
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"src/mock.server/common"
+	"src/tools.app/utils/httpresp"
 
 	"github.com/golib/httprouter"
 )
@@ -15,29 +21,49 @@ import (
 
 // NewHooks returns http connect handler hooks.
 func NewHooks() *Hooks {
-	return &Hooks{start: time.Now()}
+	return &Hooks{limiter: NewRateLimiter()}
 }
 
-// Hooks http connect handler hooks.
+// Hooks http connect handler hooks. Mutex keeps request logs in order, and is not held
+// by handlers, so long running handlers (like job output streaming) do not block others.
 type Hooks struct {
-	start time.Time
-	mutex sync.Mutex
+	mutex   sync.Mutex
+	limiter *RateLimiter
 }
 
 // RunHooks run before and after hooks when handle http connect.
 func (h *Hooks) RunHooks(fn httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
+		ew := common.NewEncodingResponseWriter(w, r)
 		defer func() {
-			if p := recover(); p != nil {
-				common.ErrHandler(w, p.(error))
+			if err := ew.Close(); err != nil {
+				log.Println("close encoding response writer failed:", err)
 			}
 		}()
+		w = ew
 
-		h.mutex.Lock()
 		defer func() {
-			h.mutex.Unlock()
+			if p := recover(); p != nil {
+				common.ErrHandler(w, p.(error))
+			}
 		}()
 
+		start := time.Now()
+		r = httpresp.SetRequestID(w, r)
+		if !isProxyPath(r.URL.Path) {
+			if err := common.DecodeRequestBody(r); err == common.ErrDecodedBodyTooLarge {
+				common.WriteErrJSONResp(w, http.StatusRequestEntityTooLarge, err.Error())
+				return
+			} else if err != nil {
+				common.WriteErrJSONResp(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		entry, jw, r := journal.start(w, r)
+		if entry != nil {
+			w = jw
+			defer journal.finish(entry, jw, start)
+		}
 		if err := h.beforeHooks(w, r); err != nil {
 			common.ErrHandler(w, err)
 			return
@@ -46,26 +72,70 @@ func (h *Hooks) RunHooks(fn httprouter.Handle) httprouter.Handle {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if !h.limiter.Allow(w, r) {
+			return
+		}
+		if !CheckAuthRules(w, r) {
+			return
+		}
 
 		fn(w, r, param)
-		h.afterHooks(w, r)
+		h.afterHooks(w, r, start)
+	}
+}
+
+// bodies of proxy requests are forwarded as they are, instead of decoded.
+var proxyPaths = []string{"/mirror/", "/chaos/"}
+
+func isProxyPath(path string) bool {
+	for _, prefix := range proxyPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
 	}
+	return false
 }
 
 func (h *Hooks) beforeHooks(w http.ResponseWriter, r *http.Request) error {
-	common.LogDivLine()
-	log.Printf("Start: %s %s\n", r.Method, r.URL.Path)
-	if err := common.LogRequestData(r); err != nil {
+	if err := h.logRequest(r); err != nil {
 		return err
 	}
 
-	h.start = time.Now()
 	common.AddCorsHeaders(r, w)
 	return common.MockWait(r)
 }
 
-func (h *Hooks) afterHooks(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Done (%s %s): %v\n", r.Method, r.URL.Path, time.Since(h.start))
+func (h *Hooks) logRequest(r *http.Request) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	common.LogDivLine()
+	log.Printf("Start: %s %s\n", r.Method, r.URL.Path)
+	return common.LogRequestData(r, redactHeaders(r.Header), peekLogBody(r))
+}
+
+// peekLogBody returns redacted head of request body to be logged, and the rest is streamed to handler.
+// Bodies of proxy and multipart requests are not logged.
+func peekLogBody(r *http.Request) string {
+	if r.Body == nil || r.Body == http.NoBody || isProxyPath(r.URL.Path) {
+		return ""
+	}
+	contentType := r.Header.Get(common.TextContentType)
+	if strings.HasPrefix(strings.ToLower(contentType), "multipart/") {
+		return ""
+	}
+
+	head, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxJournalBody))
+	r.Body = &journalRequestBody{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+	body := redactBody(contentType, string(head))
+	if len(head) == maxJournalBody {
+		body += fmt.Sprintf("\n...(body truncated at %d bytes)", maxJournalBody)
+	}
+	return body
+}
+
+func (h *Hooks) afterHooks(w http.ResponseWriter, r *http.Request, start time.Time) {
+	log.Printf("Done (%s %s): %v\n", r.Method, r.URL.Path, time.Since(start))
 	common.LogDivLine()
 }
 
@@ -0,0 +1,186 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"src/mock.server/common"
+	"src/mock.server/handlers"
+)
+
+func TestCorsPreflight(t *testing.T) {
+	t.Log("Case01: preflight of registered mock api is served with cors headers.")
+	req, _ := http.NewRequest("OPTIONS", "/mock/api/test", nil)
+	req.Header.Set("Origin", "http://localhost:8080")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rr := serveRouter(req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %d", rr.Code)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "*" || len(rr.Header().Get("Access-Control-Allow-Credentials")) > 0 ||
+		len(rr.Header().Get("Access-Control-Allow-Methods")) == 0 {
+		t.Errorf("Unexpected cors headers: %v", rr.Header())
+	}
+}
+
+func TestJournal(t *testing.T) {
+	req, _ := http.NewRequest("DELETE", "/journal", nil)
+	serveRouter(req)
+
+	t.Log("Case01: requests are recorded, and unmatched request is marked.")
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	serveRouter(req)
+	req, _ = http.NewRequest("POST", "/not/exist/path", strings.NewReader(`{"key":"val"}`))
+	serveRouter(req)
+
+	req, _ = http.NewRequest("GET", "/journal?unmatched=true", nil)
+	rr := serveRouter(req)
+	resp := struct {
+		Data []handlers.JournalEntry `json:"data"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 {
+		t.Fatalf("Unexpected unmatched requests: %s", rr.Body.String())
+	}
+	entry := resp.Data[0]
+	if entry.URL != "/not/exist/path" || entry.Status != http.StatusNotFound || entry.ReqBody != `{"key":"val"}` {
+		t.Errorf("Unexpected journal entry: %+v", entry)
+	}
+
+	t.Log("Case02: get request detail.")
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/journal/%d", entry.ID), nil)
+	if rr := serveRouter(req); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Page not found") {
+		t.Errorf("Unexpected journal detail: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case03: head of large body is recorded, and full body is sent to handler.")
+	large := strings.Repeat("x", 100<<10)
+	req, _ = http.NewRequest("POST", "/mock/register/journal_large", strings.NewReader(large))
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Register mock api failed: %d", rr.Code)
+	}
+	req, _ = http.NewRequest("GET", "/mock/api/journal_large", nil)
+	if rr := serveRouter(req); rr.Body.Len() != len(large) {
+		t.Errorf("Unexpected body size sent to handler: %d", rr.Body.Len())
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/journal?since=%d", entry.ID), nil)
+	rr = serveRouter(req)
+	resp.Data = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) == 0 || resp.Data[0].URL != "/mock/register/journal_large" || len(resp.Data[0].ReqBody) != 64<<10 {
+		t.Errorf("Unexpected journal entry of large body: %d entries", len(resp.Data))
+	}
+
+	t.Log("Case04: values of auth and tools headers are redacted.")
+	req, _ = http.NewRequest("GET", "/not/exist/redacted", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Cookie", "session=secret-session")
+	req.Header.Set(handlers.ToolsTokenHeader, "secret-tools-token")
+	req.Header.Set(handlers.ToolsSignHeader, "secret-tools-sign")
+	req.Header.Set("X-Test", "visible")
+	serveRouter(req)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/journal?since=%d", entry.ID), nil)
+	rr = serveRouter(req)
+	if strings.Contains(rr.Body.String(), "secret") {
+		t.Fatalf("Sensitive header values are journaled: %s", rr.Body.String())
+	}
+	resp.Data = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range resp.Data {
+		if e.URL != "/not/exist/redacted" {
+			continue
+		}
+		if e.ReqHeaders.Get("Authorization") != "[REDACTED]" || e.ReqHeaders.Get(handlers.ToolsTokenHeader) != "[REDACTED]" ||
+			e.ReqHeaders.Get("X-Test") != "visible" {
+			t.Errorf("Unexpected journaled headers: %v", e.ReqHeaders)
+		}
+		return
+	}
+	t.Errorf("Request is not journaled: %s", rr.Body.String())
+}
+
+func TestJournalRedactBody(t *testing.T) {
+	setAuthConfigs(t, common.AuthRule{Path: "/ping", Type: "session"})
+	req, _ := http.NewRequest("DELETE", "/journal", nil)
+	serveRouter(req)
+
+	t.Log("Case01: client secret, password and issued tokens of token request are redacted.")
+	form := url.Values{
+		"grant_type": {"password"}, "client_id": {"client01"}, "client_secret": {"secret01"},
+		"username": {"user01"}, "password": {"pwd01"}, "scope": {"openid"},
+	}
+	req, _ = http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(common.TextContentType, common.ContentTypeForm)
+	rr := serveRouter(req)
+	var token map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &token); err != nil {
+		t.Fatal(err)
+	}
+	accessToken, _ := token["access_token"].(string)
+	idToken, _ := token["id_token"].(string)
+	if rr.Code != http.StatusOK || len(accessToken) == 0 || len(idToken) == 0 {
+		t.Fatalf("Unexpected token response: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	t.Log("Case02: password of session login is redacted.")
+	req, _ = http.NewRequest("POST", "/session/login", strings.NewReader("user=user01&pwd=pwd01"))
+	req.Header.Set(common.TextContentType, common.ContentTypeForm)
+	if rr := serveRouter(req); rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected login response: %d, %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/journal", nil)
+	rr = serveRouter(req)
+	for _, secret := range []string{"secret01", "pwd01", accessToken, idToken} {
+		if strings.Contains(rr.Body.String(), secret) {
+			t.Fatalf("Secret %q is journaled: %s", secret, rr.Body.String())
+		}
+	}
+	resp := struct {
+		Data []handlers.JournalEntry `json:"data"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 || !strings.Contains(resp.Data[0].ReqBody, "client_id=client01") ||
+		!strings.Contains(resp.Data[0].RespBody, `"access_token":"[REDACTED]"`) || !strings.Contains(resp.Data[1].ReqBody, "user=user01") {
+		t.Errorf("Unexpected journal entries: %s", rr.Body.String())
+	}
+}
+
+func TestLogRequestRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	t.Log("Case01: sensitive headers and form fields are redacted in request log.")
+	req, _ := http.NewRequest("POST", "/not/exist/log", strings.NewReader("user=user01&pwd=secret-pwd"))
+	req.Header.Set(common.TextContentType, common.ContentTypeForm)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	serveRouter(req)
+	if out := buf.String(); strings.Contains(out, "secret") || !strings.Contains(out, "user=user01") {
+		t.Errorf("Unexpected request log: %s", out)
+	}
+
+	t.Log("Case02: only head of large body is logged.")
+	buf.Reset()
+	req, _ = http.NewRequest("POST", "/not/exist/log", strings.NewReader(strings.Repeat("x", 100<<10)))
+	serveRouter(req)
+	if out := buf.String(); strings.Count(out, "x") > 64<<10+10 || !strings.Contains(out, "body truncated") {
+		t.Errorf("Unexpected request log size: %d", len(out))
+	}
+}
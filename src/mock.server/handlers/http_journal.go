@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"src/mock.server/common"
+
+	"github.com/golib/httprouter"
+)
+
+/* Http Request Journal */
+
+const (
+	maxJournalBody   = 64 << 10
+	journalRedactVal = "[REDACTED]"
+)
+
+// requests of dashboard and probes are not recorded.
+var journalExcludedPaths = []string{"/static/", "/dashboard", "/journal", "/healthz", "/readyz"}
+
+type journalKey struct{}
+
+// JournalEntry a recorded request and response.
+type JournalEntry struct {
+	ID          int64       `json:"id"`
+	Time        string      `json:"time"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RemoteAddr  string      `json:"remote_addr"`
+	ReqHeaders  http.Header `json:"req_headers"`
+	ReqBody     string      `json:"req_body"`
+	Status      int         `json:"status"`
+	RespHeaders http.Header `json:"resp_headers"`
+	RespBody    string      `json:"resp_body"`
+	// Duration in milliseconds.
+	Duration float64 `json:"duration"`
+	// Unmatched request is not matched by any route, registered mock api or mock tree.
+	Unmatched  bool       `json:"unmatched"`
+	NearMisses []NearMiss `json:"near_misses,omitempty"`
+}
+
+// journalResponseWriter records status and body of response.
+type journalResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (jw *journalResponseWriter) WriteHeader(code int) {
+	if jw.status == 0 {
+		jw.status = code
+	}
+	jw.ResponseWriter.WriteHeader(code)
+}
+
+func (jw *journalResponseWriter) Write(b []byte) (int, error) {
+	if jw.status == 0 {
+		jw.status = http.StatusOK
+	}
+	if left := maxJournalBody - jw.body.Len(); left > 0 {
+		if len(b) < left {
+			left = len(b)
+		}
+		jw.body.Write(b[:left])
+	}
+	return jw.ResponseWriter.Write(b)
+}
+
+func (jw *journalResponseWriter) Flush() {
+	if f, ok := jw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (jw *journalResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := jw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijack")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped response writer.
+func (jw *journalResponseWriter) Unwrap() http.ResponseWriter {
+	return jw.ResponseWriter
+}
+
+// journalRequestBody request body whose head is read by journal, and Close closes the original body.
+type journalRequestBody struct {
+	io.Reader
+	io.Closer
+}
+
+// requestJournal keeps last requests.
+type requestJournal struct {
+	mutex   sync.Mutex
+	lastID  int64
+	entries []*JournalEntry
+}
+
+var journal = &requestJournal{}
+
+// start records request, and returns wrapped response writer and request, or nil entry if path is excluded.
+func (j *requestJournal) start(w http.ResponseWriter, r *http.Request) (*JournalEntry, *journalResponseWriter, *http.Request) {
+	for _, prefix := range journalExcludedPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return nil, nil, r
+		}
+	}
+
+	entry := &JournalEntry{
+		Time:       time.Now().Format(time.RFC3339Nano),
+		Method:     r.Method,
+		URL:        r.URL.RequestURI(),
+		RemoteAddr: r.RemoteAddr,
+		ReqHeaders: redactHeaders(r.Header),
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		// only head of body is recorded, and the rest is streamed to handler
+		// error of reading is returned again by the rest of body
+		head, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxJournalBody))
+		entry.ReqBody = redactBody(r.Header.Get("Content-Type"), string(head))
+		r.Body = &journalRequestBody{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+	}
+	r = r.WithContext(context.WithValue(r.Context(), journalKey{}, entry))
+	return entry, &journalResponseWriter{ResponseWriter: w}, r
+}
+
+// redactHeaders returns copy of headers, and values of sensitive headers (credentials, cookies and
+// tools signatures) are redacted, because journal is served without auth and saved to state file.
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range common.RunConfigs.Server.JournalRedactHeaders {
+		key := http.CanonicalHeaderKey(name)
+		if values, ok := redacted[key]; ok {
+			for i := range values {
+				values[i] = journalRedactVal
+			}
+		}
+	}
+	return redacted
+}
+
+// redactBody returns body in which values of sensitive fields (like client secrets, passwords and issued tokens)
+// are redacted. Body of form is redacted by fields, and others by json string fields, and truncated head of body
+// is redacted as well.
+func redactBody(contentType, body string) string {
+	fields := common.RunConfigs.Server.JournalRedactFields
+	if len(fields) == 0 || len(body) == 0 {
+		return body
+	}
+
+	if strings.HasPrefix(strings.ToLower(contentType), "application/x-www-form-urlencoded") {
+		pairs := strings.Split(body, "&")
+		for i, pair := range pairs {
+			items := strings.SplitN(pair, "=", 2)
+			key, err := url.QueryUnescape(items[0])
+			if err != nil {
+				key = items[0]
+			}
+			for _, field := range fields {
+				if strings.EqualFold(key, field) {
+					pairs[i] = items[0] + "=" + url.QueryEscape(journalRedactVal)
+					break
+				}
+			}
+		}
+		return strings.Join(pairs, "&")
+	}
+
+	return getRedactFieldsRegexp(fields).ReplaceAllString(body, `${1}"`+journalRedactVal+`"`)
+}
+
+var (
+	redactFieldsKey    string
+	redactFieldsRegexp *regexp.Regexp
+	redactFieldsMutex  sync.Mutex
+)
+
+// getRedactFieldsRegexp returns regexp of json string fields, which is compiled only when fields are changed.
+func getRedactFieldsRegexp(fields []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(fields))
+	for _, field := range fields {
+		quoted = append(quoted, regexp.QuoteMeta(field))
+	}
+	key := strings.Join(quoted, "|")
+
+	redactFieldsMutex.Lock()
+	defer redactFieldsMutex.Unlock()
+	if redactFieldsRegexp == nil || redactFieldsKey != key {
+		// value of the last field may be cut off without closing quote
+		redactFieldsRegexp = regexp.MustCompile(`(?i)("(?:` + key + `)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+		redactFieldsKey = key
+	}
+	return redactFieldsRegexp
+}
+
+// finish records response and adds entry to journal.
+func (j *requestJournal) finish(entry *JournalEntry, jw *journalResponseWriter, start time.Time) {
+	entry.Status = jw.status
+	entry.RespHeaders = redactHeaders(jw.Header())
+	entry.RespBody = redactBody(jw.Header().Get("Content-Type"), jw.body.String())
+	entry.Duration = float64(time.Since(start).Microseconds()) / 1000
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.lastID++
+	entry.ID = j.lastID
+	j.entries = append(j.entries, entry)
+	if size := common.RunConfigs.Server.JournalSize; size > 0 && len(j.entries) > size {
+		j.entries = j.entries[len(j.entries)-size:]
+	}
+}
+
+// list returns entries which id is greater than since.
+func (j *requestJournal) list(since int64, unmatchedOnly bool) []*JournalEntry {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	entries := make([]*JournalEntry, 0, len(j.entries))
+	for _, entry := range j.entries {
+		if entry.ID > since && (!unmatchedOnly || entry.Unmatched) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (j *requestJournal) get(id int64) *JournalEntry {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	for _, entry := range j.entries {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (j *requestJournal) reset() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.entries = nil
+}
+
+// restore replaces entries, and next id continues from the last entry.
+func (j *requestJournal) restore(entries []*JournalEntry) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.entries = entries
+	if len(entries) > 0 && entries[len(entries)-1].ID > j.lastID {
+		j.lastID = entries[len(entries)-1].ID
+	}
+}
+
+// markUnmatched marks journal entry of request as unmatched with closest stubs.
+func markUnmatched(r *http.Request, misses []NearMiss) {
+	if entry, ok := r.Context().Value(journalKey{}).(*JournalEntry); ok {
+		entry.Unmatched = true
+		entry.NearMisses = misses
+	}
+}
+
+// JournalHandler lists, gets or clears recorded requests.
+// GET /journal?since=0&unmatched=true
+// GET /journal/:id
+// DELETE /journal
+func JournalHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	switch r.Method {
+	case "GET":
+		if idText := params.ByName("id"); len(idText) > 0 {
+			id, err := strconv.ParseInt(idText, 10, 64)
+			if err != nil {
+				common.WriteErrJSONResp(w, http.StatusBadRequest, "invalid journal id: "+idText)
+				return
+			}
+			entry := journal.get(id)
+			if entry == nil {
+				common.WriteErrJSONResp(w, http.StatusNotFound, "journal not found: "+idText)
+				return
+			}
+			common.WriteOKJSONResp(w, entry)
+			return
+		}
+
+		query := r.URL.Query()
+		since, _ := strconv.ParseInt(query.Get("since"), 10, 64)
+		common.WriteOKJSONResp(w, journal.list(since, query.Get("unmatched") == "true"))
+	case "DELETE":
+		journal.reset()
+		common.WriteOKJSONResp(w, CmdRespJSON{Status: http.StatusOK, Message: "journal is cleared"})
+	default:
+		common.ErrHandler(w, fmt.Errorf("Method not support: %s", r.Method))
+	}
+}
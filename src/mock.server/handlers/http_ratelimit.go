@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"src/mock.server/common"
+)
+
+/* Http Rate Limit and Quota */
+
+const limiterIdleTimeout = 10 * time.Minute
+
+// clientLimit token bucket and quota window state of a client.
+type clientLimit struct {
+	tokens      float64
+	lastRefill  time.Time
+	quotaUsed   int
+	windowStart time.Time
+	lastSeen    time.Time
+}
+
+// RateLimiter limits requests by rate limit rules, and state is kept per rule and client key.
+type RateLimiter struct {
+	mutex     sync.Mutex
+	clients   map[string]*clientLimit
+	lastSweep time.Time
+}
+
+// NewRateLimiter returns a RateLimiter instance.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		clients:   make(map[string]*clientLimit),
+		lastSweep: time.Now(),
+	}
+}
+
+// limitResult result of a rule check for a request.
+type limitResult struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      int
+	retryAfter int
+	quotaLimit int
+	quotaLeft  int
+	quotaReset int
+	reason     string
+}
+
+// Allow checks request by all matched rules, writes rate limit headers, and writes 429 response
+// and returns false if request is limited. Tokens and quotas are taken only if all matched rules allow.
+func (l *RateLimiter) Allow(w http.ResponseWriter, r *http.Request) bool {
+	now := time.Now()
+	results := make([]*limitResult, 0, 1)
+	var limited *limitResult
+	var limitedKey string
+
+	l.mutex.Lock()
+	l.sweep(now)
+	clients := make([]*clientLimit, 0, 1)
+	rules := make([]*common.RateLimitRule, 0, 1)
+	for i := range common.RunConfigs.RateLimits {
+		rule := &common.RunConfigs.RateLimits[i]
+		if !strings.HasPrefix(r.URL.Path, rule.Path) {
+			continue
+		}
+		key := getLimitStateKey(rule, r)
+		client := l.getClient(key, rule, now)
+		ret := client.check(rule, now)
+		if !ret.allowed && limited == nil {
+			limited, limitedKey = ret, key
+		}
+		clients = append(clients, client)
+		rules = append(rules, rule)
+		results = append(results, ret)
+	}
+	if limited == nil {
+		for i, client := range clients {
+			client.take(rules[i], results[i])
+		}
+	}
+	l.mutex.Unlock()
+
+	for _, ret := range results {
+		setLimitHeaders(w, ret)
+	}
+	if limited != nil {
+		log.Printf("rate limited for %s (key=%s): %s\n", r.URL.Path, limitedKey, limited.reason)
+		w.Header().Set("Retry-After", strconv.Itoa(limited.retryAfter))
+		common.WriteErrJSONResp(w, http.StatusTooManyRequests, limited.reason)
+		return false
+	}
+	return true
+}
+
+// getLimitStateKey returns key of limit state by rule (instead of its index, so state is kept when rules are
+// reordered) and client key of request.
+func getLimitStateKey(rule *common.RateLimitRule, r *http.Request) string {
+	return fmt.Sprintf("%s|%s|%g/%d|%d/%d|%s", rule.Path, rule.Key, rule.Rate, rule.Burst, rule.Quota, rule.Window, getLimitKey(rule, r))
+}
+
+func getBurst(rule *common.RateLimitRule) int {
+	if rule.Burst > 0 {
+		return rule.Burst
+	}
+	return int(math.Max(1, math.Ceil(rule.Rate)))
+}
+
+// getClient returns limit state of key, and must be called with limiter locked.
+func (l *RateLimiter) getClient(key string, rule *common.RateLimitRule, now time.Time) *clientLimit {
+	client, ok := l.clients[key]
+	if !ok {
+		client = &clientLimit{tokens: float64(getBurst(rule)), lastRefill: now, windowStart: now}
+		l.clients[key] = client
+	}
+	client.lastSeen = now
+	return client
+}
+
+// check refills token bucket and resets quota window, and returns whether a request is allowed without taking it.
+func (client *clientLimit) check(rule *common.RateLimitRule, now time.Time) *limitResult {
+	ret := &limitResult{allowed: true}
+	// token bucket
+	if rule.Rate > 0 {
+		burst := getBurst(rule)
+		elapsed := now.Sub(client.lastRefill).Seconds()
+		client.tokens = math.Min(float64(burst), client.tokens+elapsed*rule.Rate)
+		client.lastRefill = now
+
+		ret.limit = burst
+		if client.tokens < 1 {
+			ret.allowed = false
+			ret.retryAfter = int(math.Ceil((1 - client.tokens) / rule.Rate))
+			ret.reason = fmt.Sprintf("rate limit exceeded: %g requests per second", rule.Rate)
+		}
+		ret.remaining = int(math.Floor(client.tokens))
+		ret.reset = int(math.Ceil((float64(burst) - client.tokens) / rule.Rate))
+	}
+
+	// quota window
+	if rule.Quota > 0 && rule.Window > 0 {
+		window := time.Duration(rule.Window) * time.Second
+		if now.Sub(client.windowStart) >= window {
+			client.windowStart = now
+			client.quotaUsed = 0
+		}
+		ret.quotaLimit = rule.Quota
+		ret.quotaReset = int(math.Ceil(client.windowStart.Add(window).Sub(now).Seconds()))
+		if ret.allowed && client.quotaUsed >= rule.Quota {
+			ret.allowed = false
+			ret.retryAfter = ret.quotaReset
+			ret.reason = fmt.Sprintf("quota exceeded: %d requests per %d seconds", rule.Quota, rule.Window)
+		}
+		ret.quotaLeft = rule.Quota - client.quotaUsed
+	}
+	return ret
+}
+
+// take takes a token from bucket and a request from quota window checked by check, and updates result.
+func (client *clientLimit) take(rule *common.RateLimitRule, ret *limitResult) {
+	if rule.Rate > 0 {
+		client.tokens--
+		ret.remaining = int(math.Floor(client.tokens))
+		ret.reset = int(math.Ceil((float64(getBurst(rule)) - client.tokens) / rule.Rate))
+	}
+	if rule.Quota > 0 && rule.Window > 0 {
+		client.quotaUsed++
+		ret.quotaLeft = rule.Quota - client.quotaUsed
+	}
+}
+
+// sweep removes idle clients, and must be called with limiter locked.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, client := range l.clients {
+		if now.Sub(client.lastSeen) > limiterIdleTimeout {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// getLimitKey returns client key of request by rule key: "ip" (default), "header:<name>", "query:<name>" or "cookie:<name>".
+func getLimitKey(rule *common.RateLimitRule, r *http.Request) string {
+	kind, name := rule.Key, ""
+	if idx := strings.Index(rule.Key, ":"); idx > 0 {
+		kind, name = rule.Key[:idx], rule.Key[idx+1:]
+	}
+
+	switch kind {
+	case "header":
+		return r.Header.Get(name)
+	case "query":
+		return r.URL.Query().Get(name)
+	case "cookie":
+		if cookie, err := r.Cookie(name); err == nil {
+			return cookie.Value
+		}
+		return ""
+	default:
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+func setLimitHeaders(w http.ResponseWriter, ret *limitResult) {
+	header := w.Header()
+	if ret.limit > 0 {
+		header.Set("X-RateLimit-Limit", strconv.Itoa(ret.limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(ret.remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(ret.reset))
+	}
+	if ret.quotaLimit > 0 {
+		header.Set("X-Quota-Limit", strconv.Itoa(ret.quotaLimit))
+		header.Set("X-Quota-Remaining", strconv.Itoa(ret.quotaLeft))
+		header.Set("X-Quota-Reset", strconv.Itoa(ret.quotaReset))
+	}
+}
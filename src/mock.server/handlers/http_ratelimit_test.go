@@ -0,0 +1,103 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"src/mock.server/common"
+	"src/mock.server/handlers"
+
+	"github.com/golib/httprouter"
+)
+
+func setRateLimits(t *testing.T, rules ...common.RateLimitRule) {
+	setRunConfigs(t, func(configs *common.Configs) {
+		configs.RateLimits = rules
+	})
+}
+
+func serveLimited(handler httprouter.Handle, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/limited/api", nil)
+	if len(apiKey) > 0 {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req, nil)
+	return rr
+}
+
+func TestRateLimit(t *testing.T) {
+	setRateLimits(t, common.RateLimitRule{Path: "/limited", Key: "header:X-Api-Key", Rate: 0.01, Burst: 2})
+	handler := handlers.NewHooks().RunHooks(handlers.MockDefault)
+
+	t.Log("Case01: requests in burst are allowed.")
+	for i := 0; i < 2; i++ {
+		rr := serveLimited(handler, "key01")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code: %d", rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("Unexpected X-RateLimit-Limit: %s", rr.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+
+	t.Log("Case02: request over burst is limited.")
+	rr := serveLimited(handler, "key01")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Unexpected status code: %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" || rr.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Unexpected headers: %v", rr.Header())
+	}
+
+	t.Log("Case03: bucket is kept per client key.")
+	if rr := serveLimited(handler, "key02"); rr.Code != http.StatusOK {
+		t.Errorf("Unexpected status code: %d", rr.Code)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	setRateLimits(t, common.RateLimitRule{Path: "/limited", Quota: 3, Window: 60})
+	handler := handlers.NewHooks().RunHooks(handlers.MockDefault)
+
+	t.Log("Case01: requests in quota are allowed.")
+	for i := 0; i < 3; i++ {
+		if rr := serveLimited(handler, ""); rr.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code: %d", rr.Code)
+		}
+	}
+
+	t.Log("Case02: request over quota is limited until window reset.")
+	rr := serveLimited(handler, "")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Unexpected status code: %d", rr.Code)
+	}
+	if rr.Header().Get("X-Quota-Remaining") != "0" || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Unexpected headers: %v", rr.Header())
+	}
+}
+
+func TestRateLimitMultiRules(t *testing.T) {
+	quota := common.RateLimitRule{Path: "/limited", Quota: 10, Window: 60}
+	rate := common.RateLimitRule{Path: "/limited/api", Rate: 0.01, Burst: 1}
+	setRateLimits(t, quota, rate)
+	handler := handlers.NewHooks().RunHooks(handlers.MockDefault)
+
+	t.Log("Case01: quota is not taken by request limited by later rule.")
+	if rr := serveLimited(handler, ""); rr.Code != http.StatusOK || rr.Header().Get("X-Quota-Remaining") != "9" {
+		t.Fatalf("Unexpected response: %d, %v", rr.Code, rr.Header())
+	}
+	for i := 0; i < 3; i++ {
+		rr := serveLimited(handler, "")
+		if rr.Code != http.StatusTooManyRequests || rr.Header().Get("X-Quota-Remaining") != "9" {
+			t.Fatalf("Unexpected response: %d, %v", rr.Code, rr.Header())
+		}
+	}
+
+	t.Log("Case02: state is kept when rules are reordered.")
+	common.RunConfigs.RateLimits = []common.RateLimitRule{rate, quota}
+	if rr := serveLimited(handler, ""); rr.Code != http.StatusTooManyRequests || rr.Header().Get("X-Quota-Remaining") != "9" {
+		t.Errorf("Unexpected response: %d, %v", rr.Code, rr.Header())
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"net/http"
+
 	"src/mock.server/common"
 
 	"github.com/golib/httprouter"
@@ -18,19 +20,23 @@ type RouterEntry struct {
 func NewHTTPRouter() *httprouter.Router {
 	routers := make([]RouterEntry, 0, 10)
 
-	// Issue: router matcher do not support regexp
-	if !common.IsProd() {
-		routers = append(routers, RouterEntry{"MockDefault", "OPTIONS", "/ping", MockDefault})
-		// mock api
-		routers = append(routers, RouterEntry{"MockAPIRegister", "OPTIONS", "/mock/register/:uri", MockAPIRegisterHandler})
-		routers = append(routers, RouterEntry{"MockAPI", "OPTIONS", "/mock/api/:uri", MockAPIHandler})
-	}
-
 	routers = append(routers, RouterEntry{"MockDefault", "GET", "/ping", MockDefault})
+	// probes
+	routers = append(routers, RouterEntry{"Healthz", "GET", "/healthz", HealthzHandler})
+	routers = append(routers, RouterEntry{"Readyz", "GET", "/readyz", ReadyzHandler})
 	// mock api
 	routers = append(routers, RouterEntry{"MockAPIRegister", "POST", "/mock/register/:uri", MockAPIRegisterHandler})
 	routers = append(routers, RouterEntry{"MockAPI", "GET", "/mock/api/:uri", MockAPIHandler})
 	routers = append(routers, RouterEntry{"MockAPI", "POST", "/mock/api/:uri", MockAPIHandler})
+	routers = append(routers, RouterEntry{"MockStubs", "GET", "/mock/stubs", MockStubsHandler})
+	routers = append(routers, RouterEntry{"MockStubs", "GET", "/mock/stubs/:uri", MockStubsHandler})
+	routers = append(routers, RouterEntry{"MockStubs", "DELETE", "/mock/stubs/:uri", MockStubsHandler})
+	routers = append(routers, RouterEntry{"MockTreeFile", "PUT", "/mock/tree/*path", MockTreeFileHandler})
+	routers = append(routers, RouterEntry{"MockUploadRegister", "POST", "/mock/register/:uri/upload", MockUploadRegisterHandler})
+	routers = append(routers, RouterEntry{"MockResponseRegister", "POST", "/mock/register/:uri/response", MockResponseRegisterHandler})
+	routers = append(routers, RouterEntry{"MockUploads", "GET", "/mock/uploads/:uri", MockUploadsHandler})
+	routers = append(routers, RouterEntry{"MockUploads", "GET", "/mock/uploads/:uri/:id", MockUploadsHandler})
+	routers = append(routers, RouterEntry{"MockUploads", "DELETE", "/mock/uploads/:uri", MockUploadsHandler})
 
 	// mock demo
 	routers = append(routers, RouterEntry{"MockDemo", "GET", "/demo/:id", MockDemoHandler})
@@ -40,15 +46,51 @@ func NewHTTPRouter() *httprouter.Router {
 	routers = append(routers, RouterEntry{"MockTestPart2", "GET", "/mocktest/two/:id", MockTestHandler02})
 	// mock qiniu
 	routers = append(routers, RouterEntry{"MockQiNiuTest", "GET", "/mockqiniu/:id", MockQiNiuHandler})
+	// mock auth
+	routers = append(routers, RouterEntry{"MockOIDCConfig", "GET", "/.well-known/openid-configuration", MockOIDCConfigHandler})
+	routers = append(routers, RouterEntry{"MockOAuth", "GET", "/oauth/:name", MockOAuthHandler})
+	routers = append(routers, RouterEntry{"MockOAuth", "POST", "/oauth/:name", MockOAuthHandler})
+	routers = append(routers, RouterEntry{"MockSession", "GET", "/session/:action", MockSessionHandler})
+	routers = append(routers, RouterEntry{"MockSession", "POST", "/session/:action", MockSessionHandler})
+	// scenarios
+	routers = append(routers, RouterEntry{"Scenario", "GET", "/scenarios", ScenarioHandler})
+	routers = append(routers, RouterEntry{"Scenario", "POST", "/scenarios/:name", ScenarioHandler})
+	routers = append(routers, RouterEntry{"Scenario", "DELETE", "/scenarios", ScenarioHandler})
+	routers = append(routers, RouterEntry{"Scenario", "DELETE", "/scenarios/:name", ScenarioHandler})
+	// dashboard
+	routers = append(routers, RouterEntry{"Dashboard", "GET", "/dashboard", DashboardHandler})
+	routers = append(routers, RouterEntry{"Journal", "GET", "/journal", JournalHandler})
+	routers = append(routers, RouterEntry{"Journal", "GET", "/journal/:id", JournalHandler})
+	routers = append(routers, RouterEntry{"Journal", "DELETE", "/journal", JournalHandler})
+	// traffic mirror
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"} {
+		routers = append(routers, RouterEntry{"Mirror", method, "/mirror/*path", MirrorHandler})
+	}
+	routers = append(routers, RouterEntry{"MirrorReport", "GET", "/mirrors/report", MirrorReportHandler})
+	routers = append(routers, RouterEntry{"MirrorReport", "DELETE", "/mirrors/report", MirrorReportHandler})
+	// chaos proxy
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"} {
+		routers = append(routers, RouterEntry{"ChaosProxy", method, "/chaos/*path", ChaosProxyHandler})
+	}
 	// tools
 	routers = append(routers, RouterEntry{"Tools", "POST", "/tools/:name", ToolsHandler})
+	routers = append(routers, RouterEntry{"ToolsJobs", "GET", "/tools/jobs", ToolsJobsHandler})
+	routers = append(routers, RouterEntry{"ToolsJobs", "GET", "/tools/jobs/:id", ToolsJobsHandler})
+	routers = append(routers, RouterEntry{"ToolsJobs", "GET", "/tools/jobs/:id/:action", ToolsJobsHandler})
+	routers = append(routers, RouterEntry{"ToolsJobs", "DELETE", "/tools/jobs/:id", ToolsJobsHandler})
 
+	routeEntries = routers
 	router := httprouter.New()
+	// OPTIONS requests are served by hooks with cors policy, instead of router
+	router.HandleMethodOPTIONS = false
+	// request with not allowed method falls through to mock tree, and then not found with near misses
+	router.HandleMethodNotAllowed = false
 	hooks := NewHooks()
 	for _, route := range routers {
 		router.Handle(route.Method, route.Path, hooks.RunHooks(route.HandlerFunc))
 	}
-	router.NotFound = WrapHandlerFunc(hooks.RunHooks(MockNotFound))
+	router.ServeFiles("/static/*filepath", http.Dir(common.RunConfigs.Server.StaticDir))
+	router.NotFound = WrapHandlerFunc(hooks.RunHooks(MockTreeHandler))
 
 	return router
 }
@@ -0,0 +1,25 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"src/mock.server/common"
+	"src/mock.server/handlers"
+)
+
+func serveRouter(req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handlers.NewHTTPRouter().ServeHTTP(rr, req)
+	return rr
+}
+
+// setRunConfigs updates global configs by fn, and restores them when test and its subtests finish.
+func setRunConfigs(t *testing.T, fn func(configs *common.Configs)) {
+	origin := common.RunConfigs
+	t.Cleanup(func() {
+		common.RunConfigs = origin
+	})
+	fn(&common.RunConfigs)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"src/mock.server/common"
+	myutils "src/tools.app/utils"
+)
+
+/* Server State: in-memory state persisted to state file on shutdown, and loaded on start. */
+
+// ServerState in-memory state of mock server.
+type ServerState struct {
+	Scenarios map[string]string `json:"scenarios"`
+	Journal   []*JournalEntry   `json:"journal"`
+	// Counters counts of memory counter, and file or redis counter persists itself.
+	Counters map[string]int64 `json:"counters,omitempty"`
+}
+
+// memoryCounterDumper dumps and restores counts of memory counter.
+type memoryCounterDumper interface {
+	Dump() map[string]int64
+	Restore(counts map[string]int64)
+}
+
+func getMemoryCounter() (memoryCounterDumper, bool) {
+	if backend := common.RunConfigs.Server.Counter; len(backend) > 0 && backend != common.CounterMemory {
+		return nil, false
+	}
+	counter, err := common.GetCounter()
+	if err != nil {
+		return nil, false
+	}
+	dumper, ok := counter.(memoryCounterDumper)
+	return dumper, ok
+}
+
+// SaveState writes in-memory state to file atomically, and old state is kept if failed.
+func SaveState(path string) error {
+	state := &ServerState{
+		Scenarios: scenarios.all(),
+		Journal:   journal.list(0, false),
+	}
+	if counter, ok := getMemoryCounter(); ok {
+		state.Counters = counter.Dump()
+	}
+
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return myutils.WriteFileAtomic(path, b, 0644)
+}
+
+// LoadState restores in-memory state from file, and nothing is loaded if file not exist.
+func LoadState(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	state := &ServerState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return fmt.Errorf("invalid state file (%s): %v", path, err)
+	}
+
+	scenarios.reset("")
+	for name, value := range state.Scenarios {
+		scenarios.set(name, value)
+	}
+	journal.restore(state.Journal)
+	if counter, ok := getMemoryCounter(); ok && state.Counters != nil {
+		counter.Restore(state.Counters)
+	}
+	return nil
+}
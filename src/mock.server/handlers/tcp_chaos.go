@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"src/mock.server/common"
+)
+
+/* Chaos tcp proxy: connections are forwarded to upstream with faults. */
+
+// ChaosTCPProxy forwards tcp connections from listen address to upstream.
+type ChaosTCPProxy struct {
+	cfg      common.TCPProxy
+	listener net.Listener
+}
+
+// StartChaosTCPProxies starts tcp proxies in configs.
+func StartChaosTCPProxies() ([]*ChaosTCPProxy, error) {
+	proxies := make([]*ChaosTCPProxy, 0, len(common.RunConfigs.Chaos.TCPProxies))
+	for _, cfg := range common.RunConfigs.Chaos.TCPProxies {
+		proxy, err := NewChaosTCPProxy(cfg)
+		if err != nil {
+			for _, p := range proxies {
+				p.Close()
+			}
+			return nil, err
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+// NewChaosTCPProxy listens and serves a tcp proxy.
+func NewChaosTCPProxy(cfg common.TCPProxy) (*ChaosTCPProxy, error) {
+	if len(cfg.Upstream) == 0 {
+		return nil, fmt.Errorf("upstream of tcp proxy (%s) is empty", cfg.Listen)
+	}
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := &ChaosTCPProxy{cfg: cfg, listener: listener}
+	log.Printf("Chaos tcp proxy listen on %s, upstream %s.\n", listener.Addr(), cfg.Upstream)
+	go proxy.serve()
+	return proxy, nil
+}
+
+// Addr returns listen address of proxy.
+func (p *ChaosTCPProxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Close stops accepting connections.
+func (p *ChaosTCPProxy) Close() error {
+	return p.listener.Close()
+}
+
+func (p *ChaosTCPProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			log.Println("chaos tcp proxy stopped:", err)
+			return
+		}
+		go p.handleConn(conn)
+	}
+}
+
+func (p *ChaosTCPProxy) handleConn(conn net.Conn) {
+	upstream, err := net.DialTimeout("tcp", p.cfg.Upstream, 10*time.Second)
+	if err != nil {
+		log.Println("chaos tcp proxy dial upstream failed:", err)
+		conn.Close()
+		return
+	}
+
+	fault := &chaosFault{p.cfg.ChaosFault}
+	if !fault.hit() {
+		fault = nil
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// client => upstream
+		err := copyWithFault(upstream, conn, fault, false)
+		switch err {
+		case nil:
+			// client half-closed, and reply of upstream is still forwarded
+			closeWrite(upstream)
+		case errChaosReset:
+			log.Println("chaos tcp proxy reset connection from:", conn.RemoteAddr())
+			resetConn(conn)
+			resetConn(upstream)
+		default:
+			conn.Close()
+			upstream.Close()
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		// upstream => client
+		if err := copyWithFault(conn, upstream, fault, true); err == nil {
+			closeWrite(conn)
+		} else {
+			// truncated or failed
+			conn.Close()
+			upstream.Close()
+		}
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+	conn.Close()
+	upstream.Close()
+}
+
+// closeWrite half-closes tcp connection, so peer reads EOF while the other direction is still open.
+func closeWrite(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.CloseWrite()
+		return
+	}
+	conn.Close()
+}
+
+var (
+	errChaosReset    = fmt.Errorf("chaos reset")
+	errChaosTruncate = fmt.Errorf("chaos truncate")
+)
+
+// copyWithFault copies data with latency for each read and bandwidth limit, and truncate is applied to response only.
+// It returns errChaosReset after first read if reset fault is applied to request, and errChaosTruncate
+// after response is truncated. Nil is returned when src is EOF.
+func copyWithFault(dst io.Writer, src io.Reader, fault *chaosFault, isResp bool) error {
+	if fault == nil {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+
+	tw := &throttledWriter{w: dst, bandwidth: fault.Bandwidth}
+	var sent int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if !isResp && fault.Reset {
+				return errChaosReset
+			}
+			if d := fault.delay(); d > 0 {
+				time.Sleep(d)
+			}
+
+			data := buf[:n]
+			truncated := false
+			if isResp && fault.Truncate > 0 && sent+int64(n) >= fault.Truncate {
+				data = data[:fault.Truncate-sent]
+				truncated = true
+			}
+			if _, err := tw.Write(data); err != nil {
+				return err
+			}
+			sent += int64(len(data))
+			if truncated {
+				return errChaosTruncate
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"src/mock.server/common"
 	"src/mock.server/handlers"
+	"src/tools.app/utils/logging"
 )
 
 func init() {
@@ -18,13 +25,80 @@ func init() {
 func main() {
 	help := flag.Bool("h", false, "help.")
 	port := flag.String("p", "17891", "mock server listening port.")
+	dir := flag.String("dir", "", "mock tree dir, files in which are served as apis, like mocks/GET/v1/users/{id}.json.")
+	logFlags := logging.RegisterFlags(flag.CommandLine)
 
 	flag.Parse()
 	if *help {
 		flag.Usage()
 	}
 
-	router := handlers.NewHTTPRouter()
-	log.Printf("Mock Server start, and listen on %s.\n", *port)
-	log.Fatal(http.ListenAndServe(":"+*port, router))
+	if err := logFlags.Init(&common.RunConfigs.Log); err != nil {
+		log.Fatalln(err)
+	}
+	defer logging.Close()
+	logger := logging.Get("mock")
+	// logs of handlers by standard log package
+	logging.RedirectStdLog(logger)
+
+	if len(*dir) > 0 {
+		if err := handlers.LoadMockTree(*dir); err != nil {
+			logger.Fatal("load mock tree failed", "err", err)
+		}
+	}
+	stateFile := common.RunConfigs.Server.StateFile
+	if len(stateFile) > 0 {
+		if err := handlers.LoadState(stateFile); err != nil {
+			logger.Fatal("load state failed", "err", err)
+		}
+	}
+
+	proxies, err := handlers.StartChaosTCPProxies()
+	if err != nil {
+		logger.Fatal("start chaos proxies failed", "err", err)
+	}
+
+	listener, err := net.Listen("tcp", ":"+*port)
+	if err != nil {
+		logger.Fatal("listen failed", "err", err)
+	}
+	server := &http.Server{Handler: handlers.NewHTTPRouter(), ErrorLog: logger.StdLogger(logging.ErrorLevel)}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("serve failed", "err", err)
+		}
+	}()
+	handlers.SetReady(true)
+	logger.Info("Mock Server start.", "port", *port)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigCh
+	logger.Info("Receive signal, and mock server is shutting down.", "signal", sig)
+	handlers.SetReady(false)
+
+	timeout := time.Duration(common.RunConfigs.Server.ShutdownTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("drain in-flight requests failed", "err", err)
+		server.Close()
+	}
+	for _, proxy := range proxies {
+		proxy.Close()
+	}
+
+	if len(stateFile) > 0 {
+		if err := handlers.SaveState(stateFile); err != nil {
+			logger.Error("save state failed", "err", err)
+		} else {
+			logger.Info("state is saved", "file", stateFile)
+		}
+	}
+	if counter, err := common.GetCounter(); err == nil {
+		if err := counter.Close(); err != nil {
+			logger.Error("close counter failed", "err", err)
+		}
+	}
+	logger.Info("Mock Server exit.")
 }
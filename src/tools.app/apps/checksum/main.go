@@ -0,0 +1,90 @@
+// Checksum tool: 1) print checksums of files as manifest; 2) verify files of manifest in parallel.
+// Algorithms: md5, sha1, sha256, crc32, xxhash and qetag (qiniu etag).
+//
+// Build: ./gorun.sh tool checksum
+//
+// Usage:
+// Print manifest: ./checksum -a sha256 file1 file2 > files.sha256
+// Verify manifest: ./checksum -a sha256 -c files.sha256 -w 8
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	myutils "src/tools.app/utils"
+)
+
+var (
+	h = flag.Bool("h", false, "help.")
+	a = flag.String("a", myutils.HashSHA256, "hash algorithm: md5, sha1, sha256, crc32, xxhash or qetag.")
+	c = flag.String("c", "", "manifest file to verify, and paths in it are relative to dir of manifest.")
+	w = flag.Int("w", 0, "number of workers to verify files, default is number of cpus.")
+	q = flag.Bool("q", false, "only print failed files when verify.")
+)
+
+func main() {
+	flag.Parse()
+	if *h || (len(*c) == 0 && flag.NArg() == 0) {
+		flag.Usage()
+		return
+	}
+
+	if len(*c) > 0 {
+		os.Exit(verify(*c))
+	}
+	os.Exit(printSums(flag.Args()))
+}
+
+func printSums(paths []string) int {
+	entries := make([]myutils.ChecksumEntry, len(paths))
+	for i, path := range paths {
+		entries[i].Path = path
+	}
+	// empty sums never match, so actual sums of all files are returned
+	results, err := myutils.VerifyChecksums(entries, "", *a, *w)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	code := 0
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintln(os.Stderr, result.Err)
+			code = 1
+			continue
+		}
+		fmt.Println(myutils.FormatChecksumEntry(myutils.ChecksumEntry{Path: result.Path, Sum: result.Actual}))
+	}
+	return code
+}
+
+func verify(manifest string) int {
+	results, err := myutils.VerifyChecksumManifest(manifest, *a, *w)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed++
+			fmt.Printf("%s: FAILED open or read (%v)\n", result.Path, result.Err)
+		case !result.OK():
+			failed++
+			fmt.Printf("%s: FAILED\n", result.Path)
+		case !*q:
+			fmt.Printf("%s: OK\n", result.Path)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %d of %d computed checksums did NOT match\n", failed, len(results))
+		return 1
+	}
+	return 0
+}
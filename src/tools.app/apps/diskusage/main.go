@@ -5,15 +5,19 @@
 // Usage:
 // Dir space usage: ./diskusage -u -p $(pwd)
 // Print dir tree map: ./diskusage -t -p $(pwd) -l 2
+// Dir usage report: ./diskusage -f json -p $(pwd) -l 2 -a > report.json
+// Top 20 largest files and dirs: ./diskusage -top 20 -p $(pwd) [-f json]
 
 package main
 
 import (
 	"flag"
 	"fmt"
+	"os"
 
 	mysvc "src/tools.app/services/diskusage"
 	myutils "src/tools.app/utils"
+	"src/tools.app/utils/logging"
 )
 
 var (
@@ -22,6 +26,11 @@ var (
 	t = flag.Bool("t", false, "flag print dir tree map, default path=cur_dir and level=1.")
 	l = flag.Int("l", 1, "level for dir tree map to print.")
 	p = flag.String("p", myutils.GetCurPath(), "specified abs dir path.")
+	f = flag.String("f", "", "output format of dir usage report to stdout: json, ndjson or csv, and level is report depth.")
+	a = flag.Bool("a", false, "flag to include files in dir usage report, default only dirs.")
+	n = flag.Int("top", 0, "print top n largest files and dirs, in text or json by -f.")
+
+	logFlags = logging.RegisterFlags(flag.CommandLine)
 )
 
 func main() {
@@ -30,9 +39,39 @@ func main() {
 		flag.Usage()
 		return
 	}
+	if err := logFlags.Init(nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer logging.Close()
 
-	fmt.Println("Run disk tools.")
 	diskUsage := mysvc.NewDiskUsage()
+	if *n > 0 {
+		report, err := diskUsage.TopN(*p, *n)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := mysvc.WriteTopReport(os.Stdout, report, *f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(*f) > 0 {
+		root, err := diskUsage.Scan(*p, mysvc.ScanOptions{MaxDepth: *l, Files: *a})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := mysvc.WriteUsage(os.Stdout, root, *f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println("Run disk tools.")
 	if *u {
 		if err := diskUsage.PrintDirDiskUsage(*p); err != nil {
 			panic(err)
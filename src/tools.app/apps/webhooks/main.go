@@ -11,8 +11,7 @@ import (
 	"syscall"
 
 	mysvc "src/tools.app/services/webhooks"
-
-	"github.com/golang/glog"
+	"src/tools.app/utils/logging"
 )
 
 func main() {
@@ -22,11 +21,19 @@ func main() {
 	flag.IntVar(&parameters.Port, "port", 443, "Webhook server port.")
 	flag.StringVar(&parameters.CertFile, "tlsCertFile", "/etc/webhook/certs/cert.pem", "File containing the x509 Certificate for HTTPS.")
 	flag.StringVar(&parameters.KeyFile, "tlsKeyFile", "/etc/webhook/certs/key.pem", "File containing the x509 private key to --tlsCertFile.")
+	logFlags := logging.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
+	if err := logFlags.Init(nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer logging.Close()
+	logger := logging.Get("webhooks")
+
 	pair, err := tls.LoadX509KeyPair(parameters.CertFile, parameters.KeyFile)
 	if err != nil {
-		glog.Errorf("Failed to load key pair: %v", err)
+		logger.Errorf("Failed to load key pair: %v", err)
 	}
 
 	whsvr := &mysvc.WebhookServer{
@@ -45,17 +52,17 @@ func main() {
 	// start webhook server in new routine
 	go func() {
 		if err := whsvr.Server.ListenAndServeTLS("", ""); err != nil {
-			glog.Errorf("Failed to listen and serve webhook server: %v", err)
+			logger.Errorf("Failed to listen and serve webhook server: %v", err)
 		}
 	}()
 
-	glog.Info("Server started")
+	logger.Info("Server started")
 
 	// listening OS shutdown singal
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 	<-signalChan
 
-	glog.Infof("Got OS shutdown signal, shutting down webhook server gracefully...")
+	logger.Info("Got OS shutdown signal, shutting down webhook server gracefully...")
 	whsvr.Server.Shutdown(context.Background())
 }
@@ -1,46 +1,29 @@
 package util
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
-)
 
-// RespBody 通用结构体用于装载返回的数据
-type RespBody struct {
-	Code  int         `json:"code"`
-	Rows  interface{} `json:"rows,omitempty"`
-	Data  interface{} `json:"data,omitempty"`
-	Msg   string      `json:"msg,omitempty"`
-	Total interface{} `json:"total,omitempty"`
-}
+	"src/tools.app/utils/httpresp"
+)
 
 // RespJSON 返回json的底层方法
 func RespJSON(w http.ResponseWriter, data interface{}) {
-	header := w.Header()
-	header.Set("Content-Type", "application/json;charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	ret, err := json.Marshal(data)
-	if err != nil {
-		log.Fatalln(err)
-		w.Write([]byte(err.Error()))
-		return
+	if err := httpresp.WriteJSON(w, nil, http.StatusOK, data); err != nil {
+		log.Println(err)
 	}
-	w.Write(ret)
 }
 
 // RespOK 操作成功返回Ok
 func RespOK(w http.ResponseWriter, data interface{}) {
-	RespJSON(w, &RespBody{
-		Code: http.StatusOK,
-		Data: data,
-	})
+	if err := httpresp.WriteOK(w, nil, data); err != nil {
+		log.Println(err)
+	}
 }
 
-// RespFail 操作失败返回Error
+// RespFail 操作失败返回Error, failCode为http状态码
 func RespFail(w http.ResponseWriter, failCode int, msg string) {
-	RespJSON(w, &RespBody{
-		Code: failCode,
-		Msg:  msg,
-	})
+	if err := httpresp.WriteError(w, nil, httpresp.NewError(failCode, msg)); err != nil {
+		log.Println(err)
+	}
 }
@@ -13,14 +13,22 @@ import (
 	"text/template"
 
 	myutils "src/tools.app/utils"
+	"src/tools.app/utils/logging"
 
 	"github.com/gorilla/websocket"
 )
 
 func main() {
 	addr := flag.String("addr", "localhost:8080", "http service address")
+	logFlags := logging.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
+	if err := logFlags.Init(nil); err != nil {
+		log.Fatalln(err)
+	}
+	defer logging.Close()
+	logging.RedirectStdLog(logging.Get("webshell"))
+
 	http.HandleFunc("/", Home)
 	http.HandleFunc("/echo", EchoMsg)
 
@@ -24,6 +24,7 @@ import (
 
 	k8ssvc "src/tools.app/services/k8sio"
 	wssvc "src/tools.app/services/webshell"
+	"src/tools.app/utils/logging"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -34,9 +35,19 @@ var (
 	kubeConfig  = flag.String("kubeconfig", defaultPath, "abs path to the kubeconfig file")
 	addr        = flag.String("addr", ":8090", "http service address")
 	cmd         = []string{"/bin/sh"}
+
+	logFlags = logging.RegisterFlags(flag.CommandLine)
 )
 
 func main() {
+	flag.Parse()
+	if err := logFlags.Init(nil); err != nil {
+		log.Fatalln(err)
+	}
+	defer logging.Close()
+	// logs of handlers by standard log package
+	logging.RedirectStdLog(logging.Get("webshell"))
+
 	router := mux.NewRouter()
 	router.HandleFunc("/query/ns", getAllNamespaces)
 	router.HandleFunc("/query/pods", getAllPodsByNamespace)
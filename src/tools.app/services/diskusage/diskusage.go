@@ -3,19 +3,20 @@ package diskusage
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
 	myutils "src/tools.app/utils"
+	"src/tools.app/utils/logging"
 )
 
+var logger = logging.Get("diskusage")
+
 // DiskUsage includes disk tools.
 type DiskUsage struct {
 	semaphore chan struct{}
-	wg        sync.WaitGroup
 }
 
 // NewDiskUsage returns a DiskUsage instance.
@@ -74,57 +75,71 @@ func (du *DiskUsage) PrintDirDiskUsage(dirPath string) error {
 	var (
 		filesCount int64
 		bytesCount int64
-		ch         = make(chan int64)
 	)
 
 	if err := du.verifyPath(dirPath); err != nil {
 		return err
 	}
-
-	go func() {
-		du.wg.Add(1)
-		du.walkDir(dirPath, ch)
-		du.wg.Wait()
-		close(ch)
-	}()
+	ch := du.walk(dirPath)
 
 LOOP:
 	for {
 		select {
-		case fSize, ok := <-ch:
+		case f, ok := <-ch:
 			if !ok {
 				break LOOP
 			}
+			if f.err != nil {
+				logger.Warn("read dir failed", "dir", f.dir, "err", f.err)
+				continue
+			}
 			filesCount++
-			bytesCount += fSize
+			bytesCount += f.info.Size()
 		case <-time.Tick(time.Duration(100) * time.Millisecond):
 			du.printSpaceUsage(filesCount, bytesCount)
 		}
 	}
 
-	log.Printf("total files and disk usage size (%s):\n", dirPath)
+	logger.Infof("total files and disk usage size (%s):", dirPath)
 	du.printSpaceUsage(filesCount, bytesCount)
 	return nil
 }
 
-func (du *DiskUsage) walkDir(dirPath string, ch chan<- int64) {
-	defer func() {
-		if p := recover(); p != nil {
-			log.Println("WalkDir routine internal err:", p)
-		}
-		du.wg.Done()
+// walkFile a file sent by walkDir, or error of reading dir if err is not nil.
+type walkFile struct {
+	dir  string
+	info os.FileInfo
+	err  error
+}
+
+// walk walks dir concurrently, and returns channel of files which is closed when all dirs are walked.
+// Dirs failed to read are sent with error, and walk continues.
+func (du *DiskUsage) walk(dirPath string) <-chan walkFile {
+	ch := make(chan walkFile, 64)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go du.walkDir(dirPath, wg, ch)
+	go func() {
+		wg.Wait()
+		close(ch)
 	}()
+	return ch
+}
+
+func (du *DiskUsage) walkDir(dirPath string, wg *sync.WaitGroup, ch chan<- walkFile) {
+	defer wg.Done()
 
 	files, err := du.ListFiles(dirPath)
 	if err != nil {
-		panic(err)
+		ch <- walkFile{dir: dirPath, err: err}
+		return
 	}
 	for _, f := range files {
 		if f.IsDir() {
-			du.wg.Add(1)
-			go du.walkDir(filepath.Join(dirPath, f.Name()), ch)
+			wg.Add(1)
+			go du.walkDir(filepath.Join(dirPath, f.Name()), wg, ch)
 		} else {
-			ch <- f.Size()
+			ch <- walkFile{dir: dirPath, info: f}
 		}
 	}
 }
@@ -145,9 +160,9 @@ func (du *DiskUsage) ListFiles(dirPath string) ([]os.FileInfo, error) {
 func (du *DiskUsage) printSpaceUsage(filesCount, bytesCount int64) {
 	gbytes := float64(bytesCount) / 1e9
 	if gbytes >= 1.0 {
-		log.Printf("%d files\t%.1f GB\n", filesCount, gbytes)
+		logger.Infof("%d files\t%.1f GB", filesCount, gbytes)
 	} else {
-		log.Printf("%d files\t%.1f MB\n", filesCount, float64(bytesCount)/1e6)
+		logger.Infof("%d files\t%.1f MB", filesCount, float64(bytesCount)/1e6)
 	}
 }
 
@@ -0,0 +1,190 @@
+package diskusage
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TopEntry a file or dir in top report.
+type TopEntry struct {
+	Path string `json:"path"`
+	// Size allocated size on disk, and total of files for dir.
+	Size         int64 `json:"size"`
+	ApparentSize int64 `json:"apparent_size"`
+	// FileCount count of files in dir, and it's 0 for file.
+	FileCount int64 `json:"file_count,omitempty"`
+}
+
+// TopReport largest files and dirs under path.
+type TopReport struct {
+	Path         string     `json:"path"`
+	Size         int64      `json:"size"`
+	ApparentSize int64      `json:"apparent_size"`
+	FileCount    int64      `json:"file_count"`
+	Files        []TopEntry `json:"files"`
+	Dirs         []TopEntry `json:"dirs"`
+	// Errors errors of sub dirs failed to read, and files in them are not counted.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// TopN returns n largest files and n largest dirs (total size of files in it, excluding root) under dir
+// in one concurrent walk, and they're sorted by size desc. Error is returned if dir can not be read,
+// and errors of sub dirs are kept in report.
+func (du *DiskUsage) TopN(dirPath string, n int) (*TopReport, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid top number: %d", n)
+	}
+	if err := du.verifyPath(dirPath); err != nil {
+		return nil, err
+	}
+
+	root := filepath.Clean(dirPath)
+	report := &TopReport{Path: root}
+	files := make(topHeap, 0, n+1)
+	dirs := make(map[string]*TopEntry)
+	var rootErr error
+	for f := range du.walk(root) {
+		if f.err != nil {
+			if f.dir == root {
+				rootErr = f.err
+			} else {
+				report.Errors = append(report.Errors, f.err.Error())
+			}
+			continue
+		}
+		file := TopEntry{Path: filepath.Join(f.dir, f.info.Name()), Size: diskSize(f.info), ApparentSize: f.info.Size()}
+		report.Size += file.Size
+		report.ApparentSize += file.ApparentSize
+		report.FileCount++
+
+		heap.Push(&files, file)
+		if len(files) > n {
+			heap.Pop(&files)
+		}
+		// file is counted in all parent dirs under root
+		for dir := f.dir; dir != root; {
+			entry, ok := dirs[dir]
+			if !ok {
+				entry = &TopEntry{Path: dir}
+				dirs[dir] = entry
+			}
+			entry.Size += file.Size
+			entry.ApparentSize += file.ApparentSize
+			entry.FileCount++
+
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	if rootErr != nil {
+		return nil, fmt.Errorf("read dir (%s) failed: %v", root, rootErr)
+	}
+	sort.Strings(report.Errors)
+
+	report.Files = []TopEntry(files)
+	sortTopEntries(report.Files)
+	report.Dirs = make([]TopEntry, 0, len(dirs))
+	for _, entry := range dirs {
+		report.Dirs = append(report.Dirs, *entry)
+	}
+	sortTopEntries(report.Dirs)
+	if len(report.Dirs) > n {
+		report.Dirs = report.Dirs[:n]
+	}
+	return report, nil
+}
+
+func sortTopEntries(entries []TopEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return largerTopEntry(&entries[i], &entries[j])
+	})
+}
+
+// largerTopEntry compares entries by size, apparent size and then path, so order is stable.
+func largerTopEntry(a, b *TopEntry) bool {
+	if a.Size != b.Size {
+		return a.Size > b.Size
+	}
+	if a.ApparentSize != b.ApparentSize {
+		return a.ApparentSize > b.ApparentSize
+	}
+	return a.Path < b.Path
+}
+
+// topHeap min heap of entries by size, so the smallest is popped when over n.
+type topHeap []TopEntry
+
+func (h topHeap) Len() int { return len(h) }
+
+func (h topHeap) Less(i, j int) bool { return largerTopEntry(&h[j], &h[i]) }
+
+func (h topHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *topHeap) Push(x interface{}) { *h = append(*h, x.(TopEntry)) }
+
+func (h *topHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// WriteTopReport writes top report as format: "text" (human readable, default) or "json".
+func WriteTopReport(w io.Writer, report *TopReport, format string) error {
+	switch strings.ToLower(format) {
+	case "", "text":
+		return writeTopReportText(w, report)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+func writeTopReportText(w io.Writer, report *TopReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d files, %s (apparent %s)\n",
+		report.Path, report.FileCount, HumanSize(report.Size), HumanSize(report.ApparentSize))
+	fmt.Fprintf(&b, "\nTop %d largest files:\n", len(report.Files))
+	for _, entry := range report.Files {
+		fmt.Fprintf(&b, "%8s  %s\n", HumanSize(entry.Size), entry.Path)
+	}
+	fmt.Fprintf(&b, "\nTop %d largest dirs:\n", len(report.Dirs))
+	for _, entry := range report.Dirs {
+		fmt.Fprintf(&b, "%8s  %8d files  %s\n", HumanSize(entry.Size), entry.FileCount, entry.Path)
+	}
+	if len(report.Errors) > 0 {
+		fmt.Fprintf(&b, "\n%d dirs failed to read, and files in them are not counted:\n", len(report.Errors))
+		for _, e := range report.Errors {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// HumanSize returns size in binary units like "du -h", e.g. "512B", "4.0K" and "1.5G".
+func HumanSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return fmt.Sprintf("%dB", size)
+	}
+	value := float64(size)
+	idx := -1
+	for value >= 1024 && idx < len(units)-1 {
+		value /= 1024
+		idx++
+	}
+	return fmt.Sprintf("%.1f%c", value, units[idx])
+}
@@ -0,0 +1,142 @@
+package diskusage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	mysvc "src/tools.app/services/diskusage"
+)
+
+func TestTopN(t *testing.T) {
+	dir := createUsageTestDir(t)
+	du := mysvc.NewDiskUsage()
+
+	t.Log("Case01: top 2 largest files and dirs.")
+	report, err := du.TopN(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FileCount != 5 || report.ApparentSize != 5160 {
+		t.Fatalf("unexpected total: %+v", report)
+	}
+	if len(report.Files) != 2 || report.Files[0].Path != filepath.Join(dir, "big/b1.bin") ||
+		report.Files[1].Path != filepath.Join(dir, "big/b2.bin") || report.Files[0].ApparentSize != 3000 {
+		t.Fatalf("unexpected top files: %+v", report.Files)
+	}
+	// dirs are cumulative, and root is excluded
+	if len(report.Dirs) != 2 || report.Dirs[0].Path != filepath.Join(dir, "big") ||
+		report.Dirs[0].ApparentSize != 5010 || report.Dirs[0].FileCount != 3 {
+		t.Fatalf("unexpected top dirs: %+v", report.Dirs)
+	}
+
+	t.Log("Case02: n is larger than count of files and dirs.")
+	report, err = du.TopN(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// empty dir has no files
+	if len(report.Files) != 5 || len(report.Dirs) != 3 {
+		t.Fatalf("unexpected top report: %+v", report)
+	}
+	for i := 1; i < len(report.Files); i++ {
+		if report.Files[i-1].Size < report.Files[i].Size {
+			t.Fatalf("files are not sorted by size: %+v", report.Files)
+		}
+	}
+
+	t.Log("Case03: invalid arguments.")
+	if _, err := du.TopN(dir, 0); err == nil {
+		t.Fatal("want error for invalid n")
+	}
+	if _, err := du.TopN(filepath.Join(dir, "not_exist"), 1); err == nil {
+		t.Fatal("want error for not exist dir")
+	}
+
+	t.Log("Case04: root is not a readable dir.")
+	if _, err := du.TopN(filepath.Join(dir, "a.txt"), 3); err == nil {
+		t.Fatal("want error for regular file")
+	}
+}
+
+func TestTopNUnreadableDir(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("permission of dir is not checked for windows or root")
+	}
+	dir := createUsageTestDir(t)
+	locked := filepath.Join(dir, "big/sub")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chmod(locked, 0755)
+	})
+
+	t.Log("Case01: unreadable sub dir is kept in errors of report.")
+	report, err := mysvc.NewDiskUsage().TopN(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], locked) {
+		t.Fatalf("unexpected errors: %v", report.Errors)
+	}
+	// file in unreadable dir is not counted
+	if report.FileCount != 4 {
+		t.Fatalf("unexpected file count: %d", report.FileCount)
+	}
+
+	var buf bytes.Buffer
+	if err := mysvc.WriteTopReport(&buf, report, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "1 dirs failed to read") {
+		t.Fatalf("text report does not contain errors:\n%s", buf.String())
+	}
+}
+
+func TestWriteTopReport(t *testing.T) {
+	report := &mysvc.TopReport{
+		Path:      "/data",
+		Size:      3 << 30,
+		FileCount: 2,
+		Files:     []mysvc.TopEntry{{Path: "/data/a/big.bin", Size: 2 << 30}, {Path: "/data/small.txt", Size: 512}},
+		Dirs:      []mysvc.TopEntry{{Path: "/data/a", Size: 2 << 30, FileCount: 1}},
+	}
+
+	t.Log("Case01: human readable text.")
+	var buf bytes.Buffer
+	if err := mysvc.WriteTopReport(&buf, report, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/data: 2 files, 3.0G", "    2.0G  /data/a/big.bin", "    512B  /data/small.txt", "    2.0G         1 files  /data/a"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("text report does not contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	t.Log("Case02: json.")
+	buf.Reset()
+	if err := mysvc.WriteTopReport(&buf, report, mysvc.FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var got mysvc.TopReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 2 || got.Dirs[0].Size != 2<<30 {
+		t.Fatalf("unexpected json report: %s", buf.String())
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	cases := map[int64]string{0: "0B", 1023: "1023B", 1024: "1.0K", 1536: "1.5K", 5 << 20: "5.0M", 1 << 40: "1.0T"}
+	for size, want := range cases {
+		if got := mysvc.HumanSize(size); got != want {
+			t.Fatalf("want %s for %d, got %s", want, size, got)
+		}
+	}
+}
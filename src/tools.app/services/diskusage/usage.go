@@ -0,0 +1,192 @@
+package diskusage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output formats of WriteUsage.
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// UsageEntry disk usage of a dir or file, and sizes of dir include all files in it.
+type UsageEntry struct {
+	Path string `json:"path"`
+	// Depth depth from root, and root is 0.
+	Depth int  `json:"depth"`
+	IsDir bool `json:"is_dir"`
+	// Size allocated size on disk, like "du".
+	Size int64 `json:"size"`
+	// ApparentSize size of content, like "du --apparent-size".
+	ApparentSize int64 `json:"apparent_size"`
+	// FileCount count of files (not dirs) in dir, and 1 for file.
+	FileCount int64     `json:"file_count"`
+	ModTime   time.Time `json:"mtime"`
+	// Error error of reading dir, and its sizes only include readable files.
+	Error    string        `json:"error,omitempty"`
+	Children []*UsageEntry `json:"children,omitempty"`
+}
+
+// ScanOptions options of Scan.
+type ScanOptions struct {
+	// MaxDepth children are kept until depth (root is 0), and -1 is no limit. Sizes are counted for all levels.
+	MaxDepth int
+	// Files keeps files as children, otherwise only dirs are kept.
+	Files bool
+}
+
+// Scan returns disk usage tree of dir, and children are sorted by size desc. Errors of sub dirs
+// are kept in entries instead of returned.
+func (du *DiskUsage) Scan(dirPath string, opts ScanOptions) (*UsageEntry, error) {
+	if err := du.verifyPath(dirPath); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return newUsageEntry(dirPath, 0, info), nil
+	}
+
+	root := du.scanDir(dirPath, 0, info, &opts)
+	if len(root.Error) > 0 {
+		return nil, fmt.Errorf("scan dir (%s) failed: %s", dirPath, root.Error)
+	}
+	return root, nil
+}
+
+func (du *DiskUsage) scanDir(dirPath string, depth int, info os.FileInfo, opts *ScanOptions) *UsageEntry {
+	entry := newUsageEntry(dirPath, depth, info)
+	files, err := du.ListFiles(dirPath)
+	if err != nil {
+		logger.Warn("read dir failed", "dir", dirPath, "err", err)
+		entry.Error = err.Error()
+		return entry
+	}
+
+	children := make([]*UsageEntry, len(files))
+	var wg sync.WaitGroup
+	for i, f := range files {
+		path := filepath.Join(dirPath, f.Name())
+		if !f.IsDir() {
+			children[i] = newUsageEntry(path, depth+1, f)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, f os.FileInfo) {
+			defer wg.Done()
+			children[i] = du.scanDir(path, depth+1, f, opts)
+		}(i, f)
+	}
+	wg.Wait()
+
+	keep := opts.MaxDepth < 0 || depth < opts.MaxDepth
+	for _, child := range children {
+		entry.Size += child.Size
+		entry.ApparentSize += child.ApparentSize
+		entry.FileCount += child.FileCount
+		if keep && (child.IsDir || opts.Files) {
+			entry.Children = append(entry.Children, child)
+		}
+	}
+	sort.SliceStable(entry.Children, func(i, j int) bool {
+		return entry.Children[i].Size > entry.Children[j].Size
+	})
+	return entry
+}
+
+func newUsageEntry(path string, depth int, info os.FileInfo) *UsageEntry {
+	entry := &UsageEntry{
+		Path:         path,
+		Depth:        depth,
+		IsDir:        info.IsDir(),
+		Size:         diskSize(info),
+		ApparentSize: info.Size(),
+		ModTime:      info.ModTime(),
+	}
+	if !entry.IsDir {
+		entry.FileCount = 1
+	}
+	return entry
+}
+
+// Walk calls fn for entry and its children in pre-order.
+func (e *UsageEntry) Walk(fn func(entry *UsageEntry)) {
+	fn(e)
+	for _, child := range e.Children {
+		child.Walk(fn)
+	}
+}
+
+// WriteUsage writes usage tree as format: "json" (nested tree), "ndjson" (an entry without children
+// per line), or "csv" (an entry per row with header), and entries are in pre-order.
+func WriteUsage(w io.Writer, root *UsageEntry, format string) error {
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(root)
+	case FormatNDJSON:
+		return writeUsageNDJSON(w, root)
+	case FormatCSV:
+		return writeUsageCSV(w, root)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+func writeUsageNDJSON(w io.Writer, root *UsageEntry) (err error) {
+	encoder := json.NewEncoder(w)
+	root.Walk(func(entry *UsageEntry) {
+		if err != nil {
+			return
+		}
+		flat := *entry
+		flat.Children = nil
+		err = encoder.Encode(&flat)
+	})
+	return err
+}
+
+var usageCSVHeader = []string{"path", "depth", "is_dir", "size", "apparent_size", "file_count", "mtime", "error"}
+
+func writeUsageCSV(w io.Writer, root *UsageEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(usageCSVHeader); err != nil {
+		return err
+	}
+	var err error
+	root.Walk(func(entry *UsageEntry) {
+		if err != nil {
+			return
+		}
+		err = writer.Write([]string{
+			entry.Path,
+			strconv.Itoa(entry.Depth),
+			strconv.FormatBool(entry.IsDir),
+			strconv.FormatInt(entry.Size, 10),
+			strconv.FormatInt(entry.ApparentSize, 10),
+			strconv.FormatInt(entry.FileCount, 10),
+			entry.ModTime.UTC().Format(time.RFC3339),
+			entry.Error,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
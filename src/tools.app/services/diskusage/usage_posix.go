@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package diskusage
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskSize returns allocated size of file on disk (512-byte blocks), like "du".
+func diskSize(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks) * 512
+	}
+	return info.Size()
+}
@@ -0,0 +1,146 @@
+package diskusage_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mysvc "src/tools.app/services/diskusage"
+)
+
+func createUsageTestDir(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]int{
+		"a.txt":         100,
+		"big/b1.bin":    3000,
+		"big/b2.bin":    2000,
+		"big/sub/c.txt": 10,
+		"small/d.txt":   50,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, bytes.Repeat([]byte("x"), size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestScan(t *testing.T) {
+	dir := createUsageTestDir(t)
+	du := mysvc.NewDiskUsage()
+
+	t.Log("Case01: scan dirs of 1 level, and sizes include all levels.")
+	root, err := du.Scan(dir, mysvc.ScanOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.FileCount != 5 || root.ApparentSize < 5160 || !root.IsDir || !root.ModTime.Equal(dirInfo.ModTime()) {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+	if len(root.Children) != 3 {
+		t.Fatalf("want 3 dirs, got %d", len(root.Children))
+	}
+	big := root.Children[0]
+	if big.Path != filepath.Join(dir, "big") || big.Depth != 1 || big.FileCount != 3 || len(big.Children) != 0 {
+		t.Fatalf("unexpected largest dir: %+v", big)
+	}
+	if big.Size < 5010 {
+		t.Fatalf("disk size is less than content: %d", big.Size)
+	}
+
+	t.Log("Case02: scan files of all levels.")
+	root, err = du.Scan(dir, mysvc.ScanOptions{MaxDepth: -1, Files: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	root.Walk(func(entry *mysvc.UsageEntry) {
+		count++
+	})
+	// root, 4 dirs and 5 files
+	if count != 10 {
+		t.Fatalf("want 10 entries, got %d", count)
+	}
+
+	t.Log("Case03: scan not exist dir.")
+	if _, err := du.Scan(filepath.Join(dir, "not_exist"), mysvc.ScanOptions{}); err == nil {
+		t.Fatal("want error for not exist dir")
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+	dir := createUsageTestDir(t)
+	root, err := mysvc.NewDiskUsage().Scan(dir, mysvc.ScanOptions{MaxDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Case01: write usage as json tree.")
+	var buf bytes.Buffer
+	if err := mysvc.WriteUsage(&buf, root, mysvc.FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var tree mysvc.UsageEntry
+	if err := json.Unmarshal(buf.Bytes(), &tree); err != nil {
+		t.Fatal(err)
+	}
+	if tree.FileCount != 5 || len(tree.Children) != 3 || len(tree.Children[0].Children) != 1 {
+		t.Fatalf("unexpected json tree: %s", buf.String())
+	}
+
+	t.Log("Case02: write usage as ndjson in pre-order.")
+	buf.Reset()
+	if err := mysvc.WriteUsage(&buf, root, mysvc.FormatNDJSON); err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0, 5)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry mysvc.UsageEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if len(entry.Children) > 0 {
+			t.Fatalf("ndjson entry has children: %s", scanner.Text())
+		}
+		paths = append(paths, strings.TrimPrefix(entry.Path, dir))
+	}
+	if want := "|/big|/big/sub|/small|/empty"; strings.Join(paths, "|") != want {
+		t.Fatalf("want paths %s, got %s", want, strings.Join(paths, "|"))
+	}
+
+	t.Log("Case03: write usage as csv with header.")
+	buf.Reset()
+	if err := mysvc.WriteUsage(&buf, root, mysvc.FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 || records[0][0] != "path" || records[2][0] != filepath.Join(dir, "big") || records[2][5] != "3" {
+		t.Fatalf("unexpected csv records: %v", records)
+	}
+
+	t.Log("Case04: unsupported format.")
+	if err := mysvc.WriteUsage(&buf, root, "xml"); err == nil {
+		t.Fatal("want unsupported format error")
+	}
+}
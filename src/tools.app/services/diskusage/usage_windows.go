@@ -0,0 +1,8 @@
+package diskusage
+
+import "os"
+
+// diskSize returns apparent size of file, since allocated size is not available from file info.
+func diskSize(info os.FileInfo) int64 {
+	return info.Size()
+}
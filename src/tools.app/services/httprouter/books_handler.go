@@ -7,7 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 
-	myutils "src/tools.app/utils"
+	"src/tools.app/utils/httpresp"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -65,11 +65,11 @@ func (handler BooksHandler) Index(w http.ResponseWriter, r *http.Request, _ http
 func (handler *BooksHandler) BookCreate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	book := &Book{}
 	if err := handler.populateModelFromHandler(r, book); err != nil {
-		myutils.WriteErrJSONResp(w, http.StatusUnprocessableEntity, "Unprocessible Entity")
+		httpresp.WriteError(w, r, httpresp.NewError(http.StatusUnprocessableEntity, "Unprocessible Entity").WithCause(err))
 		return
 	}
 	handler.BooksStore[book.ISDN] = book
-	myutils.WriteOKJSONResp(w, book)
+	httpresp.WriteOK(w, r, book)
 }
 
 // BookIndex handler for the books index action.
@@ -79,7 +79,7 @@ func (handler BooksHandler) BookIndex(w http.ResponseWriter, r *http.Request, _
 	for _, book := range handler.BooksStore {
 		books = append(books, book)
 	}
-	myutils.WriteOKJSONResp(w, books)
+	httpresp.WriteOK(w, r, books)
 }
 
 // BookShow handler for the books Show action.
@@ -88,10 +88,10 @@ func (handler BooksHandler) BookShow(w http.ResponseWriter, r *http.Request, par
 	isdn := params.ByName("isdn")
 	book, ok := handler.BooksStore[isdn]
 	if !ok {
-		myutils.WriteErrJSONResp(w, http.StatusNotFound, "Book Record Not Found!")
+		httpresp.WriteError(w, r, httpresp.NotFound("Book Record Not Found!"))
 		return
 	}
-	myutils.WriteOKJSONResp(w, book)
+	httpresp.WriteOK(w, r, book)
 }
 
 // populateModelFromHandler populates a model from the params in the Handler.
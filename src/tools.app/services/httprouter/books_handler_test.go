@@ -23,7 +23,7 @@ func TestBookShow(t *testing.T) {
 	if rr.Code != http.StatusNotFound {
 		t.Error("Expected response code to be 404")
 	}
-	jsonResp := `{"error":{"status":404,"title":"Book Record Not Found!"}}`
+	jsonResp := `{"error":{"status":404,"code":"not_found","message":"Book Record Not Found!"}}`
 	if strings.Trim(rr.Body.String(), "\n") != jsonResp {
 		t.Error("Response body does not match")
 	}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	tmplates "src/tools.app/services/httptemplate"
+	"src/tools.app/utils/httpresp"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -48,6 +49,7 @@ func (svc BooksServer) GetHTTPRouter() *httprouter.Router {
 func (svc BooksServer) logger(fn httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, param httprouter.Params) {
 		start := time.Now()
+		r = httpresp.SetRequestID(w, r)
 		log.Printf("start: %s %s\n", r.Method, r.URL.Path)
 		fn(w, r, param)
 		log.Printf("done: %v (%s %s)\n", time.Since(start), r.Method, r.URL.Path)
@@ -3,11 +3,10 @@ package httptemplate
 import (
 	"fmt"
 	"html/template"
-	"log"
 	"net/http"
 	"strconv"
 
-	myutil "src/tools.app/utils"
+	"src/tools.app/utils/httpresp"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -96,7 +95,7 @@ func TemplateHandler04(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// errHandler writes internal server error, and err is logged.
 func errHandler(w http.ResponseWriter, err error) {
-	log.Println(err)
-	myutil.WriteErrJSONResp(w, http.StatusInternalServerError, "Internal Server Error")
+	httpresp.WriteError(w, nil, err)
 }
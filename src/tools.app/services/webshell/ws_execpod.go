@@ -2,7 +2,6 @@ package webshell
 
 import (
 	"io"
-	"log"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -22,7 +21,7 @@ type PtyHandler interface {
 // ExecPod execs command in given pod.
 func ExecPod(kubeClient kubernetes.Interface, cfg *restclient.Config,
 	cmd []string, ptyHandler PtyHandler, namespace, podName, containerName string) error {
-	log.Println("init pod executor with sh session:", cmd)
+	logger.Info("init pod executor with sh session", "cmd", cmd)
 
 	req := kubeClient.CoreV1().RESTClient().Post().
 		Resource("pods").
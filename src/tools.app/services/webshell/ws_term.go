@@ -3,16 +3,18 @@ package webshell
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
+	"src/tools.app/utils/logging"
+
 	"github.com/gorilla/websocket"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
+var logger = logging.Get("webshell")
+
 const (
-	isDebug = false
 	// Time allowed to write a message to the peer.
 	writeWait = 10 * time.Second
 	// Maximum message size allowed from peer.
@@ -30,7 +32,7 @@ const (
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		if r.Method != "GET" {
-			log.Println("websocket not support GET")
+			logger.Warn("websocket not support GET")
 			return false
 		}
 		return true
@@ -89,10 +91,10 @@ func (term *TerminalSession) Close() error {
 
 // Next is called in a loop from remotecommand as long as the process is running.
 func (term *TerminalSession) Next() *remotecommand.TerminalSize {
-	myLogPrintln("[Next] terminal session")
+	logger.Debug("[Next] terminal session")
 	select {
 	case size := <-term.sizeChan:
-		myLogPrintln("[NextEnd] terminal is resized")
+		logger.Debug("[NextEnd] terminal is resized")
 		return &size
 	case <-term.doneChan:
 		return nil
@@ -103,17 +105,17 @@ func (term *TerminalSession) Next() *remotecommand.TerminalSize {
 // webshell term => by websocket => term server => by copy => pod executor stdin => pod
 // Read is called in a loop from remotecommand as long as the process is running.
 func (term *TerminalSession) Read(p []byte) (int, error) {
-	myLogPrintln("[Read] terminal session")
+	logger.Debug("[Read] terminal session")
 	_, message, err := term.wsConn.ReadMessage()
-	myLogPrintln("[ReadEnd] read message from ws, and copy to stdin")
+	logger.Debug("[ReadEnd] read message from ws, and copy to stdin")
 	if err != nil {
-		log.Printf("ws read message err: %v\n", err)
+		logger.Error("ws read message err", "err", err)
 		return copy(p, EndOfTransmission), err
 	}
 
 	var msg TerminalMessage
 	if err := json.Unmarshal([]byte(message), &msg); err != nil {
-		log.Printf("json unmarshal ws message err: %v\n", err)
+		logger.Error("json unmarshal ws message err", "err", err)
 		return copy(p, EndOfTransmission), err
 	}
 
@@ -124,7 +126,7 @@ func (term *TerminalSession) Read(p []byte) (int, error) {
 		term.sizeChan <- remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows}
 		return 0, nil
 	default:
-		log.Printf("unknown message type '%s'\n", msg.Operation)
+		logger.Error("unknown message type", "type", msg.Operation)
 		return copy(p, EndOfTransmission), fmt.Errorf("unknown message type '%s'", msg.Operation)
 	}
 }
@@ -133,26 +135,20 @@ func (term *TerminalSession) Read(p []byte) (int, error) {
 // pod => pod exec stdout => by write => terminal server => by websocket => webshell term
 // Write is called from remotecommand whenever there is any output from stdout.
 func (term *TerminalSession) Write(p []byte) (int, error) {
-	myLogPrintln("[Write] terminal session")
+	logger.Debug("[Write] terminal session")
 	msg, err := json.Marshal(TerminalMessage{
 		Operation: "stdout",
 		Data:      string(p),
 	})
 	if err != nil {
-		log.Printf("json marshal stdin bytes error: %v\n", err)
+		logger.Error("json marshal stdin bytes error", "err", err)
 		return 0, err
 	}
 
 	if err := term.wsConn.WriteMessage(websocket.TextMessage, msg); err != nil {
-		log.Printf("ws write message err: %v\n", err)
+		logger.Error("ws write message err", "err", err)
 		return 0, err
 	}
-	myLogPrintln("[WriteEnd] copy message from stdout, and write to ws")
+	logger.Debug("[WriteEnd] copy message from stdout, and write to ws")
 	return len(p), nil
 }
-
-func myLogPrintln(msg string) {
-	if isDebug {
-		log.Println(msg)
-	}
-}
@@ -0,0 +1,611 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveFormat format of archive file.
+type ArchiveFormat string
+
+const (
+	// ArchiveTarGz tar archive compressed by gzip.
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	// ArchiveTarZst tar archive compressed by zstd.
+	ArchiveTarZst ArchiveFormat = "tar.zst"
+	// ArchiveZip zip archive.
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+// ArchiveOptions options of creating and extracting archive.
+type ArchiveOptions struct {
+	// Include and Exclude glob patterns (path.Match) of slash separated entry names, like "*.go" or "logs/*".
+	// A pattern matches an entry if it matches entry name, base name or any parent dir of entry.
+	// All entries are included if Include is empty, and Exclude takes precedence over Include.
+	Include []string
+	Exclude []string
+	// MaxSize max total bytes of extracted files, no limit if 0.
+	MaxSize int64
+}
+
+// GetArchiveFormat returns archive format by file name: .tar.gz, .tgz, .tar.zst, .tzst or .zip.
+func GetArchiveFormat(name string) (ArchiveFormat, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return ArchiveTarZst, nil
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip, nil
+	default:
+		return "", fmt.Errorf("unsupported archive format: %s", name)
+	}
+}
+
+func (opts *ArchiveOptions) isIncluded(name string) bool {
+	if opts == nil {
+		return true
+	}
+	if matchArchiveGlobs(name, opts.Exclude) {
+		return false
+	}
+	return len(opts.Include) == 0 || matchArchiveGlobs(name, opts.Include)
+}
+
+func matchArchiveGlobs(name string, patterns []string) bool {
+	name = strings.Trim(name, "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+		for p := name; p != "." && p != "/" && len(p) > 0; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/* Create Archive */
+
+// archiveWriter writes entries of files to an archive.
+type archiveWriter interface {
+	writeEntry(name string, info os.FileInfo, filePath, link string) error
+	Close() error
+}
+
+// CreateArchiveFile creates archive file of paths, and format is by dest file name.
+func CreateArchiveFile(dest string, paths []string, opts *ArchiveOptions) (err error) {
+	format, err := GetArchiveFormat(dest)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dest)
+		}
+	}()
+	return CreateArchive(f, format, paths, opts)
+}
+
+// CreateArchive writes archive of files and dirs (recursively) to w. Entry names are relative to parent dir
+// of each path, and symlinks are archived as links. Modes and modify times are kept.
+func CreateArchive(w io.Writer, format ArchiveFormat, paths []string, opts *ArchiveOptions) (err error) {
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := aw.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		base := filepath.Dir(root)
+		err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(base, filePath)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+
+			if info.IsDir() {
+				if opts != nil && matchArchiveGlobs(name, opts.Exclude) {
+					return filepath.SkipDir
+				}
+				// dirs are created by files when only some files are included
+				if opts != nil && len(opts.Include) > 0 {
+					return nil
+				}
+				return aw.writeEntry(name+"/", info, filePath, "")
+			}
+			if !opts.isIncluded(name) {
+				return nil
+			}
+
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(filePath); err != nil {
+					return err
+				}
+			} else if !info.Mode().IsRegular() {
+				// devices, pipes and sockets are skipped
+				return nil
+			}
+			return aw.writeEntry(name, info, filePath, link)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat) (archiveWriter, error) {
+	switch format {
+	case ArchiveTarGz:
+		gw := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gw), compressor: gw}, nil
+	case ArchiveTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &tarArchiveWriter{tw: tar.NewWriter(zw), compressor: zw}, nil
+	case ArchiveZip:
+		return &zipArchiveWriter{zw: zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+type tarArchiveWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (w *tarArchiveWriter) writeEntry(name string, info os.FileInfo, filePath, link string) error {
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil
+	}
+	return copyFileTo(w.tw, filePath)
+}
+
+func (w *tarArchiveWriter) Close() error {
+	err := w.tw.Close()
+	if closeErr := w.compressor.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipArchiveWriter) writeEntry(name string, info os.FileInfo, filePath, link string) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.Mode().IsRegular() {
+		hdr.Method = zip.Deflate
+	}
+	fw, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		return nil
+	case len(link) > 0:
+		// content of symlink entry is the link target
+		_, err = io.WriteString(fw, link)
+		return err
+	default:
+		return copyFileTo(fw, filePath)
+	}
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
+
+func copyFileTo(w io.Writer, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+/* Extract Archive */
+
+// ExtractArchiveFile extracts archive file to dest dir, and format is by archive file name.
+func ExtractArchiveFile(archivePath, dest string, opts *ArchiveOptions) error {
+	format, err := GetArchiveFormat(archivePath)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ExtractArchive(f, format, dest, opts)
+}
+
+// ExtractArchive extracts archive read from r to dest dir. Entries out of dest (like "../x", absolute path,
+// or through a symlink) and symlinks pointing out of dest are rejected, and existing symlinks are never
+// replaced by links. Modes and modify times are kept.
+func ExtractArchive(r io.Reader, format ArchiveFormat, dest string, opts *ArchiveOptions) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	ex, err := newArchiveExtractor(dest, opts)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case ArchiveTarGz:
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(r); err != nil {
+			return err
+		}
+		defer gr.Close()
+		err = ex.extractTar(tar.NewReader(gr))
+	case ArchiveTarZst:
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(r); err != nil {
+			return err
+		}
+		defer zr.Close()
+		err = ex.extractTar(tar.NewReader(zr))
+	case ArchiveZip:
+		err = ex.extractZip(r)
+	default:
+		err = fmt.Errorf("unsupported archive format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	return ex.setDirTimes()
+}
+
+// archiveExtractor extracts entries to dest dir.
+type archiveExtractor struct {
+	dest    string
+	opts    *ArchiveOptions
+	size    int64
+	dirTime map[string]time.Time
+}
+
+func newArchiveExtractor(dest string, opts *ArchiveOptions) (*archiveExtractor, error) {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	if dest, err = filepath.EvalSymlinks(dest); err != nil {
+		return nil, err
+	}
+	return &archiveExtractor{dest: dest, opts: opts, dirTime: make(map[string]time.Time)}, nil
+}
+
+func (ex *archiveExtractor) extractTar(tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = ex.extractDir(hdr.Name, info)
+		case tar.TypeReg, tar.TypeRegA:
+			err = ex.extractFile(hdr.Name, info, tr)
+		case tar.TypeSymlink:
+			err = ex.extractSymlink(hdr.Name, hdr.Linkname)
+		case tar.TypeLink:
+			err = ex.extractHardLink(hdr.Name, hdr.Linkname)
+		default:
+			// devices, fifos and pax global headers are skipped
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip extracts zip read from r, and r is buffered to a temp file if it's not a file.
+func (ex *archiveExtractor) extractZip(r io.Reader) error {
+	f, ok := r.(*os.File)
+	if !ok {
+		tmp, err := ioutil.TempFile("", "archive_*.zip")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, r); err != nil {
+			return err
+		}
+		f = tmp
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		info := zf.FileInfo()
+		switch {
+		case info.IsDir():
+			err = ex.extractDir(zf.Name, info)
+		case info.Mode()&os.ModeSymlink != 0:
+			err = ex.extractZipSymlink(zf)
+		case info.Mode().IsRegular():
+			err = ex.extractZipFile(zf, info)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ex *archiveExtractor) extractZipFile(zf *zip.File, info os.FileInfo) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return ex.extractFile(zf.Name, info, rc)
+}
+
+func (ex *archiveExtractor) extractZipSymlink(zf *zip.File) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return ex.extractSymlink(zf.Name, string(b))
+}
+
+// targetPath returns path of entry in dest, and error if entry is out of dest.
+// Empty path is returned if entry is not included by options.
+func (ex *archiveExtractor) targetPath(name string) (string, error) {
+	name = strings.Replace(name, `\`, "/", -1)
+	clean := path.Clean("/" + name)
+	if clean == "/" {
+		return "", nil
+	}
+	if path.IsAbs(name) || strings.HasPrefix(path.Clean(name), "../") || path.Clean(name) == ".." {
+		return "", fmt.Errorf("illegal archive entry out of dest: %s", name)
+	}
+	if !ex.opts.isIncluded(clean[1:]) {
+		return "", nil
+	}
+
+	target := filepath.Join(ex.dest, filepath.FromSlash(clean[1:]))
+	// parent dir may be a symlink extracted before
+	parent, err := ex.mkdirAll(filepath.Dir(target))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(target)), nil
+}
+
+// mkdirAll creates dir, and returns real path of dir which must be in dest.
+func (ex *archiveExtractor) mkdirAll(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if !ex.isInDest(realDir) {
+		return "", fmt.Errorf("illegal archive entry through symlink out of dest: %s", dir)
+	}
+	return realDir, nil
+}
+
+func (ex *archiveExtractor) isInDest(p string) bool {
+	rel, err := filepath.Rel(ex.dest, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (ex *archiveExtractor) extractDir(name string, info os.FileInfo) error {
+	target, err := ex.targetPath(name)
+	if err != nil || len(target) == 0 {
+		return err
+	}
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("illegal archive dir entry on symlink: %s", name)
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	if err := os.Chmod(target, info.Mode().Perm()|0700); err != nil {
+		return err
+	}
+	ex.dirTime[target] = info.ModTime()
+	return nil
+}
+
+func (ex *archiveExtractor) extractFile(name string, info os.FileInfo, r io.Reader) (err error) {
+	target, err := ex.targetPath(name)
+	if err != nil || len(target) == 0 {
+		return err
+	}
+	// existing file or symlink is replaced, and file is never written through a symlink
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chtimes(target, info.ModTime(), info.ModTime())
+		}
+	}()
+
+	if ex.opts == nil || ex.opts.MaxSize <= 0 {
+		_, err = io.Copy(f, r)
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, ex.opts.MaxSize-ex.size+1))
+	ex.size += n
+	if err == nil && ex.size > ex.opts.MaxSize {
+		err = fmt.Errorf("extracted size exceeds max size %d", ex.opts.MaxSize)
+	}
+	return err
+}
+
+func (ex *archiveExtractor) extractSymlink(name, link string) error {
+	target, err := ex.targetPath(name)
+	if err != nil || len(target) == 0 {
+		return err
+	}
+	if filepath.IsAbs(link) || !ex.isLinkInDest(filepath.Dir(target), link) {
+		return fmt.Errorf("illegal archive symlink out of dest: %s -> %s", name, link)
+	}
+	// dir or symlink is never replaced by a symlink, otherwise links resolved through it before may point out of dest
+	if fi, err := os.Lstat(target); err == nil && (fi.IsDir() || fi.Mode()&os.ModeSymlink != 0) {
+		return fmt.Errorf("illegal archive symlink replacing dir or symlink: %s", name)
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(link, target)
+}
+
+// isLinkInDest resolves link from real dir as os does, following symlinks extracted before,
+// and returns whether each step is in dest. ".." after a not exist path is rejected, because
+// the path may be extracted as a symlink later.
+func (ex *archiveExtractor) isLinkInDest(dir, link string) bool {
+	cur := dir
+	exists := true
+	for _, elem := range strings.Split(filepath.ToSlash(link), "/") {
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if !exists {
+				return false
+			}
+			// cur is a real path, so its parent is the same as os resolves
+			cur = filepath.Dir(cur)
+		default:
+			cur = filepath.Join(cur, elem)
+			if !exists {
+				break
+			}
+			fi, err := os.Lstat(cur)
+			if err != nil {
+				exists = false
+				break
+			}
+			if fi.Mode()&os.ModeSymlink != 0 {
+				if real, err := filepath.EvalSymlinks(cur); err == nil {
+					cur = real
+				} else {
+					exists = false
+				}
+			}
+		}
+		if !ex.isInDest(cur) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ex *archiveExtractor) extractHardLink(name, link string) error {
+	target, err := ex.targetPath(name)
+	if err != nil || len(target) == 0 {
+		return err
+	}
+	linkTarget, err := ex.targetPath(link)
+	if err != nil {
+		return err
+	}
+	if len(linkTarget) == 0 {
+		return fmt.Errorf("hard link target is not extracted: %s -> %s", name, link)
+	}
+	// hard link to a symlink is a symlink in other dir, and existing symlink is never replaced
+	if fi, err := os.Lstat(linkTarget); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("illegal archive hard link to symlink: %s -> %s", name, link)
+	}
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("illegal archive hard link replacing symlink: %s", name)
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Link(linkTarget, target)
+}
+
+// setDirTimes sets modify times of dirs after all files are extracted.
+func (ex *archiveExtractor) setDirTimes() error {
+	for dir, mtime := range ex.dirTime {
+		if err := os.Chtimes(dir, mtime, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package utils_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	myutils "src/tools.app/utils"
+)
+
+func createTestArchiveSrc(t *testing.T, dir string) string {
+	src := filepath.Join(dir, "src")
+	files := map[string]string{
+		"a.txt":         "file a",
+		"sub/b.go":      "package b",
+		"sub/deep/c.go": "package c",
+		"logs/d.log":    "log d",
+	}
+	for name, content := range files {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(src, "a.txt"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "a.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/b.go", filepath.Join(src, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := createTestArchiveSrc(t, dir)
+
+	for _, name := range []string{"test.tar.gz", "test.tar.zst", "test.zip"} {
+		t.Logf("Case01: create and extract %s, and modes, mtimes and symlinks are kept.", name)
+		archivePath := filepath.Join(dir, name)
+		if err := myutils.CreateArchiveFile(archivePath, []string{src}, nil); err != nil {
+			t.Fatal(err)
+		}
+		dest := filepath.Join(dir, "dest_"+name)
+		if err := myutils.ExtractArchiveFile(archivePath, dest, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dest, "src/sub/deep/c.go"))
+		if err != nil || string(b) != "package c" {
+			t.Errorf("Unexpected extracted file: %s, %v", b, err)
+		}
+		info, err := os.Stat(filepath.Join(dest, "src/a.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0750 || !info.ModTime().Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+			t.Errorf("Unexpected mode or mtime: %v, %v", info.Mode(), info.ModTime())
+		}
+		if link, err := os.Readlink(filepath.Join(dest, "src/link.go")); err != nil || link != "sub/b.go" {
+			t.Errorf("Unexpected symlink: %s, %v", link, err)
+		}
+	}
+
+	t.Log("Case02: include and exclude globs, and streaming zip from reader.")
+	var buf bytes.Buffer
+	opts := &myutils.ArchiveOptions{Include: []string{"*.go"}, Exclude: []string{"deep"}}
+	if err := myutils.CreateArchive(&buf, myutils.ArchiveZip, []string{src}, opts); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "dest_globs")
+	if err := myutils.ExtractArchive(&buf, myutils.ArchiveZip, dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, exist := range map[string]bool{"src/sub/b.go": true, "src/link.go": true, "src/a.txt": false, "src/sub/deep": false, "src/logs": false} {
+		if _, err := os.Lstat(filepath.Join(dest, name)); (err == nil) != exist {
+			t.Errorf("Unexpected existence of %s: %v", name, err)
+		}
+	}
+
+	t.Log("Case03: max size of extracted files.")
+	err = myutils.ExtractArchiveFile(filepath.Join(dir, "test.tar.gz"), filepath.Join(dir, "dest_max"), &myutils.ArchiveOptions{MaxSize: 10})
+	if err == nil || !strings.Contains(err.Error(), "max size") {
+		t.Errorf("Want max size error, got: %v", err)
+	}
+}
+
+func newTestTarGz(t *testing.T, hdrs []*tar.Header) *bytes.Buffer {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len("evil"))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte("evil"))
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return &buf
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive_unsafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "dest")
+
+	cases := []struct {
+		desc string
+		hdrs []*tar.Header
+	}{
+		{"zip-slip by parent dir", []*tar.Header{{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644}}},
+		{"absolute path", []*tar.Header{{Name: filepath.Join(dir, "evil.txt"), Typeflag: tar.TypeReg, Mode: 0644}}},
+		{"symlink out of dest", []*tar.Header{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../"}}},
+		{"absolute symlink", []*tar.Header{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: dir}}},
+		{"hard link out of dest", []*tar.Header{{Name: "link", Typeflag: tar.TypeLink, Linkname: "../evil.txt"}}},
+		{"symlink out of dest through extracted symlink", []*tar.Header{
+			{Name: "q", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "x", Typeflag: tar.TypeSymlink, Linkname: "q/.."},
+		}},
+		{"symlink re-pointing symlink resolved before", []*tar.Header{
+			{Name: "sub/x/y/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "sub/x/y"},
+			{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "a/../../.."},
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+		}},
+		{"hard link to symlink in other dir", []*tar.Header{
+			{Name: "sub/l", Typeflag: tar.TypeSymlink, Linkname: "../d"},
+			{Name: "hl", Typeflag: tar.TypeLink, Linkname: "sub/l"},
+		}},
+		{"symlink replacing dir resolved before", []*tar.Header{
+			{Name: "d/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "y", Typeflag: tar.TypeSymlink, Linkname: "d/.."},
+			{Name: "d", Typeflag: tar.TypeSymlink, Linkname: "."},
+		}},
+	}
+	for i, c := range cases {
+		t.Logf("Case%02d: %s is rejected.", i+1, c.desc)
+		err := myutils.ExtractArchive(newTestTarGz(t, c.hdrs), myutils.ArchiveTarGz, dest, nil)
+		if err == nil {
+			t.Errorf("Want error for %s", c.desc)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "evil.txt")); err == nil {
+			t.Fatalf("File is written out of dest for %s", c.desc)
+		}
+	}
+
+	if _, err := os.Lstat(filepath.Join(dest, "x")); err == nil {
+		t.Error("Symlink through extracted symlink out of dest is extracted")
+	}
+	if fi, err := os.Lstat(filepath.Join(dest, "d")); err != nil || !fi.IsDir() {
+		t.Error("Dir resolved before is replaced by symlink")
+	}
+	realDest, _ := filepath.EvalSymlinks(dest)
+	if real, err := filepath.EvalSymlinks(filepath.Join(dest, "b")); err != nil || real != realDest {
+		t.Errorf("Symlink resolved before is re-pointed: %s, %v", real, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "hl")); err == nil {
+		t.Error("Hard link to symlink is extracted")
+	}
+
+	t.Log("Case10: file through existing symlink out of dest is rejected.")
+	outside := filepath.Join(dir, "outside")
+	os.MkdirAll(outside, 0755)
+	os.MkdirAll(dest, 0755)
+	if err := os.Symlink(outside, filepath.Join(dest, "out")); err != nil {
+		t.Fatal(err)
+	}
+	hdrs := []*tar.Header{{Name: "out/evil.txt", Typeflag: tar.TypeReg, Mode: 0644}}
+	if err := myutils.ExtractArchive(newTestTarGz(t, hdrs), myutils.ArchiveTarGz, dest, nil); err == nil {
+		t.Error("Want error for file through symlink")
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "evil.txt")); err == nil {
+		t.Error("File is written through symlink out of dest")
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ChecksumEntry an entry of checksum manifest.
+type ChecksumEntry struct {
+	Path string
+	Sum  string
+}
+
+// ChecksumResult result of checking an entry.
+type ChecksumResult struct {
+	ChecksumEntry
+	Algo   string
+	Actual string
+	// Err error of reading file.
+	Err error
+}
+
+// OK returns whether file is read and matched. Hex sums are matched case-insensitively,
+// and url base64 sums of qetag are case-sensitive.
+func (r *ChecksumResult) OK() bool {
+	if r.Err != nil {
+		return false
+	}
+	if strings.EqualFold(r.Algo, HashQETag) {
+		return r.Sum == r.Actual
+	}
+	return strings.EqualFold(r.Sum, r.Actual)
+}
+
+// ParseChecksumManifest parses manifest of "<sum>  <path>" lines (like output of sha256sum), and
+// empty lines and lines start with "#" are skipped.
+func ParseChecksumManifest(r io.Reader) ([]ChecksumEntry, error) {
+	entries := make([]ChecksumEntry, 0, 16)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.IndexAny(line, " \t")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid manifest line %d: %s", lineNo, line)
+		}
+		// "*" marks binary mode of sha256sum
+		path := strings.TrimPrefix(strings.TrimLeft(line[idx:], " \t"), "*")
+		if len(path) == 0 {
+			return nil, fmt.Errorf("invalid manifest line %d: %s", lineNo, line)
+		}
+		entries = append(entries, ChecksumEntry{Path: path, Sum: line[:idx]})
+	}
+	return entries, scanner.Err()
+}
+
+// FormatChecksumEntry returns manifest line of entry.
+func FormatChecksumEntry(entry ChecksumEntry) string {
+	return entry.Sum + "  " + entry.Path
+}
+
+// VerifyChecksums hashes files of entries by workers in parallel (number of cpus if workers <= 0),
+// and returns results in order of entries. Relative paths are joined with baseDir.
+func VerifyChecksums(entries []ChecksumEntry, baseDir, algo string, workers int) ([]ChecksumResult, error) {
+	if _, err := NewHash(algo); err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]ChecksumResult, len(entries))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				entry := entries[idx]
+				path := entry.Path
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(baseDir, path)
+				}
+				actual, err := HashFile(path, algo)
+				results[idx] = ChecksumResult{ChecksumEntry: entry, Algo: algo, Actual: actual, Err: err}
+			}
+		}()
+	}
+	for idx := range entries {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+	return results, nil
+}
+
+// VerifyChecksumManifest verifies files of manifest, and relative paths are relative to dir of manifest.
+func VerifyChecksumManifest(manifest, algo string, workers int) ([]ChecksumResult, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := ParseChecksumManifest(f)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyChecksums(entries, filepath.Dir(manifest), algo, workers)
+}
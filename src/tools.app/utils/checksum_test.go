@@ -0,0 +1,119 @@
+package utils_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	myutils "src/tools.app/utils"
+)
+
+func TestVerifyChecksumManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := make([]string, 0, 12)
+	manifest = append(manifest, "# sha256 of test files", "")
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("file%02d.txt", i)
+		content := strings.Repeat(name, i*100)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := myutils.HashReader(strings.NewReader(content), myutils.HashSHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest = append(manifest, myutils.FormatChecksumEntry(myutils.ChecksumEntry{Path: name, Sum: sum}))
+	}
+	manifestPath := filepath.Join(dir, "files.sha256")
+	writeManifest := func() {
+		if err := ioutil.WriteFile(manifestPath, []byte(strings.Join(manifest, "\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeManifest()
+
+	t.Log("Case01: all files are matched.")
+	results, err := myutils.VerifyChecksumManifest(manifestPath, myutils.HashSHA256, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 10 {
+		t.Fatalf("want 10 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.Path != fmt.Sprintf("file%02d.txt", i) || !result.OK() {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+
+	t.Log("Case02: changed and missing files are failed.")
+	if err := ioutil.WriteFile(filepath.Join(dir, "file03.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "file05.txt")); err != nil {
+		t.Fatal(err)
+	}
+	results, err = myutils.VerifyChecksumManifest(manifestPath, myutils.HashSHA256, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		switch i {
+		case 3:
+			if result.OK() || result.Err != nil {
+				t.Fatalf("want mismatched result: %+v", result)
+			}
+		case 5:
+			if result.OK() || !os.IsNotExist(result.Err) {
+				t.Fatalf("want not exist result: %+v", result)
+			}
+		default:
+			if !result.OK() {
+				t.Fatalf("unexpected result: %+v", result)
+			}
+		}
+	}
+
+	t.Log("Case03: invalid manifest and algorithm.")
+	manifest = append(manifest, "invalid-line")
+	writeManifest()
+	if _, err := myutils.VerifyChecksumManifest(manifestPath, myutils.HashSHA256, 0); err == nil {
+		t.Fatal("want invalid manifest error")
+	}
+	if _, err := myutils.VerifyChecksums(nil, dir, "sha512", 0); err == nil {
+		t.Fatal("want unsupported algorithm error")
+	}
+}
+
+func TestChecksumResultOK(t *testing.T) {
+	t.Log("Case01: hex sum is matched case-insensitively.")
+	result := myutils.ChecksumResult{ChecksumEntry: myutils.ChecksumEntry{Sum: "ABCDEF01"}, Algo: myutils.HashSHA256, Actual: "abcdef01"}
+	if !result.OK() {
+		t.Errorf("want matched hex sum: %+v", result)
+	}
+
+	t.Log("Case02: qetag sum differs only in case is not matched, and algorithm is case-insensitive.")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	if err := ioutil.WriteFile(path, []byte("qetag content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := myutils.HashFile(path, myutils.HashQETag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, algo := range []string{myutils.HashQETag, strings.ToUpper(myutils.HashQETag)} {
+		for _, entrySum := range []string{sum, strings.ToLower(sum), strings.ToUpper(sum)} {
+			results, err := myutils.VerifyChecksums([]myutils.ChecksumEntry{{Path: "file.txt", Sum: entrySum}}, dir, algo, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expect := entrySum == sum; results[0].OK() != expect {
+				t.Errorf("want qetag (%s) matched %v: %+v", algo, expect, results[0])
+			}
+		}
+	}
+}
@@ -1,105 +1,301 @@
 package utils
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
 )
 
-// RunShellCmd runs shell command and returns output string.
-func RunShellCmd(cmd string) (string, error) {
-	if len(cmd) == 0 {
-		return "", fmt.Errorf("input command is empty")
+var (
+	// ErrCmdTimeout command is killed after timeout.
+	ErrCmdTimeout = errors.New("command timeout")
+	// ErrCmdCanceled command is killed as context is canceled.
+	ErrCmdCanceled = errors.New("command canceled")
+)
+
+// ExitError command exits with non-zero code.
+type ExitError struct {
+	Cmd      string
+	ExitCode int
+	// Stderr captured stderr of command.
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("command (%s) exit with code %d", e.Cmd, e.ExitCode)
+	if stderr := strings.TrimSpace(e.Stderr); len(stderr) > 0 {
+		msg += ": " + stderr
 	}
+	return msg
+}
 
-	cmmd := exec.Command("/bin/bash", "-c", cmd)
-	stdout, err := cmmd.StdoutPipe()
-	if err != nil {
-		return "", err
+// Command a command runner, and the whole process group of command is killed on timeout or cancel.
+type Command struct {
+	Name string
+	Args []string
+	// Env extra "key=value" env appended to env of current process.
+	Env   []string
+	Dir   string
+	Stdin io.Reader
+	// Stdout and Stderr also receive output besides captured output.
+	Stdout io.Writer
+	Stderr io.Writer
+	// OnStdoutLine and OnStderrLine are called for each output line without "\n", and calls are serialized
+	// (never concurrent), so callbacks need no locking.
+	OnStdoutLine func(line string)
+	OnStderrLine func(line string)
+	// MaxOutput max bytes of captured stdout and stderr each, and the rest are discarded.
+	// No limit if 0, and output is not captured if negative.
+	MaxOutput int
+	// Timeout no timeout if 0.
+	Timeout time.Duration
+}
+
+// CmdResult captured output and exit code of command.
+type CmdResult struct {
+	Stdout          string
+	Stderr          string
+	StdoutTruncated bool
+	StderrTruncated bool
+	ExitCode        int
+	Duration        time.Duration
+}
+
+// NewCommand returns a command runs program with args.
+func NewCommand(name string, args ...string) *Command {
+	return &Command{Name: name, Args: args}
+}
+
+// NewShellCommand returns a command runs command line by bash, or sh if bash not found.
+func NewShellCommand(line string) *Command {
+	return NewCommand(GetShell(), "-c", line)
+}
+
+// GetShell returns path of bash, or sh if bash not found.
+func GetShell() string {
+	if path, err := exec.LookPath("bash"); err == nil {
+		return path
 	}
+	return "sh"
+}
 
-	if err := cmmd.Start(); err != nil {
-		return "", err
+// String returns command line.
+func (c *Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Run runs command until exit, timeout or context canceled, and returns result with captured output even if error.
+func (c *Command) Run(ctx context.Context) (*CmdResult, error) {
+	if len(c.Name) == 0 {
+		return nil, fmt.Errorf("input command is empty")
 	}
-	b, err := ioutil.ReadAll(stdout)
-	if err != nil {
-		return "", err
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
 	}
-	if err := cmmd.Wait(); err != nil {
-		return "", err
+
+	stdout := &cappedBuffer{limit: c.MaxOutput}
+	stderr := &cappedBuffer{limit: c.MaxOutput}
+	stdoutLines := newLineWriter(c.OnStdoutLine)
+	stderrLines := newLineWriter(c.OnStderrLine)
+
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Stdin = c.Stdin
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
 	}
-	return string(b), nil
-}
+	// stdout and stderr share one mutex, as caller may pass the same writer for both
+	mutex := &sync.Mutex{}
+	cmd.Stdout = newMultiWriter(mutex, stdout, c.Stdout, stdoutLines)
+	cmd.Stderr = newMultiWriter(mutex, stderr, c.Stderr, stderrLines)
+	setProcessGroup(cmd)
 
-// RunShellCmdBuf runs shell command and writes output lines with buffer.
-func RunShellCmdBuf(cmd string) (string, error) {
-	if len(cmd) == 0 {
-		return "", fmt.Errorf("input command is empty")
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, err
 	}
 
-	cmmd := exec.Command("/bin/bash", "-c", cmd)
-	stdout, err := cmmd.StdoutPipe()
-	if err != nil {
-		return "", err
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	stdoutLines.flush()
+	stderrLines.flush()
+
+	result := &CmdResult{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+		ExitCode:        -1,
+		Duration:        time.Since(start),
 	}
-	if err := cmmd.Start(); err != nil {
-		return "", err
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if err == nil {
+		return result, nil
 	}
 
-	var lines []string
-	outputBuf := bufio.NewReader(stdout)
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return result, fmt.Errorf("%w after %v: %s", ErrCmdTimeout, c.Timeout, c)
+	case context.Canceled:
+		return result, fmt.Errorf("%w: %s", ErrCmdCanceled, c)
+	}
+	if _, ok := err.(*exec.ExitError); ok && result.ExitCode > 0 {
+		return result, &ExitError{Cmd: c.String(), ExitCode: result.ExitCode, Stderr: result.Stderr}
+	}
+	return result, fmt.Errorf("command (%s) failed: %v", c, err)
+}
+
+// cappedBuffer keeps at most limit bytes (no limit if 0, and nothing if negative), and discards the rest.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.limit == 0 {
+		return b.Buffer.Write(p)
+	}
+	if b.limit < 0 {
+		return len(p), nil
+	}
+	if remain := b.limit - b.Len(); remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.Buffer.Write(p[:remain])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// lineWriter calls fn for each line, and the last line without "\n" is called on flush.
+type lineWriter struct {
+	fn  func(line string)
+	buf bytes.Buffer
+}
+
+func newLineWriter(fn func(line string)) *lineWriter {
+	if fn == nil {
+		return nil
+	}
+	return &lineWriter{fn: fn}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
 	for {
-		b, _, err := outputBuf.ReadLine()
-		if err != nil {
-			if err.Error() != "EOF" {
-				return "", err
-			}
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
 			break
 		}
-		lines = append(lines, string(b))
+		line := string(w.buf.Next(idx + 1))
+		w.fn(strings.TrimRight(line, "\r\n"))
 	}
+	return len(p), nil
+}
 
-	if err := cmmd.Wait(); err != nil {
-		return "", err
+func (w *lineWriter) flush() {
+	if w != nil && w.buf.Len() > 0 {
+		w.fn(strings.TrimRight(w.buf.String(), "\r\n"))
+		w.buf.Reset()
 	}
-	return strings.Join(lines, "\n"), nil
 }
 
-// RunShellCmds run multiple shell commands in shell client.
-func RunShellCmds(cmds []string) (string, error) {
-	if len(cmds) == 0 {
-		return "", fmt.Errorf("input commands is empty")
+// multiWriter writes to all writers, and a failed writer (like closed connection) is skipped
+// instead of failing the command. Writes are serialized by mutex which may be shared with other multi writers.
+type multiWriter struct {
+	mutex   *sync.Mutex
+	writers []io.Writer
+}
+
+func newMultiWriter(mutex *sync.Mutex, writers ...io.Writer) io.Writer {
+	w := &multiWriter{mutex: mutex, writers: make([]io.Writer, 0, len(writers))}
+	for _, writer := range writers {
+		// typed nil pointers of line writer are skipped too
+		if writer == nil {
+			continue
+		}
+		if lw, ok := writer.(*lineWriter); ok && lw == nil {
+			continue
+		}
+		w.writers = append(w.writers, writer)
+	}
+	return w
+}
+
+func (w *multiWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for i := 0; i < len(w.writers); i++ {
+		if _, err := w.writers[i].Write(p); err != nil {
+			w.writers = append(w.writers[:i], w.writers[i+1:]...)
+			i--
+		}
 	}
+	return len(p), nil
+}
 
-	in := bytes.NewBuffer(nil)
-	shClient := exec.Command("sh")
-	shClient.Stdin = in
-	stdout, err := shClient.StdoutPipe()
+// RunShellCmd runs shell command and returns output string.
+func RunShellCmd(cmd string) (string, error) {
+	if len(cmd) == 0 {
+		return "", fmt.Errorf("input command is empty")
+	}
+	result, err := NewShellCommand(cmd).Run(context.Background())
 	if err != nil {
 		return "", err
 	}
+	return result.Stdout, nil
+}
 
-	if err := shClient.Start(); err != nil {
-		return "", err
+// RunShellCmdBuf runs shell command and returns output lines.
+func RunShellCmdBuf(cmd string) (string, error) {
+	if len(cmd) == 0 {
+		return "", fmt.Errorf("input command is empty")
 	}
-	for _, cmd := range cmds {
-		if _, err := in.WriteString(cmd + "\n"); err != nil {
-			return "", err
-		}
+
+	var lines []string
+	c := NewShellCommand(cmd)
+	c.OnStdoutLine = func(line string) {
+		lines = append(lines, line)
 	}
-	if _, err := in.WriteString("exit\n"); err != nil {
+	c.MaxOutput = -1
+	if _, err := c.Run(context.Background()); err != nil {
 		return "", err
 	}
+	return strings.Join(lines, "\n"), nil
+}
 
-	b, err := ioutil.ReadAll(stdout)
-	if err != nil {
-		return "", err
+// RunShellCmds run multiple shell commands in shell client.
+func RunShellCmds(cmds []string) (string, error) {
+	if len(cmds) == 0 {
+		return "", fmt.Errorf("input commands is empty")
 	}
-	if err := shClient.Wait(); err != nil {
+
+	c := NewCommand("sh")
+	c.Stdin = strings.NewReader(strings.Join(cmds, "\n") + "\nexit\n")
+	result, err := c.Run(context.Background())
+	if err != nil {
 		return "", err
 	}
-	return string(b), err
+	return result.Stdout, nil
 }
@@ -1,8 +1,14 @@
 package utils_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	myutils "src/tools.app/utils"
 )
@@ -47,3 +53,103 @@ func TestRunShellCmds(t *testing.T) {
 	}
 	t.Logf("commands (%s) output: %s\n", strings.Join(cmds, ","), output)
 }
+
+func TestCommand(t *testing.T) {
+	t.Log("Case01: separated stdout and stderr, with env, dir and stdin.")
+	dir := os.TempDir()
+	c := myutils.NewShellCommand(`echo "$MY_VAR"; pwd; cat; echo "err msg" >&2`)
+	c.Env = []string{"MY_VAR=hello"}
+	c.Dir = dir
+	c.Stdin = strings.NewReader("from stdin\n")
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+	if expect := "hello\n" + realDir + "\nfrom stdin\n"; result.Stdout != expect {
+		t.Errorf("Unexpected stdout: %q, expect: %q", result.Stdout, expect)
+	}
+	if result.Stderr != "err msg\n" || result.ExitCode != 0 {
+		t.Errorf("Unexpected stderr or exit code: %q, %d", result.Stderr, result.ExitCode)
+	}
+
+	t.Log("Case02: line callbacks, and output writers.")
+	lines := make([]string, 0, 3)
+	var out bytes.Buffer
+	c = myutils.NewShellCommand(`printf "line1\nline2\nline3"`)
+	c.OnStdoutLine = func(line string) {
+		lines = append(lines, line)
+	}
+	c.Stdout = &out
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, ",") != "line1,line2,line3" || out.String() != "line1\nline2\nline3" {
+		t.Errorf("Unexpected lines: %v, output: %q", lines, out.String())
+	}
+
+	t.Log("Case03: exit code in typed error.")
+	result, err = myutils.NewShellCommand(`echo "failed" >&2; exit 3`).Run(context.Background())
+	exitErr, ok := err.(*myutils.ExitError)
+	if !ok {
+		t.Fatalf("Want exit error, got: %v", err)
+	}
+	if exitErr.ExitCode != 3 || exitErr.Stderr != "failed\n" || result.ExitCode != 3 {
+		t.Errorf("Unexpected exit error: %+v", exitErr)
+	}
+
+	t.Log("Case04: captured output is capped.")
+	c = myutils.NewShellCommand(`printf "0123456789"`)
+	c.MaxOutput = 4
+	if result, err = c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "0123" || !result.StdoutTruncated {
+		t.Errorf("Unexpected capped output: %q, truncated: %v", result.Stdout, result.StdoutTruncated)
+	}
+
+	t.Log("Case05: process group is killed on timeout.")
+	c = myutils.NewShellCommand(`sleep 10 & sleep 10; wait`)
+	c.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	_, err = c.Run(context.Background())
+	if !errors.Is(err, myutils.ErrCmdTimeout) {
+		t.Errorf("Want timeout error, got: %v", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Command is not killed after timeout: %v", d)
+	}
+
+	t.Log("Case06: command is killed when context is canceled.")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err = myutils.NewShellCommand(`sleep 10`).Run(ctx); !errors.Is(err, myutils.ErrCmdCanceled) {
+		t.Errorf("Want canceled error, got: %v", err)
+	}
+
+	t.Log("Case07: same unsynchronized writer for stdout and stderr (run with -race).")
+	out.Reset()
+	c = myutils.NewShellCommand(`for i in $(seq 200); do echo "out$i"; echo "err$i" >&2; done`)
+	c.Stdout = &out
+	c.Stderr = &out
+	if _, err = c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 400 {
+		t.Errorf("Unexpected lines of output: %d, expect: 400", n)
+	}
+
+	t.Log("Case08: stdout and stderr line callbacks are serialized (run with -race).")
+	lines = lines[:0]
+	c = myutils.NewShellCommand(`for i in $(seq 200); do echo "out$i"; echo "err$i" >&2; done`)
+	c.OnStdoutLine = func(line string) {
+		lines = append(lines, line)
+	}
+	c.OnStderrLine = c.OnStdoutLine
+	if _, err = c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 400 {
+		t.Errorf("Unexpected lines: %d, expect: 400", len(lines))
+	}
+}
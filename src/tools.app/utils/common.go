@@ -14,11 +14,15 @@ func GetCurrentDateTime() string {
 }
 
 // NewLoggerToStdout returns a custom logger write to stdout.
+//
+// Deprecated: use logging.Get of src/tools.app/utils/logging, which supports levels and shared configs.
 func NewLoggerToStdout(prefix string) *log.Logger {
 	return log.New(os.Stdout, prefix, log.Ldate|log.Ltime|log.Lshortfile)
 }
 
 // NewLoggerToFile returns a custom logger write to specified file.
+//
+// Deprecated: use logging.Init with output file of src/tools.app/utils/logging, which supports rotation.
 func NewLoggerToFile(path, prefix string) (*log.Logger, error) {
 	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
@@ -1,9 +1,20 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 // GetBase64Text returns base64 text.
@@ -44,3 +55,141 @@ func getMd5EncodedText(text, md5Type string) string {
 	}
 	return base64.URLEncoding.EncodeToString(b)
 }
+
+// Hash algorithms of NewHash.
+const (
+	HashMD5    = "md5"
+	HashSHA1   = "sha1"
+	HashSHA256 = "sha256"
+	HashCRC32  = "crc32"
+	HashXXHash = "xxhash"
+	// HashQETag qiniu etag, which is url base64 text instead of hex.
+	HashQETag = "qetag"
+)
+
+// NewHash returns hash of algorithm (case insensitive), and crc32 is IEEE, xxhash is xxh64.
+func NewHash(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case HashMD5:
+		return md5.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashCRC32:
+		return crc32.NewIEEE(), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	case HashQETag:
+		return NewQETagHash(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+}
+
+// HashReader returns hash text of reader content, which is hex text, or url base64 text for qetag.
+func HashReader(r io.Reader, algo string) (string, error) {
+	sums, err := HashReaderMulti(r, algo)
+	if err != nil {
+		return "", err
+	}
+	return sums[algo], nil
+}
+
+// HashReaderMulti returns hash texts of reader content by algorithms in one pass.
+func HashReaderMulti(r io.Reader, algos ...string) (map[string]string, error) {
+	hashes := make([]hash.Hash, 0, len(algos))
+	writers := make([]io.Writer, 0, len(algos))
+	for _, algo := range algos {
+		h, err := NewHash(algo)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+		writers = append(writers, h)
+	}
+
+	buf := make([]byte, 128*1024)
+	if _, err := io.CopyBuffer(io.MultiWriter(writers...), r, buf); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(algos))
+	for i, algo := range algos {
+		sums[algo] = encodeHashSum(algo, hashes[i].Sum(nil))
+	}
+	return sums, nil
+}
+
+// HashFile returns hash text of file content.
+func HashFile(path, algo string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return HashReader(f, algo)
+}
+
+// HashFileMulti returns hash texts of file content by algorithms in one pass.
+func HashFileMulti(path string, algos ...string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return HashReaderMulti(f, algos...)
+}
+
+func encodeHashSum(algo string, sum []byte) string {
+	if strings.ToLower(algo) == HashQETag {
+		return base64.URLEncoding.EncodeToString(sum)
+	}
+	return hex.EncodeToString(sum)
+}
+
+/* HMAC */
+
+// HMACSign returns hmac of data by hash algorithm (md5, sha1 or sha256).
+func HMACSign(algo string, key, data []byte) ([]byte, error) {
+	var fn func() hash.Hash
+	switch strings.ToLower(algo) {
+	case HashMD5:
+		fn = md5.New
+	case HashSHA1:
+		fn = sha1.New
+	case HashSHA256:
+		fn = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported hmac algorithm: %s", algo)
+	}
+	mac := hmac.New(fn, key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// HMACVerify returns whether sign is hmac of data, and it's compared in constant time.
+func HMACVerify(algo string, key, data, sign []byte) bool {
+	expected, err := HMACSign(algo, key, data)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, sign)
+}
+
+// GetHMACHexText returns hmac hex text.
+func GetHMACHexText(algo, key, text string) (string, error) {
+	sign, err := HMACSign(algo, []byte(key), []byte(text))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sign), nil
+}
+
+// GetURLBase64HMACText returns url base64 hmac text, like sign of qiniu access token.
+func GetURLBase64HMACText(algo, key, text string) (string, error) {
+	sign, err := HMACSign(algo, []byte(key), []byte(text))
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(sign), nil
+}
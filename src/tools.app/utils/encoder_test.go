@@ -1,6 +1,8 @@
 package utils_test
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"strings"
 	"testing"
@@ -41,3 +43,107 @@ func TestGetMd5HexText(t *testing.T) {
 	}
 	t.Logf("md5 text: %s", retText)
 }
+
+func TestHashReader(t *testing.T) {
+	const testText = "hello world"
+	sums := map[string]string{
+		myutils.HashMD5:    "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		myutils.HashSHA1:   "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+		myutils.HashSHA256: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		myutils.HashCRC32:  "0d4a1185",
+		myutils.HashXXHash: "45ab6734b21e6968",
+		myutils.HashQETag:  "FiqubDXJT8-0FdvpX0CLnOke6Ebt",
+	}
+
+	t.Log("Case01: hash reader by each algorithm.")
+	algos := make([]string, 0, len(sums))
+	for algo, want := range sums {
+		algos = append(algos, algo)
+		got, err := myutils.HashReader(strings.NewReader(testText), algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("want %s sum %s, got %s", algo, want, got)
+		}
+	}
+
+	t.Log("Case02: hash reader by all algorithms in one pass.")
+	got, err := myutils.HashReaderMulti(strings.NewReader(testText), algos...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for algo, want := range sums {
+		if got[algo] != want {
+			t.Fatalf("want %s sum %s, got %s", algo, want, got[algo])
+		}
+	}
+
+	t.Log("Case03: unsupported algorithm.")
+	if _, err := myutils.HashReader(strings.NewReader(testText), "sha512"); err == nil {
+		t.Fatal("want unsupported algorithm error")
+	}
+}
+
+func TestQETag(t *testing.T) {
+	data := make([]byte, 9*1024*1024+7)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	cases := []struct {
+		desc string
+		data []byte
+		want string
+	}{
+		{"empty content", nil, "Fto5o-5ea0sNMlW_75VgGJCv2AcJ"},
+		{"content of one full block", data[:myutils.QETagBlockSize], "Fgd8eREZ4FXnoK5eUHCJo_kRSDb1"},
+		{"content of multiple blocks", data, "ll3xMcqaW_ujrNuEfJefK7aJvTLh"},
+	}
+	for i, c := range cases {
+		t.Logf("Case%02d: qetag of %s.", i+1, c.desc)
+		got, err := myutils.GetQETag(bytes.NewReader(c.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Fatalf("want qetag %s, got %s", c.want, got)
+		}
+	}
+
+	t.Log("Case04: qetag of content written in small chunks.")
+	h := myutils.NewQETagHash()
+	for b := data; len(b) > 0; {
+		n := 1000
+		if n > len(b) {
+			n = len(b)
+		}
+		h.Write(b[:n])
+		b = b[n:]
+	}
+	if got := base64.URLEncoding.EncodeToString(h.Sum(nil)); got != cases[2].want {
+		t.Fatalf("want qetag %s, got %s", cases[2].want, got)
+	}
+}
+
+func TestHMACSign(t *testing.T) {
+	t.Log("Case01: hmac hex and url base64 text.")
+	if got, err := myutils.GetHMACHexText("sha1", "secret", "hello world"); err != nil || got != "03376ee7ad7bbfceee98660439a4d8b125122a5a" {
+		t.Fatalf("unexpected hmac-sha1: %s, %v", got, err)
+	}
+	if got, err := myutils.GetURLBase64HMACText("sha256", "secret", "hello world"); err != nil || got != "c0zGLzKEFWj0VxWuufTXiRMk5tlI5MbGDAYhzaxIYjo=" {
+		t.Fatalf("unexpected hmac-sha256: %s, %v", got, err)
+	}
+
+	t.Log("Case02: verify hmac.")
+	sign, err := myutils.HMACSign("sha256", []byte("secret"), []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !myutils.HMACVerify("sha256", []byte("secret"), []byte("data"), sign) {
+		t.Fatal("valid sign is not verified")
+	}
+	if myutils.HMACVerify("sha256", []byte("other"), []byte("data"), sign) {
+		t.Fatal("sign of other key is verified")
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrFileLocked file is locked by others.
+var ErrFileLocked = errors.New("file is locked")
+
+// FileLock an advisory lock of file between processes, and also between goroutines of different FileLock.
+// It only works with cooperating writers which lock the same file.
+type FileLock struct {
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileLock returns a lock of path, and lock file is created if not exist.
+// Lock a separate file (like "data.json.lock") if data file is replaced by WriteFileAtomic.
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// Lock acquires exclusive lock, and blocks until it's available.
+func (l *FileLock) Lock() error {
+	return l.lock(true, true)
+}
+
+// RLock acquires shared lock, and blocks until it's available.
+func (l *FileLock) RLock() error {
+	return l.lock(false, true)
+}
+
+// TryLock acquires exclusive lock without blocking, and returns false if it's held by others.
+func (l *FileLock) TryLock() (bool, error) {
+	err := l.lock(true, false)
+	if err == ErrFileLocked {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Unlock releases lock.
+func (l *FileLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return errors.New("file is not locked: " + l.path)
+	}
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+func (l *FileLock) lock(exclusive, block bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file != nil {
+		return errors.New("file is already locked: " + l.path)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(f, exclusive, block); err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	return nil
+}
+
+// WithFileLock runs fn with exclusive lock of path + ".lock".
+func WithFileLock(path string, fn func() error) error {
+	l := NewFileLock(path + ".lock")
+	if err := l.Lock(); err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return fn()
+}
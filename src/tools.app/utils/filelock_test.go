@@ -0,0 +1,79 @@
+package utils_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	myutils "src/tools.app/utils"
+)
+
+func TestFileLock(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "test.lock")
+
+	t.Log("Case01: exclusive lock is not acquired when it's held by others.")
+	l1, l2 := myutils.NewFileLock(lockPath), myutils.NewFileLock(lockPath)
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := l2.TryLock(); err != nil || ok {
+		t.Fatalf("want lock held by others, got ok=%v, err=%v", ok, err)
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := l2.TryLock(); err != nil || !ok {
+		t.Fatalf("want lock acquired, got ok=%v, err=%v", ok, err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Unlock(); err == nil {
+		t.Fatal("want error for unlock twice")
+	}
+
+	t.Log("Case02: shared locks are held together, and block exclusive lock.")
+	if err := l1.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	l3 := myutils.NewFileLock(lockPath)
+	if ok, err := l3.TryLock(); err != nil || ok {
+		t.Fatalf("want lock held by readers, got ok=%v, err=%v", ok, err)
+	}
+	l1.Unlock()
+	l2.Unlock()
+
+	t.Log("Case03: concurrent read-modify-write with file lock.")
+	counterPath := filepath.Join(dir, "counter.txt")
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- myutils.WithFileLock(counterPath, func() error {
+				count := 0
+				if content, err := myutils.ReadFileContent(counterPath); err == nil {
+					count, _ = strconv.Atoi(content)
+				}
+				return myutils.WriteFileAtomic(counterPath, []byte(strconv.Itoa(count+1)), 0644)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if content, _ := myutils.ReadFileContent(counterPath); content != strconv.Itoa(workers) {
+		t.Fatalf("want count %d, got %s", workers, content)
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// FollowOptions options of FollowFile.
+type FollowOptions struct {
+	// FromStart reads existing lines from start of file, instead of only new lines from end.
+	FromStart bool
+	// PollInterval interval to check new lines, rotation and truncation, default is 200ms.
+	PollInterval time.Duration
+}
+
+// FollowFile calls fn for each line appended to file like "tail -F", until ctx is done (nil is returned)
+// or fn returns error (nil is returned for ErrStopIteration). It waits for file if not exist.
+// When file is rotated (renamed or removed, and then recreated), rest lines of old file are read,
+// and then new file is followed from start. When file is truncated, it's followed from start.
+func FollowFile(ctx context.Context, path string, opts *FollowOptions, fn func(line string) error) error {
+	f := &follower{path: path, fn: fn}
+	if opts != nil {
+		f.opts = *opts
+	}
+	if f.opts.PollInterval <= 0 {
+		f.opts.PollInterval = 200 * time.Millisecond
+	}
+	defer f.close()
+
+	err := f.run(ctx)
+	if err == ErrStopIteration {
+		return nil
+	}
+	return err
+}
+
+type follower struct {
+	path   string
+	opts   FollowOptions
+	fn     func(line string) error
+	file   *os.File
+	info   os.FileInfo
+	reader *bufio.Reader
+	offset int64
+	// pending last line without "\n" which may be still in writing
+	pending strings.Builder
+}
+
+func (f *follower) run(ctx context.Context) error {
+	fromStart := f.opts.FromStart
+	for {
+		if f.file == nil {
+			err := f.open(fromStart)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			// files created later are followed from start
+			fromStart = true
+			if os.IsNotExist(err) {
+				if !f.wait(ctx) {
+					return nil
+				}
+				continue
+			}
+		}
+
+		if err := f.readLines(); err != nil {
+			return err
+		}
+		rotated, truncated, err := f.check()
+		if err != nil {
+			return err
+		}
+		if rotated {
+			// read rest lines written before rotation
+			if err := f.readLines(); err != nil {
+				return err
+			}
+			if err := f.flush(); err != nil {
+				return err
+			}
+			f.close()
+			continue
+		}
+		if truncated {
+			if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			f.reader.Reset(f.file)
+			f.offset = 0
+			f.pending.Reset()
+			continue
+		}
+		if !f.wait(ctx) {
+			return nil
+		}
+	}
+}
+
+func (f *follower) open(fromStart bool) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	var offset int64
+	if !fromStart {
+		if offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	f.file, f.info, f.offset = file, info, offset
+	f.reader = bufio.NewReader(file)
+	return nil
+}
+
+// readLines reads until EOF, and the last line without "\n" is kept as pending.
+func (f *follower) readLines() error {
+	for {
+		line, err := f.reader.ReadString('\n')
+		f.offset += int64(len(line))
+		if err != nil {
+			f.pending.WriteString(line)
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if f.pending.Len() > 0 {
+			line = f.pending.String() + line
+			f.pending.Reset()
+		}
+		if err := f.fn(strings.TrimRight(line, "\r\n")); err != nil {
+			return err
+		}
+	}
+}
+
+// check returns whether file of path is rotated (removed or replaced), or truncated.
+func (f *follower) check() (rotated, truncated bool, err error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, false, nil
+		}
+		return false, false, err
+	}
+	if !os.SameFile(info, f.info) {
+		return true, false, nil
+	}
+	return false, info.Size() < f.offset, nil
+}
+
+// flush calls fn for pending line.
+func (f *follower) flush() error {
+	if f.pending.Len() == 0 {
+		return nil
+	}
+	line := f.pending.String()
+	f.pending.Reset()
+	return f.fn(strings.TrimRight(line, "\r\n"))
+}
+
+func (f *follower) wait(ctx context.Context) bool {
+	t := time.NewTimer(f.opts.PollInterval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func (f *follower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
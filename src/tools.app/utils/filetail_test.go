@@ -0,0 +1,84 @@
+package utils_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	myutils "src/tools.app/utils"
+)
+
+func TestFollowFile(t *testing.T) {
+	fPath := filepath.Join(t.TempDir(), "app.log")
+	if err := myutils.WriteContentToFile(fPath, "old\n", true); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		opts := &myutils.FollowOptions{FromStart: true, PollInterval: 10 * time.Millisecond}
+		done <- myutils.FollowFile(ctx, fPath, opts, func(line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Fatalf("want line %q, got %q", want, line)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("wait for line %q timeout", want)
+		}
+	}
+	appendContent := func(content string) {
+		t.Helper()
+		if _, err := myutils.AppendContentToFile(fPath, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Log("Case01: follow existing and appended lines, and partial line is joined.")
+	expect("old")
+	appendContent("a\nb")
+	expect("a")
+	time.Sleep(30 * time.Millisecond)
+	appendContent("c\n")
+	expect("bc")
+
+	t.Log("Case02: rest lines of rotated file are read, and then new file is followed.")
+	appendContent("d\n")
+	if err := os.Rename(fPath, fPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendContent("e\n")
+	expect("d")
+	expect("e")
+
+	t.Log("Case03: truncated file is followed from start.")
+	if err := os.Truncate(fPath, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendContent("f\n")
+	expect("f")
+
+	t.Log("Case04: stop following when context is done.")
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait for follower exit timeout")
+	}
+}
@@ -0,0 +1,116 @@
+package httpresp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error an api error which is written as {"error": {...}}, or problem details of RFC 7807.
+type Error struct {
+	// Status http status code.
+	Status int `json:"status"`
+	// Code machine readable error code, default is snake case of status text, like "not_found".
+	Code string `json:"code"`
+	// Message human readable error message, which is safe to show to clients.
+	Message string `json:"message"`
+	// Details extra info of error, like invalid fields.
+	Details interface{} `json:"details,omitempty"`
+	// RequestID id of request, which is set when error is written.
+	RequestID string `json:"request_id,omitempty"`
+
+	// cause internal error which is logged, but not written to clients.
+	cause error
+}
+
+// NewError returns error with status, and code by status.
+func NewError(status int, message string) *Error {
+	return &Error{Status: status, Code: StatusCode(status), Message: message}
+}
+
+// Errorf returns error with status, and formatted message.
+func Errorf(status int, format string, args ...interface{}) *Error {
+	return NewError(status, fmt.Sprintf(format, args...))
+}
+
+// BadRequest returns error with status 400.
+func BadRequest(message string) *Error {
+	return NewError(http.StatusBadRequest, message)
+}
+
+// Unauthorized returns error with status 401.
+func Unauthorized(message string) *Error {
+	return NewError(http.StatusUnauthorized, message)
+}
+
+// Forbidden returns error with status 403.
+func Forbidden(message string) *Error {
+	return NewError(http.StatusForbidden, message)
+}
+
+// NotFound returns error with status 404.
+func NotFound(message string) *Error {
+	return NewError(http.StatusNotFound, message)
+}
+
+// Conflict returns error with status 409.
+func Conflict(message string) *Error {
+	return NewError(http.StatusConflict, message)
+}
+
+// Internal returns error with status 500 and a generic message, and cause is only logged.
+func Internal(cause error) *Error {
+	return NewError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).WithCause(cause)
+}
+
+// WithCode sets error code.
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+// WithDetails sets error details.
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// WithCause sets internal cause of error.
+func (e *Error) WithCause(cause error) *Error {
+	e.cause = cause
+	return e
+}
+
+// Error returns error text with cause.
+func (e *Error) Error() string {
+	text := fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+	if e.cause != nil {
+		text += ": " + e.cause.Error()
+	}
+	return text
+}
+
+// Unwrap returns internal cause of error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// AsError returns *Error in chain of err, or an internal error with err as cause.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
+
+// StatusCode returns error code of http status, like "too_many_requests" for 429.
+func StatusCode(status int) string {
+	text := http.StatusText(status)
+	if len(text) == 0 {
+		return "unknown_error"
+	}
+	text = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+	return strings.ToLower(text)
+}
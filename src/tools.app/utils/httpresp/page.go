@@ -0,0 +1,83 @@
+package httpresp
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Pagination pagination meta of list response.
+type Pagination struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewPagination returns pagination of page (starts from 1) and total count of items.
+func NewPagination(page, pageSize int, total int64) *Pagination {
+	p := &Pagination{Page: page, PageSize: pageSize}
+	p.SetTotal(total)
+	return p
+}
+
+// ParsePagination parses "page" and "page_size" from request query. Default page is 1, default size
+// is defaultSize, and size is limited to maxSize if maxSize > 0. Bad request error is returned for invalid values.
+func ParsePagination(r *http.Request, defaultSize, maxSize int) (*Pagination, error) {
+	query := r.URL.Query()
+	page, err := parsePositiveInt(query.Get("page"), 1)
+	if err != nil {
+		return nil, BadRequest("invalid page: " + query.Get("page"))
+	}
+	size, err := parsePositiveInt(query.Get("page_size"), defaultSize)
+	if err != nil {
+		return nil, BadRequest("invalid page_size: " + query.Get("page_size"))
+	}
+	if maxSize > 0 && size > maxSize {
+		size = maxSize
+	}
+	return &Pagination{Page: page, PageSize: size}, nil
+}
+
+func parsePositiveInt(value string, defaultValue int) (int, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if i <= 0 {
+		return 0, strconv.ErrRange
+	}
+	return i, nil
+}
+
+// SetTotal sets total count of items, and updates total pages.
+func (p *Pagination) SetTotal(total int64) {
+	p.Total = total
+	p.TotalPages = 0
+	if p.PageSize > 0 {
+		p.TotalPages = int((total + int64(p.PageSize) - 1) / int64(p.PageSize))
+	}
+}
+
+// Offset returns index of first item in current page.
+func (p *Pagination) Offset() int {
+	if p.Page <= 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.PageSize
+}
+
+// Bounds returns [start, end) of current page in items of length n, which can be used to slice items.
+func (p *Pagination) Bounds(n int) (start, end int) {
+	start = p.Offset()
+	if start > n {
+		start = n
+	}
+	end = start + p.PageSize
+	if p.PageSize <= 0 || end > n {
+		end = n
+	}
+	return start, end
+}
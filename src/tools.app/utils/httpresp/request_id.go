@@ -0,0 +1,55 @@
+package httpresp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+// RequestID returns id of request set by SetRequestID, or from request header "X-Request-Id".
+func RequestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return r.Header.Get(HeaderRequestID)
+}
+
+// SetRequestID uses id from request header "X-Request-Id" or creates a new one, and then sets it to
+// response header and context of returned request.
+func SetRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(HeaderRequestID)
+	if len(id) == 0 {
+		id = newRequestID()
+	}
+	w.Header().Set(HeaderRequestID, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// RequestIDHandler returns handler which sets request id before calling next handler.
+func RequestIDHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, SetRequestID(w, r))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDOf returns id of request, or from response header if r is nil.
+func requestIDOf(w http.ResponseWriter, r *http.Request) string {
+	if id := RequestID(r); len(id) > 0 {
+		return id
+	}
+	return w.Header().Get(HeaderRequestID)
+}
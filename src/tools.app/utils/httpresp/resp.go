@@ -0,0 +1,223 @@
+// Package httpresp writes json responses of services in a unified format:
+//
+//	ok:    {"meta": {"request_id": "...", "pagination": {...}}, "data": ...}
+//	error: {"error": {"status": 404, "code": "not_found", "message": "...", "details": ..., "request_id": "..."}}
+//
+// Errors are written as RFC 7807 problem details ("application/problem+json") if Writer.Problem is set,
+// or request accepts it.
+package httpresp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HeaderContentType header of content type.
+	HeaderContentType = "Content-Type"
+	// HeaderContentLength header of content length.
+	HeaderContentLength = "Content-Length"
+	// HeaderRequestID header of request id.
+	HeaderRequestID = "X-Request-Id"
+
+	// ContentTypeJSON content type of json.
+	ContentTypeJSON = "application/json; charset=utf-8"
+	// ContentTypeProblemJSON content type of problem details (RFC 7807).
+	ContentTypeProblemJSON = "application/problem+json; charset=utf-8"
+	// ContentTypeHTML content type of html.
+	ContentTypeHTML = "text/html; charset=utf-8"
+	// ContentTypeText content type of plain text.
+	ContentTypeText = "text/plain; charset=utf-8"
+)
+
+// Response json response of data.
+type Response struct {
+	// Meta is null if there's no request id or pagination.
+	Meta *Meta       `json:"meta"`
+	Data interface{} `json:"data"`
+}
+
+// Meta meta of json response.
+type Meta struct {
+	RequestID  string      `json:"request_id,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// ErrorResponse json response of error.
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// Problem problem details of RFC 7807, with error code, details and request id as extension members.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      string      `json:"code,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Writer writes json responses.
+type Writer struct {
+	// Problem writes errors as problem details, otherwise they're only written for requests which accept it.
+	Problem bool
+	// ProblemTypeBase base uri of problem type, which is joined with error code, like "https://example.com/probs/not_found".
+	// Type is "about:blank" if it's empty.
+	ProblemTypeBase string
+	// OnError is called before server errors (status >= 500) are written, default is logging the error.
+	OnError func(r *http.Request, e *Error)
+}
+
+// DefaultWriter writer used by WriteXXX functions.
+var DefaultWriter = &Writer{}
+
+// OK writes data with status 200.
+func (wr *Writer) OK(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	return wr.Data(w, r, http.StatusOK, data)
+}
+
+// Data writes data with status.
+func (wr *Writer) Data(w http.ResponseWriter, r *http.Request, status int, data interface{}) error {
+	resp := &Response{Data: data}
+	if id := requestIDOf(w, r); len(id) > 0 {
+		resp.Meta = &Meta{RequestID: id}
+	}
+	return wr.JSON(w, r, status, resp)
+}
+
+// Page writes a page of list with pagination meta, and status 200.
+func (wr *Writer) Page(w http.ResponseWriter, r *http.Request, data interface{}, p *Pagination) error {
+	return wr.JSON(w, r, http.StatusOK, &Response{
+		Meta: &Meta{RequestID: requestIDOf(w, r), Pagination: p},
+		Data: data,
+	})
+}
+
+// Error writes err as json error, and err which is not *Error is written as internal server error.
+func (wr *Writer) Error(w http.ResponseWriter, r *http.Request, err error) error {
+	e := *AsError(err)
+	if e.Status == 0 {
+		e.Status = http.StatusInternalServerError
+	}
+	if len(e.Code) == 0 {
+		e.Code = StatusCode(e.Status)
+	}
+	e.RequestID = requestIDOf(w, r)
+	if e.Status >= http.StatusInternalServerError {
+		wr.onError(r, &e)
+	}
+
+	if wr.acceptProblem(r) {
+		return wr.write(w, r, e.Status, ContentTypeProblemJSON, wr.problem(r, &e))
+	}
+	return wr.write(w, r, e.Status, ContentTypeJSON, &ErrorResponse{Error: &e})
+}
+
+// JSON writes v as json with status.
+func (wr *Writer) JSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	return wr.write(w, r, status, ContentTypeJSON, v)
+}
+
+func (wr *Writer) write(w http.ResponseWriter, r *http.Request, status int, contentType string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		// response is not written yet, so it's replaced by internal error
+		err = fmt.Errorf("marshal json response failed: %w", err)
+		e := Internal(err)
+		e.RequestID = requestIDOf(w, r)
+		wr.onError(r, e)
+		b, _ = json.Marshal(&ErrorResponse{Error: e})
+		status, contentType = e.Status, ContentTypeJSON
+	}
+
+	w.Header().Set(HeaderContentType, contentType)
+	w.WriteHeader(status)
+	if _, writeErr := w.Write(append(b, '\n')); err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+func (wr *Writer) onError(r *http.Request, e *Error) {
+	if wr.OnError != nil {
+		wr.OnError(r, e)
+		return
+	}
+	if r != nil {
+		log.Printf("server error (%s %s): %v\n", r.Method, r.URL.Path, e)
+	} else {
+		log.Println("server error:", e)
+	}
+}
+
+func (wr *Writer) acceptProblem(r *http.Request) bool {
+	if wr.Problem {
+		return true
+	}
+	return r != nil && strings.Contains(r.Header.Get("Accept"), "application/problem+json")
+}
+
+func (wr *Writer) problem(r *http.Request, e *Error) *Problem {
+	p := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Message,
+		Code:      e.Code,
+		Details:   e.Details,
+		RequestID: e.RequestID,
+	}
+	if len(wr.ProblemTypeBase) > 0 {
+		p.Type = strings.TrimRight(wr.ProblemTypeBase, "/") + "/" + e.Code
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	return p
+}
+
+// HTML writes html with status.
+func (wr *Writer) HTML(w http.ResponseWriter, status int, data []byte) error {
+	w.Header().Set(HeaderContentType, ContentTypeHTML)
+	w.Header().Set(HeaderContentLength, strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	_, err := w.Write(data)
+	return err
+}
+
+// WriteOK writes data with status 200 by DefaultWriter, and r can be nil.
+func WriteOK(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	return DefaultWriter.OK(w, r, data)
+}
+
+// WriteData writes data with status by DefaultWriter.
+func WriteData(w http.ResponseWriter, r *http.Request, status int, data interface{}) error {
+	return DefaultWriter.Data(w, r, status, data)
+}
+
+// WritePage writes a page of list with pagination meta by DefaultWriter.
+func WritePage(w http.ResponseWriter, r *http.Request, data interface{}, p *Pagination) error {
+	return DefaultWriter.Page(w, r, data, p)
+}
+
+// WriteError writes err by DefaultWriter.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) error {
+	return DefaultWriter.Error(w, r, err)
+}
+
+// WriteJSON writes v as json with status by DefaultWriter.
+func WriteJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	return DefaultWriter.JSON(w, r, status, v)
+}
+
+// WriteHTML writes html with status by DefaultWriter.
+func WriteHTML(w http.ResponseWriter, status int, data []byte) error {
+	return DefaultWriter.HTML(w, status, data)
+}
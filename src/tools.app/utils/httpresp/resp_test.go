@@ -0,0 +1,180 @@
+package httpresp_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"src/tools.app/utils/httpresp"
+)
+
+func checkResp(t *testing.T, rr *httptest.ResponseRecorder, status int, contentType, body string) {
+	t.Helper()
+	if rr.Code != status {
+		t.Fatalf("want status %d, got %d", status, rr.Code)
+	}
+	if ct := rr.Header().Get(httpresp.HeaderContentType); ct != contentType {
+		t.Fatalf("want content type %s, got %s", contentType, ct)
+	}
+	if got := strings.TrimRight(rr.Body.String(), "\n"); got != body {
+		t.Fatalf("unexpected body:\nwant: %s\ngot:  %s", body, got)
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	t.Log("Case01: data without meta.")
+	rr := httptest.NewRecorder()
+	if err := httpresp.WriteOK(rr, nil, map[string]int{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusOK, httpresp.ContentTypeJSON, `{"meta":null,"data":{"id":1}}`)
+
+	t.Log("Case02: data with request id of request.")
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set(httpresp.HeaderRequestID, "req-001")
+	rr = httptest.NewRecorder()
+	if err := httpresp.WriteData(rr, req, http.StatusCreated, "ok"); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusCreated, httpresp.ContentTypeJSON, `{"meta":{"request_id":"req-001"},"data":"ok"}`)
+
+	t.Log("Case03: page with pagination meta.")
+	rr = httptest.NewRecorder()
+	if err := httpresp.WritePage(rr, req, []int{1, 2}, httpresp.NewPagination(2, 2, 5)); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusOK, httpresp.ContentTypeJSON,
+		`{"meta":{"request_id":"req-001","pagination":{"page":2,"page_size":2,"total":5,"total_pages":3}},"data":[1,2]}`)
+
+	t.Log("Case04: data which can't be marshalled is written as internal error.")
+	rr = httptest.NewRecorder()
+	if err := httpresp.WriteOK(rr, nil, make(chan int)); err == nil {
+		t.Fatal("want marshal error")
+	}
+	checkResp(t, rr, http.StatusInternalServerError, httpresp.ContentTypeJSON,
+		`{"error":{"status":500,"code":"internal_server_error","message":"Internal Server Error"}}`)
+}
+
+func TestWriteError(t *testing.T) {
+	var logged []error
+	wr := &httpresp.Writer{OnError: func(r *http.Request, e *httpresp.Error) {
+		logged = append(logged, e)
+	}}
+
+	t.Log("Case01: typed error with details.")
+	rr := httptest.NewRecorder()
+	rr.Header().Set(httpresp.HeaderRequestID, "req-002")
+	e := httpresp.BadRequest("invalid name").WithDetails(map[string]string{"name": "required"})
+	if err := wr.Error(rr, nil, e); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusBadRequest, httpresp.ContentTypeJSON,
+		`{"error":{"status":400,"code":"bad_request","message":"invalid name","details":{"name":"required"},"request_id":"req-002"}}`)
+	if len(e.RequestID) > 0 {
+		t.Fatal("written error is changed")
+	}
+
+	t.Log("Case02: wrapped typed error, and custom code.")
+	rr = httptest.NewRecorder()
+	err := fmt.Errorf("get user: %w", httpresp.NotFound("user not found").WithCode("user_not_found"))
+	if err := wr.Error(rr, nil, err); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusNotFound, httpresp.ContentTypeJSON,
+		`{"error":{"status":404,"code":"user_not_found","message":"user not found"}}`)
+
+	t.Log("Case03: other errors are written as internal error, and cause is only logged.")
+	rr = httptest.NewRecorder()
+	if err := wr.Error(rr, nil, errors.New("db is down")); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusInternalServerError, httpresp.ContentTypeJSON,
+		`{"error":{"status":500,"code":"internal_server_error","message":"Internal Server Error"}}`)
+	if len(logged) != 1 || !strings.HasSuffix(logged[0].Error(), ": db is down") {
+		t.Fatalf("unexpected logged errors: %v", logged)
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	t.Log("Case01: problem details for request which accepts it.")
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("Accept", "application/problem+json, application/json")
+	rr := httptest.NewRecorder()
+	if err := httpresp.WriteError(rr, req, httpresp.NotFound("user not found")); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusNotFound, httpresp.ContentTypeProblemJSON,
+		`{"type":"about:blank","title":"Not Found","status":404,"detail":"user not found","instance":"/users/1","code":"not_found"}`)
+
+	t.Log("Case02: problem details by writer, with type of base uri.")
+	wr := &httpresp.Writer{Problem: true, ProblemTypeBase: "https://example.com/probs/"}
+	rr = httptest.NewRecorder()
+	if err := wr.Error(rr, nil, httpresp.Errorf(http.StatusTooManyRequests, "limit %d/s", 10)); err != nil {
+		t.Fatal(err)
+	}
+	checkResp(t, rr, http.StatusTooManyRequests, httpresp.ContentTypeProblemJSON,
+		`{"type":"https://example.com/probs/too_many_requests","title":"Too Many Requests","status":429,"detail":"limit 10/s","code":"too_many_requests"}`)
+}
+
+func TestRequestID(t *testing.T) {
+	var id string
+	handler := httpresp.RequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = httpresp.RequestID(r)
+		httpresp.WriteOK(w, nil, nil)
+	}))
+
+	t.Log("Case01: request id is created if not set.")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if len(id) != 32 || rr.Header().Get(httpresp.HeaderRequestID) != id {
+		t.Fatalf("unexpected request id: %q", id)
+	}
+	checkResp(t, rr, http.StatusOK, httpresp.ContentTypeJSON, `{"meta":{"request_id":"`+id+`"},"data":null}`)
+
+	t.Log("Case02: request id from request header.")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(httpresp.HeaderRequestID, "req-003")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if id != "req-003" || rr.Header().Get(httpresp.HeaderRequestID) != id {
+		t.Fatalf("unexpected request id: %q", id)
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	cases := []struct {
+		query       string
+		page, size  int
+		start, end  int
+		expectedErr bool
+	}{
+		{"", 1, 10, 0, 10, false},
+		{"page=3&page_size=5", 3, 5, 10, 15, false},
+		{"page=2&page_size=500", 2, 100, 25, 25, false},
+		{"page=0", 0, 0, 0, 0, true},
+		{"page_size=x", 0, 0, 0, 0, true},
+	}
+	for i, c := range cases {
+		t.Logf("Case%02d: parse pagination of query %q.", i+1, c.query)
+		p, err := httpresp.ParsePagination(httptest.NewRequest("GET", "/items?"+c.query, nil), 10, 100)
+		if c.expectedErr {
+			var e *httpresp.Error
+			if !errors.As(err, &e) || e.Status != http.StatusBadRequest {
+				t.Fatalf("want bad request error, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Page != c.page || p.PageSize != c.size {
+			t.Fatalf("unexpected pagination: %+v", p)
+		}
+		if start, end := p.Bounds(25); start != c.start || end != c.end {
+			t.Fatalf("unexpected bounds of 25 items: [%d, %d)", start, end)
+		}
+	}
+}
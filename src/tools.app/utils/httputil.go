@@ -1,21 +1,23 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ********* HTTP Request
 
-var (
-	// IsHTTPLog flag print logs for http request and response.
-	IsHTTPLog = true
-)
-
 // HTTPRequest model for http request
 type HTTPRequest struct {
 	URL     string
@@ -25,107 +27,321 @@ type HTTPRequest struct {
 	Body    string
 }
 
-// HTTPGet sends http GET request, and returns response.
-func HTTPGet(params *HTTPRequest) (*http.Response, error) {
-	params.Method = "GET"
-	printHTTPRequest(params)
+// HTTPRequestHook is called before each attempt of request, with request body.
+type HTTPRequestHook func(req *http.Request, body string)
+
+// HTTPResponseHook is called after each attempt of request, with response body, and resp is nil if err.
+type HTTPResponseHook func(req *http.Request, resp *http.Response, body string, err error)
 
+// HTTPClientOptions options of http client.
+type HTTPClientOptions struct {
+	// Timeout of each attempt, no timeout if 0.
+	Timeout time.Duration
+	// MaxRetries retries after first attempt, for connection error or response status in RetryStatusCodes.
+	MaxRetries int
+	// RetryStatusCodes default are 429, 502, 503 and 504.
+	RetryStatusCodes []int
+	// RetryWaitMin and RetryWaitMax bounds of exponential backoff, default are 100ms and 5s.
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// Headers default headers of all requests.
+	Headers http.Header
+	// OnRequest and OnResponse hooks, like LogHTTPRequest and LogHTTPResponse.
+	OnRequest  HTTPRequestHook
+	OnResponse HTTPResponseHook
+	// Transport default is http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// HTTPClient a reusable http client with retries, and it's safe for concurrent use.
+type HTTPClient struct {
+	client *http.Client
+	opts   HTTPClientOptions
+}
+
+// HTTPStatusError response status is not 2xx.
+type HTTPStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http %s %s returns %d: %s", e.Method, e.URL, e.StatusCode, getBodyByLimited(e.Body))
+}
+
+// NewHTTPClient returns a http client by options, and default options are used if nil.
+func NewHTTPClient(opts *HTTPClientOptions) *HTTPClient {
+	c := &HTTPClient{}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if len(c.opts.RetryStatusCodes) == 0 {
+		c.opts.RetryStatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	if c.opts.RetryWaitMin <= 0 {
+		c.opts.RetryWaitMin = 100 * time.Millisecond
+	}
+	if c.opts.RetryWaitMax < c.opts.RetryWaitMin {
+		c.opts.RetryWaitMax = 5 * time.Second
+	}
+	c.client = &http.Client{Timeout: c.opts.Timeout, Transport: c.opts.Transport}
+	return c
+}
+
+// Do sends request with retries, and returns response which body should be closed by caller.
+func (c *HTTPClient) Do(ctx context.Context, params *HTTPRequest) (*http.Response, error) {
+	method := params.Method
+	if len(method) == 0 {
+		method = "GET"
+	}
 	u, err := url.Parse(params.URL)
 	if err != nil {
-		printErrorLine(err)
 		return nil, err
 	}
 	if len(params.Query) > 0 {
-		u.RawQuery = params.Query.Encode()
+		query := u.Query()
+		for k, v := range params.Query {
+			query[k] = v
+		}
+		u.RawQuery = query.Encode()
 	}
 
-	resp, err := http.Get(u.String())
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if len(params.Body) > 0 {
+			body = strings.NewReader(params.Body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c.opts.Headers {
+			req.Header[k] = v
+		}
+		for k, v := range params.Headers {
+			req.Header[k] = v
+		}
+
+		resp, err := c.send(req, params.Body)
+		if attempt >= c.opts.MaxRetries || !c.shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		wait := c.backoff(attempt, resp)
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send sends request once, and response body is read and replaced when response hook is set.
+func (c *HTTPClient) send(req *http.Request, body string) (*http.Response, error) {
+	if c.opts.OnRequest != nil {
+		c.opts.OnRequest(req, body)
+	}
+	resp, err := c.client.Do(req)
+	if c.opts.OnResponse == nil {
+		return resp, err
+	}
 	if err != nil {
-		printErrorLine(err)
+		c.opts.OnResponse(req, nil, "", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if err := printHTTPResponse(params.URL, resp); err != nil {
-		printErrorLine(err)
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	c.opts.OnResponse(req, resp, string(b), err)
+	if err != nil {
 		return nil, err
 	}
-
 	return resp, nil
 }
 
-// HTTPPost sends http Post request, and returns response.
-func HTTPPost(params *HTTPRequest) (*http.Response, error) {
-	params.Method = "POST"
-	printHTTPRequest(params)
-
-	req, err := http.NewRequest(params.Method, params.URL, strings.NewReader(params.Body))
+func (c *HTTPClient) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
 	if err != nil {
-		printErrorLine(err)
-		return nil, err
+		return true
+	}
+	for _, code := range c.opts.RetryStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
 	}
-	req.Header = params.Headers
+	return false
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		printErrorLine(err)
-		return nil, err
+// backoff returns wait before next attempt: exponential backoff with jitter in [wait/2, wait],
+// or Retry-After (in seconds) of response, and not greater than RetryWaitMax.
+func (c *HTTPClient) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			if wait := time.Duration(secs) * time.Second; wait < c.opts.RetryWaitMax {
+				return wait
+			}
+			return c.opts.RetryWaitMax
+		}
 	}
-	defer resp.Body.Close()
 
-	if err := printHTTPResponse(params.URL, resp); err != nil {
-		return nil, err
+	wait := c.opts.RetryWaitMax
+	if attempt < 30 {
+		if w := c.opts.RetryWaitMin << uint(attempt); w > 0 && w < wait {
+			wait = w
+		}
 	}
-	return resp, nil
+	half := int64(wait / 2)
+	return time.Duration(half + rand.Int63n(half+1))
 }
 
-func printHTTPRequest(params *HTTPRequest) {
-	url := params.URL
-	if len(params.Query) > 0 {
-		url = url + "?" + params.Query.Encode()
+// doMethod sends request by method, and method of params is not changed, so params can be reused.
+func (c *HTTPClient) doMethod(ctx context.Context, method string, params *HTTPRequest) (*http.Response, error) {
+	req := *params
+	req.Method = method
+	return c.Do(ctx, &req)
+}
+
+// Get sends http GET request.
+func (c *HTTPClient) Get(ctx context.Context, params *HTTPRequest) (*http.Response, error) {
+	return c.doMethod(ctx, "GET", params)
+}
+
+// Head sends http HEAD request.
+func (c *HTTPClient) Head(ctx context.Context, params *HTTPRequest) (*http.Response, error) {
+	return c.doMethod(ctx, "HEAD", params)
+}
+
+// Post sends http POST request.
+func (c *HTTPClient) Post(ctx context.Context, params *HTTPRequest) (*http.Response, error) {
+	return c.doMethod(ctx, "POST", params)
+}
+
+// Put sends http PUT request.
+func (c *HTTPClient) Put(ctx context.Context, params *HTTPRequest) (*http.Response, error) {
+	return c.doMethod(ctx, "PUT", params)
+}
+
+// Patch sends http PATCH request.
+func (c *HTTPClient) Patch(ctx context.Context, params *HTTPRequest) (*http.Response, error) {
+	return c.doMethod(ctx, "PATCH", params)
+}
+
+// Delete sends http DELETE request.
+func (c *HTTPClient) Delete(ctx context.Context, params *HTTPRequest) (*http.Response, error) {
+	return c.doMethod(ctx, "DELETE", params)
+}
+
+// DoJSON sends in (if not nil) as json body, and decodes json response into out (if not nil).
+// HTTPStatusError is returned if response status is not 2xx.
+func (c *HTTPClient) DoJSON(ctx context.Context, method, url string, in, out interface{}) error {
+	params := &HTTPRequest{URL: url, Method: method, Headers: make(http.Header)}
+	params.Headers.Set("Accept", "application/json")
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		params.Body = string(b)
+		params.Headers.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(ctx, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &HTTPStatusError{Method: method, URL: url, StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	if out == nil || len(b) == 0 {
+		return nil
 	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("decode json response of %s failed: %v", url, err)
+	}
+	return nil
+}
+
+// GetJSON sends GET request, and decodes json response into out.
+func (c *HTTPClient) GetJSON(ctx context.Context, url string, out interface{}) error {
+	return c.DoJSON(ctx, "GET", url, nil, out)
+}
+
+// PostJSON sends in as json body, and decodes json response into out.
+func (c *HTTPClient) PostJSON(ctx context.Context, url string, in, out interface{}) error {
+	return c.DoJSON(ctx, "POST", url, in, out)
+}
+
+// defaultHTTPClient client of HTTPGet and HTTPPost, and logs requests and responses.
+var defaultHTTPClient = NewHTTPClient(&HTTPClientOptions{
+	OnRequest:  LogHTTPRequest,
+	OnResponse: LogHTTPResponse,
+})
+
+// HTTPGet sends http GET request, and returns response which body should be closed by caller.
+func HTTPGet(params *HTTPRequest) (*http.Response, error) {
+	return defaultHTTPClient.Get(context.Background(), params)
+}
+
+// HTTPPost sends http Post request, and returns response which body should be closed by caller.
+func HTTPPost(params *HTTPRequest) (*http.Response, error) {
+	return defaultHTTPClient.Post(context.Background(), params)
+}
+
+// LogHTTPRequest request hook prints request.
+func LogHTTPRequest(req *http.Request, body string) {
 	printDivLine()
-	printlnPrefixLine(fmt.Sprintf("-Request: (%s) %s", params.Method, url))
+	printlnPrefixLine(fmt.Sprintf("-Request: (%s) %s", req.Method, req.URL))
 	printDivLine()
 
-	if len(params.Headers) > 0 {
+	if len(req.Header) > 0 {
 		printlnPrefixLine("-Headers:")
-		for k, v := range params.Headers {
+		for k, v := range req.Header {
 			printlnPrefixLine(fmt.Sprintf("%s: %s", k, strings.Join(v, ",")))
 		}
 	}
 
-	if len(params.Body) > 0 {
+	if len(body) > 0 {
 		printlnPrefixLine("-Body:")
-		printlnPrefixLine(getBodyByLimited(params.Body))
+		printlnPrefixLine(getBodyByLimited(body))
 	}
 }
 
-func printHTTPResponse(url string, resp *http.Response) error {
+// LogHTTPResponse response hook prints response or error.
+func LogHTTPResponse(req *http.Request, resp *http.Response, body string, err error) {
+	if err != nil {
+		printErrorLine(err)
+		return
+	}
 	printDivLine()
-	printlnPrefixLine(fmt.Sprintf("-Response: (%d) %s", resp.StatusCode, url))
+	printlnPrefixLine(fmt.Sprintf("-Response: (%d) %s", resp.StatusCode, req.URL))
 	printDivLine()
 
 	printlnPrefixLine("-Headers:")
 	for k, v := range resp.Header {
 		printlnPrefixLine(fmt.Sprintf("%s: %s", k, strings.Join(v, ";")))
 	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		printErrorLine(err)
-		return err
-	}
 	printlnPrefixLine("-Body:")
-	printlnPrefixLine(getBodyByLimited(string(body)))
-
-	return nil
+	printlnPrefixLine(getBodyByLimited(body))
 }
 
 func getBodyByLimited(body string) string {
 	const bodyLenLimit = 1024
-	limit := intMin(len(body), bodyLenLimit)
-	words := []rune(body)[:limit]
+	words := []rune(body)
+	words = words[:intMin(len(words), bodyLenLimit)]
 	lines := strings.Split(string(words), "\n")
 	return strings.Join(lines, "\n# ")
 }
@@ -138,9 +354,7 @@ func intMin(a, b int) int {
 }
 
 func printDivLine() {
-	if IsHTTPLog {
-		log.Println("#", strings.Repeat("*", 60))
-	}
+	log.Println("#", strings.Repeat("*", 60))
 }
 
 func printErrorLine(arg interface{}) {
@@ -148,7 +362,5 @@ func printErrorLine(arg interface{}) {
 }
 
 func printlnPrefixLine(arg interface{}) {
-	if IsHTTPLog {
-		log.Println("#", arg)
-	}
+	log.Println("#", arg)
 }
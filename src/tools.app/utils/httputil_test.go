@@ -1,10 +1,17 @@
 package utils_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	myutils "src/tools.app/utils"
 )
@@ -37,7 +44,6 @@ func TestHTTPGet(t *testing.T) {
 func TestHTTPPost(t *testing.T) {
 	const tURL = "http://127.0.0.1:17891/index"
 	t.Log(fmt.Sprintf("Case01: http post request test: '%s'", tURL))
-	// myutils.IsHTTPLog = false
 
 	headers := make(http.Header)
 	headers.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -57,3 +63,121 @@ func TestHTTPPost(t *testing.T) {
 		t.Errorf("Failed: returned code is not OK (%d)", resp.StatusCode)
 	}
 }
+
+func TestHTTPClientRetry(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		b, _ := ioutil.ReadAll(r.Body)
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Tag", r.Header.Get("X-Tag"))
+		fmt.Fprintf(w, "attempt %d: %s", n, b)
+	}))
+	defer ts.Close()
+
+	t.Log("Case01: retry on 503 with body replayed, and response body is readable.")
+	headers := make(http.Header)
+	headers.Set("X-Tag", "retry")
+	client := myutils.NewHTTPClient(&myutils.HTTPClientOptions{
+		MaxRetries:   3,
+		RetryWaitMin: 10 * time.Millisecond,
+		RetryWaitMax: 50 * time.Millisecond,
+		Headers:      headers,
+	})
+	resp, err := client.Post(context.Background(), &myutils.HTTPRequest{URL: ts.URL, Body: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(b) != "attempt 3: hello" || resp.Header.Get("X-Tag") != "retry" {
+		t.Errorf("Unexpected response: %d, %s, %v", resp.StatusCode, b, resp.Header)
+	}
+
+	t.Log("Case02: last response is returned when retries are exhausted.")
+	atomic.StoreInt32(&count, 0)
+	client = myutils.NewHTTPClient(&myutils.HTTPClientOptions{MaxRetries: 1, RetryWaitMin: time.Millisecond})
+	resp, err = client.Get(context.Background(), &myutils.HTTPRequest{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&count) != 2 {
+		t.Errorf("Unexpected status %d after %d attempts", resp.StatusCode, count)
+	}
+
+	t.Log("Case03: retry stops when context is canceled.")
+	atomic.StoreInt32(&count, -100)
+	client = myutils.NewHTTPClient(&myutils.HTTPClientOptions{MaxRetries: 100, RetryWaitMin: 50 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	if _, err = client.Get(ctx, &myutils.HTTPRequest{URL: ts.URL}); err != context.DeadlineExceeded {
+		t.Errorf("Want deadline exceeded, got: %v", err)
+	}
+}
+
+func TestHTTPClientJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/notfound" {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		var in map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&in)
+		json.NewEncoder(w).Encode(map[string]interface{}{"method": r.Method, "content_type": r.Header.Get("Content-Type"), "in": in})
+	}))
+	defer ts.Close()
+
+	var logs []string
+	client := myutils.NewHTTPClient(&myutils.HTTPClientOptions{
+		OnRequest: func(req *http.Request, body string) {
+			logs = append(logs, "request "+req.Method+" "+body)
+		},
+		OnResponse: func(req *http.Request, resp *http.Response, body string, err error) {
+			logs = append(logs, fmt.Sprintf("response %d", resp.StatusCode))
+		},
+	})
+
+	t.Log("Case01: post json, and decode json response.")
+	out := struct {
+		Method      string            `json:"method"`
+		ContentType string            `json:"content_type"`
+		In          map[string]string `json:"in"`
+	}{}
+	if err := client.PostJSON(context.Background(), ts.URL, map[string]string{"name": "test"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Method != "POST" || out.ContentType != "application/json" || out.In["name"] != "test" {
+		t.Errorf("Unexpected json response: %+v", out)
+	}
+	if len(logs) != 2 || logs[0] != `request POST {"name":"test"}` || logs[1] != "response 200" {
+		t.Errorf("Unexpected hook logs: %v", logs)
+	}
+
+	t.Log("Case02: status error.")
+	err := client.GetJSON(context.Background(), ts.URL+"/notfound", &out)
+	statusErr, ok := err.(*myutils.HTTPStatusError)
+	if !ok || statusErr.StatusCode != http.StatusNotFound || !strings.Contains(statusErr.Body, "not found") {
+		t.Errorf("Want status error, got: %v", err)
+	}
+
+	t.Log("Case03: request params are reused by methods, and not changed.")
+	params := &myutils.HTTPRequest{URL: ts.URL, Method: "GET"}
+	if _, err := client.Delete(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if params.Method != "GET" || out.Method != "GET" {
+		t.Errorf("Unexpected method of reused params: %s, sent: %s", params.Method, out.Method)
+	}
+}
@@ -2,13 +2,18 @@ package utils
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// ErrStopIteration stops line iteration of ForEachLine without error.
+var ErrStopIteration = errors.New("stop iteration")
+
 // GetCurPath returns current run abs path.
 func GetCurPath() string {
 	dir, _ := filepath.Split(os.Args[0])
@@ -137,11 +142,91 @@ func ReadFileLines(path string) ([]string, error) {
 	return lines, nil
 }
 
+// ForEachLine reads file line by line, and calls fn for each line without "\n" (or "\r\n").
+// Iteration stops when fn returns error, and nil is returned for ErrStopIteration.
+func ForEachLine(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ForEachLineReader(f, fn)
+}
+
+// ForEachLineReader reads r line by line like ForEachLine, and there is no limit of line length.
+func ForEachLineReader(r io.Reader, fn func(line string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if fnErr := fn(strings.TrimRight(line, "\r\n")); fnErr != nil {
+				if fnErr == ErrStopIteration {
+					return nil
+				}
+				return fnErr
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
 // ------------------------------
 // File Write
 // ------------------------------
 
-// WriteContentToFile writes content to file.
+// WriteFileAtomic writes data to a temp file in the same dir, syncs and renames it to path,
+// so path has either old or new content even if process crashes.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir, name := filepath.Split(path)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+name+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir syncs dir to persist rename, and error is ignored as dir cannot be synced on some platforms (windows).
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// WriteContentToFile writes content to file atomically.
 func WriteContentToFile(path, content string, isOverwrite bool) error {
 	exist, err := IsFileExist(path)
 	if err != nil {
@@ -151,13 +236,11 @@ func WriteContentToFile(path, content string, isOverwrite bool) error {
 		return fmt.Errorf("file (%s) is exist", path)
 	}
 
-	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
-		return err
-	}
-	return nil
+	return WriteFileAtomic(path, []byte(content), 0644)
 }
 
 // AppendContentToFile if file exist, appends content at the end, or writes content to a new file.
+// File is locked exclusively while writing, so content of concurrent writers are not interleaved.
 func AppendContentToFile(path, content string) (int, error) {
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -165,5 +248,9 @@ func AppendContentToFile(path, content string) (int, error) {
 	}
 	defer f.Close()
 
+	if err := lockFile(f, true, true); err != nil {
+		return 0, err
+	}
+	defer unlockFile(f)
 	return f.WriteString(content)
 }
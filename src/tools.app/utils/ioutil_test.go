@@ -1,8 +1,12 @@
 package utils_test
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	myutils "src/tools.app/utils"
@@ -126,3 +130,80 @@ func TestGetCurPath(t *testing.T) {
 	t.Log("Case01: test get current run abs path.")
 	t.Log("current path:", myutils.GetCurPath())
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fPath := filepath.Join(dir, "atomic.json")
+
+	t.Log("Case01: write and overwrite file atomically.")
+	for _, content := range []string{`{"v": 1}`, `{"v": 2}`} {
+		if err := myutils.WriteFileAtomic(fPath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		rText, err := myutils.ReadFileContent(fPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rText != content {
+			t.Fatalf("want %s, got %s", content, rText)
+		}
+	}
+	info, err := os.Stat(fPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected file mode: %v", info.Mode())
+	}
+
+	t.Log("Case02: no temp file is left after failed write.")
+	if err := myutils.WriteFileAtomic(filepath.Join(dir, "not_exist", "atomic.json"), []byte("x"), 0644); err == nil {
+		t.Fatal("want error for not exist dir")
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "atomic.json" {
+		t.Fatalf("unexpected files in dir: %d", len(files))
+	}
+}
+
+func TestForEachLine(t *testing.T) {
+	fPath := filepath.Join(t.TempDir(), "lines.txt")
+	if err := myutils.WriteContentToFile(fPath, "1|one\r\n2|two\n\n4|four", true); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Case01: iterate all lines, including empty and last line without newline.")
+	lines := make([]string, 0, 4)
+	err := myutils.ForEachLine(fPath, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, ",") != "1|one,2|two,,4|four" {
+		t.Fatalf("unexpected lines: %q", lines)
+	}
+
+	t.Log("Case02: stop iteration.")
+	count := 0
+	err = myutils.ForEachLine(fPath, func(line string) error {
+		count++
+		if count == 2 {
+			return myutils.ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Fatalf("want stop at 2nd line, got count=%d, err=%v", count, err)
+	}
+
+	t.Log("Case03: error of callback is returned.")
+	wantErr := errors.New("bad line")
+	if err := myutils.ForEachLine(fPath, func(line string) error { return wantErr }); err != wantErr {
+		t.Fatalf("want error %v, got %v", wantErr, err)
+	}
+}
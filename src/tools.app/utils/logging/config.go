@@ -0,0 +1,202 @@
+package logging
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigEnv env of logging config file, which is shared by all tools.
+const ConfigEnv = "LOG_CONFIG"
+
+/*
+Config logging configs, like:
+
+	{
+	  "level": "info",
+	  "format": "json",
+	  "output": "/tmp/logs/mock.log",
+	  "max_size_mb": 100,
+	  "max_backups": 7,
+	  "max_age_days": 30,
+	  "rotate": "daily",
+	  "fields": {"host": "mock-01"},
+	  "components": {"journal": "debug", "webhooks": "warn"}
+	}
+*/
+type Config struct {
+	// Level debug, info, warn or error, and default is info.
+	Level string `json:"level"`
+	// Format text or json, and default is text.
+	Format string `json:"format"`
+	// Output stdout, stderr or file path, and default is stderr.
+	Output string `json:"output"`
+	// MaxSizeMB, MaxBackups, MaxAgeDays and Rotate (hourly or daily) rotation and retention of output file.
+	MaxSizeMB  int    `json:"max_size_mb"`
+	MaxBackups int    `json:"max_backups"`
+	MaxAgeDays int    `json:"max_age_days"`
+	Rotate     string `json:"rotate"`
+	// Fields added to all entries.
+	Fields map[string]interface{} `json:"fields"`
+	// Components maps component name to its level.
+	Components map[string]string `json:"components"`
+}
+
+// LoadConfig reads logging configs from json file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("invalid logging config file (%s): %v", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) newState() (*state, error) {
+	st := &state{components: make(map[string]Level, len(cfg.Components))}
+	var err error
+	if st.level, err = ParseLevel(cfg.Level); err != nil {
+		return nil, err
+	}
+	for component, name := range cfg.Components {
+		if st.components[component], err = ParseLevel(name); err != nil {
+			return nil, err
+		}
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+	case "json":
+		st.json = true
+	default:
+		return nil, fmt.Errorf("invalid log format: %s", cfg.Format)
+	}
+
+	// fields are sorted by key for stable output
+	keys := make([]string, 0, len(cfg.Fields))
+	for key := range cfg.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		st.fields = append(st.fields, key, cfg.Fields[key])
+	}
+
+	if st.out, err = cfg.newOutput(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (cfg *Config) newOutput() (*output, error) {
+	switch cfg.Output {
+	case "", "stderr":
+		return &output{w: os.Stderr}, nil
+	case "stdout":
+		return &output{w: os.Stdout}, nil
+	}
+
+	opts := RotateOptions{
+		MaxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+	}
+	switch strings.ToLower(cfg.Rotate) {
+	case "":
+	case "hourly":
+		opts.Interval = time.Hour
+	case "daily":
+		opts.Interval = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("invalid log rotate: %s", cfg.Rotate)
+	}
+	w, err := NewRotateWriter(cfg.Output, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &output{w: w, closer: w}, nil
+}
+
+// root logger of Get, which writes text to stderr until Init.
+var root = newLogger(&state{out: &output{w: os.Stderr}, level: InfoLevel})
+
+// Init applies config to all loggers returned by Get, including those created before Init,
+// and previous output file is closed. Entries being written concurrently with Init go to the new output.
+func Init(cfg *Config) error {
+	st, err := cfg.newState()
+	if err != nil {
+		return err
+	}
+	prev := root.state()
+	root.holder.Store(st)
+	return prev.out.replace(st.out)
+}
+
+// Get returns a logger of component, which follows config of Init.
+func Get(component string) *Logger {
+	return root.Named(component)
+}
+
+// Close closes output file of loggers returned by Get.
+func Close() error {
+	return root.Close()
+}
+
+// SetOutput sets output of loggers returned by Get, mainly for tests.
+func SetOutput(w io.Writer) {
+	st := *root.state()
+	st.out = &output{w: w}
+	root.holder.Store(&st)
+}
+
+// Flags logging command line flags shared by all tools.
+type Flags struct {
+	Config string
+	Level  string
+	Format string
+	Output string
+}
+
+// RegisterFlags registers -log-config, -log-level, -log-format and -log-output flags.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.Config, "log-config", os.Getenv(ConfigEnv), "logging config file (json), default is env "+ConfigEnv+".")
+	fs.StringVar(&f.Level, "log-level", "", "log level: debug, info, warn or error.")
+	fs.StringVar(&f.Format, "log-format", "", "log format: text or json.")
+	fs.StringVar(&f.Output, "log-output", "", "log output: stdout, stderr or file path.")
+	return f
+}
+
+// Init inits loggers by config file of flags, or base config (may be nil) if no config file,
+// and the other flags override configs.
+func (f *Flags) Init(base *Config) error {
+	cfg := &Config{}
+	if len(f.Config) > 0 {
+		var err error
+		if cfg, err = LoadConfig(f.Config); err != nil {
+			return err
+		}
+	} else if base != nil {
+		*cfg = *base
+	}
+
+	if len(f.Level) > 0 {
+		cfg.Level = f.Level
+	}
+	if len(f.Format) > 0 {
+		cfg.Format = f.Format
+	}
+	if len(f.Output) > 0 {
+		cfg.Output = f.Output
+	}
+	return Init(cfg)
+}
@@ -0,0 +1,33 @@
+package logging
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitReplaceOutput(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.log"), filepath.Join(dir, "new.log")
+	if err := Init(&Config{Output: oldPath}); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(&Config{})
+
+	t.Log("Case01: entry of state loaded before Init is written to new output.")
+	prev := root.state()
+	if err := Init(&Config{Output: newPath}); err != nil {
+		t.Fatal(err)
+	}
+	prev.out.write([]byte("late entry\n"))
+	b, err := ioutil.ReadFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "late entry\n" {
+		t.Fatalf("unexpected new output: %q", string(b))
+	}
+	if b, _ := ioutil.ReadFile(oldPath); len(b) > 0 {
+		t.Fatalf("unexpected old output: %q", string(b))
+	}
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level log level.
+type Level int32
+
+const (
+	// DebugLevel verbose logs for debugging.
+	DebugLevel Level = iota
+	// InfoLevel default level.
+	InfoLevel
+	// WarnLevel unexpected but recoverable errors.
+	WarnLevel
+	// ErrorLevel errors which need attention.
+	ErrorLevel
+	// FatalLevel process exits after the log is written.
+	FatalLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+	FatalLevel: "fatal",
+}
+
+// ParseLevel returns level by name (case insensitive), and info level if name is empty.
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return DebugLevel, nil
+	case "", "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	case "fatal":
+		return FatalLevel, nil
+	default:
+		return InfoLevel, fmt.Errorf("invalid log level: %s", name)
+	}
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
@@ -0,0 +1,357 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const timeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// pkgPrefix function name prefix of this package, and frames of which are skipped for caller.
+var pkgPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	// like "src/tools.app/utils/logging.init"
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+strings.Index(name[slash:], ".")+1]
+}()
+
+// state output, format, levels and fields shared by loggers from the same config.
+type state struct {
+	out        *output
+	json       bool
+	level      Level
+	components map[string]Level
+	fields     []interface{}
+}
+
+func (st *state) levelOf(component string) Level {
+	if level, ok := st.components[component]; ok {
+		return level
+	}
+	return st.level
+}
+
+// output serializes writes of entries.
+type output struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	// next output which entries are redirected to after output is replaced.
+	next *output
+}
+
+func (o *output) write(b []byte) {
+	o.mutex.Lock()
+	if next := o.next; next != nil {
+		o.mutex.Unlock()
+		next.write(b)
+		return
+	}
+	defer o.mutex.Unlock()
+	o.w.Write(b)
+}
+
+// replace closes output, and entries written after it (by loggers which loaded state before it's replaced)
+// are redirected to next output instead of lost.
+func (o *output) replace(next *output) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.next = next
+	if o.closer == nil {
+		return nil
+	}
+	return o.closer.Close()
+}
+
+func (o *output) close() error {
+	if o.closer == nil {
+		return nil
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.closer.Close()
+}
+
+// Logger a leveled logger of a component with fields, and it's safe for concurrent use.
+// Fields are key and value pairs, like logger.Info("request done", "path", path, "status", 200).
+type Logger struct {
+	holder    *atomic.Value
+	component string
+	fields    []interface{}
+}
+
+func newLogger(st *state) *Logger {
+	holder := &atomic.Value{}
+	holder.Store(st)
+	return &Logger{holder: holder}
+}
+
+// New returns a standalone logger by config, which should be closed if output is a file.
+func New(cfg *Config) (*Logger, error) {
+	st, err := cfg.newState()
+	if err != nil {
+		return nil, err
+	}
+	return newLogger(st), nil
+}
+
+// Close closes output file of logger.
+func (l *Logger) Close() error {
+	return l.state().out.close()
+}
+
+func (l *Logger) state() *state {
+	return l.holder.Load().(*state)
+}
+
+// Named returns a logger of component, which level can be set by components of config.
+func (l *Logger) Named(component string) *Logger {
+	return &Logger{holder: l.holder, component: component, fields: l.fields}
+}
+
+// With returns a logger with fields added to all entries.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(append(fields, l.fields...), kv...)
+	return &Logger{holder: l.holder, component: l.component, fields: fields}
+}
+
+// Enabled returns whether entries of level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.state().levelOf(l.component)
+}
+
+// Debug writes a debug entry with fields.
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(DebugLevel, msg, kv)
+}
+
+// Info writes an info entry with fields.
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(InfoLevel, msg, kv)
+}
+
+// Warn writes a warn entry with fields.
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(WarnLevel, msg, kv)
+}
+
+// Error writes an error entry with fields.
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(ErrorLevel, msg, kv)
+}
+
+// Fatal writes a fatal entry with fields, and then exits with code 1.
+func (l *Logger) Fatal(msg string, kv ...interface{}) {
+	l.log(FatalLevel, msg, kv)
+	os.Exit(1)
+}
+
+// Debugf writes a formatted debug entry.
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	if l.Enabled(DebugLevel) {
+		l.log(DebugLevel, fmt.Sprintf(format, args...), nil)
+	}
+}
+
+// Infof writes a formatted info entry.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(InfoLevel, fmt.Sprintf(format, args...), nil)
+}
+
+// Warnf writes a formatted warn entry.
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(WarnLevel, fmt.Sprintf(format, args...), nil)
+}
+
+// Errorf writes a formatted error entry.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(ErrorLevel, fmt.Sprintf(format, args...), nil)
+}
+
+// Fatalf writes a formatted fatal entry, and then exits with code 1.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.log(FatalLevel, fmt.Sprintf(format, args...), nil)
+	os.Exit(1)
+}
+
+// StdLogger returns a standard logger writes to logger at level, like ErrorLog of http.Server.
+func (l *Logger) StdLogger(level Level) *log.Logger {
+	return log.New(&stdWriter{logger: l, level: level}, "", 0)
+}
+
+// RedirectStdLog writes logs of standard "log" package to logger at info level.
+func RedirectStdLog(l *Logger) {
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(&stdWriter{logger: l, level: InfoLevel})
+}
+
+type stdWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (w *stdWriter) Write(p []byte) (int, error) {
+	w.logger.log(w.level, strings.TrimRight(string(p), "\r\n"), nil)
+	return len(p), nil
+}
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	st := l.state()
+	if level < st.levelOf(l.component) && level != FatalLevel {
+		return
+	}
+
+	fields := make([]interface{}, 0, len(st.fields)+len(l.fields)+len(kv))
+	fields = append(append(append(fields, st.fields...), l.fields...), kv...)
+	e := &entry{
+		time:      time.Now(),
+		level:     level,
+		component: l.component,
+		caller:    findCaller(),
+		msg:       msg,
+		fields:    fields,
+	}
+	var buf bytes.Buffer
+	if st.json {
+		e.encodeJSON(&buf)
+	} else {
+		e.encodeText(&buf)
+	}
+	st.out.write(buf.Bytes())
+}
+
+// findCaller returns "file:line" of the first frame outside this package and standard log package.
+func findCaller() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) && !strings.HasPrefix(frame.Function, "log.") {
+			return filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// entry a log entry to be encoded.
+type entry struct {
+	time      time.Time
+	level     Level
+	component string
+	caller    string
+	msg       string
+	fields    []interface{}
+}
+
+// eachField calls fn for each key and value pair, and value of the last odd key is "(MISSING)".
+func (e *entry) eachField(fn func(key string, value interface{})) {
+	for i := 0; i < len(e.fields); i += 2 {
+		key, ok := e.fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(e.fields[i])
+		}
+		var value interface{} = "(MISSING)"
+		if i+1 < len(e.fields) {
+			value = e.fields[i+1]
+		}
+		fn(key, value)
+	}
+}
+
+// encodeText writes entry like:
+// 2020-01-02T15:04:05.000+08:00 INFO [component] main.go:12 message key=value key2="a value"
+func (e *entry) encodeText(buf *bytes.Buffer) {
+	buf.WriteString(e.time.Format(timeLayout))
+	buf.WriteByte(' ')
+	buf.WriteString(strings.ToUpper(e.level.String()))
+	if len(e.component) > 0 {
+		buf.WriteString(" [" + e.component + "]")
+	}
+	if len(e.caller) > 0 {
+		buf.WriteString(" " + e.caller)
+	}
+	buf.WriteString(" " + e.msg)
+	e.eachField(func(key string, value interface{}) {
+		buf.WriteString(" " + key + "=" + quoteText(formatValue(value)))
+	})
+	buf.WriteByte('\n')
+}
+
+// encodeJSON writes entry as a json line, and fields follow time, level, component, caller and msg.
+func (e *entry) encodeJSON(buf *bytes.Buffer) {
+	writeKV := func(key string, value interface{}) {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		switch v := value.(type) {
+		case error:
+			value = v.Error()
+		case fmt.Stringer:
+			value = v.String()
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			b, _ = json.Marshal(fmt.Sprintf("%+v", value))
+		}
+		buf.Write(b)
+	}
+
+	buf.WriteByte('{')
+	writeKV("time", e.time.Format(timeLayout))
+	writeKV("level", e.level.String())
+	if len(e.component) > 0 {
+		writeKV("component", e.component)
+	}
+	if len(e.caller) > 0 {
+		writeKV("caller", e.caller)
+	}
+	writeKV("msg", e.msg)
+	e.eachField(writeKV)
+	buf.WriteString("}\n")
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+// quoteText quotes text if it's empty or contains spaces, quotes, "=" or control chars.
+func quoteText(text string) string {
+	if len(text) == 0 {
+		return `""`
+	}
+	for _, r := range text {
+		if r <= ' ' || r == '"' || r == '=' || r == 0x7f {
+			return strconv.Quote(text)
+		}
+	}
+	return text
+}
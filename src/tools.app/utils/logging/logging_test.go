@@ -0,0 +1,207 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"src/tools.app/utils/logging"
+)
+
+func newTestLogger(t *testing.T, cfg *logging.Config) (*logging.Logger, string) {
+	cfg.Output = filepath.Join(t.TempDir(), "test.log")
+	logger, err := logging.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		logger.Close()
+	})
+	return logger, cfg.Output
+}
+
+func readLines(t *testing.T, path string) []string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+}
+
+func TestLoggerText(t *testing.T) {
+	logger, path := newTestLogger(t, &logging.Config{
+		Level:      "info",
+		Fields:     map[string]interface{}{"host": "test-01"},
+		Components: map[string]string{"verbose": "debug", "quiet": "error"},
+	})
+
+	t.Log("Case01: entries below level are dropped.")
+	logger.Debug("debug msg")
+	logger.Info("info msg", "path", "/ping", "status", 200, "err", errors.New("not found"), "odd")
+	logger.Named("verbose").Debugf("debug %d", 1)
+	logger.Named("quiet").Warn("warn msg")
+	logger.Named("quiet").With("id", "a b").Error("error msg")
+
+	lines := readLines(t, path)
+	if len(lines) != 3 {
+		t.Fatalf("want 3 lines, got:\n%s", strings.Join(lines, "\n"))
+	}
+
+	t.Log("Case02: text entry with level, caller and fields.")
+	want := `INFO logging_test.go:48 info msg host=test-01 path=/ping status=200 err="not found" odd=(MISSING)`
+	if !strings.HasSuffix(lines[0], want) {
+		t.Fatalf("unexpected entry: %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "DEBUG [verbose] logging_test.go:49 debug 1 host=test-01") {
+		t.Fatalf("unexpected entry: %s", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], `ERROR [quiet] logging_test.go:51 error msg host=test-01 id="a b"`) {
+		t.Fatalf("unexpected entry: %s", lines[2])
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	logger, path := newTestLogger(t, &logging.Config{Format: "json"})
+
+	t.Log("Case01: json entry with fields.")
+	logger.Named("mock").With("uri", "test").Warn("slow request", "cost", 1.5, "tags", []string{"a", "b"})
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(readLines(t, path)[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"level": "warn", "component": "mock", "msg": "slow request", "uri": "test", "cost": 1.5,
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Fatalf("want %s=%v, got %v", key, value, entry[key])
+		}
+	}
+	if tags := fmt.Sprint(entry["tags"]); tags != "[a b]" {
+		t.Fatalf("unexpected tags: %s", tags)
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Fatal("time is not set")
+	}
+
+	t.Log("Case02: standard logger writes to logger.")
+	logger.StdLogger(logging.ErrorLevel).Println("std error")
+	if err := json.Unmarshal([]byte(readLines(t, path)[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "error" || entry["msg"] != "std error" || !strings.HasPrefix(entry["caller"].(string), "logging_test.go:") {
+		t.Fatalf("unexpected entry: %v", entry)
+	}
+}
+
+func TestGlobalLogger(t *testing.T) {
+	t.Log("Case01: loggers got before Init follow config of Init.")
+	logger := logging.Get("component")
+	path := filepath.Join(t.TempDir(), "global.log")
+	if err := logging.Init(&logging.Config{Output: path, Components: map[string]string{"component": "warn"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.Init(&logging.Config{})
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+	lines := readLines(t, path)
+	if len(lines) != 1 || !strings.Contains(lines[0], "WARN [component]") {
+		t.Fatalf("unexpected entries: %v", lines)
+	}
+
+	t.Log("Case02: standard log package is redirected.")
+	var buf bytes.Buffer
+	logging.SetOutput(&buf)
+	flags, prefix, w := log.Flags(), log.Prefix(), log.Writer()
+	defer func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(w)
+	}()
+	logging.RedirectStdLog(logging.Get("std"))
+	log.Printf("hello %s\n", "world")
+	if !strings.HasSuffix(buf.String(), "INFO [std] logging_test.go:131 hello world\n") {
+		t.Fatalf("unexpected entry: %s", buf.String())
+	}
+
+	t.Log("Case03: flags override config file.")
+	cfgPath := filepath.Join(t.TempDir(), "log.json")
+	if err := ioutil.WriteFile(cfgPath, []byte(`{"level": "error", "format": "json", "output": "stdout"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := &logging.Flags{Config: cfgPath, Output: path}
+	if err := f.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("dropped")
+	logger.Error("kept")
+	lines = readLines(t, path)
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "{") {
+		t.Fatalf("unexpected entries: %v", lines)
+	}
+
+	t.Log("Case04: invalid configs.")
+	for _, cfg := range []*logging.Config{{Level: "trace"}, {Format: "xml"}, {Output: path, Rotate: "weekly"}} {
+		if err := logging.Init(cfg); err == nil {
+			t.Fatalf("want error for config: %+v", cfg)
+		}
+	}
+}
+
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := logging.NewRotateWriter(path, logging.RotateOptions{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	t.Log("Case01: file is rotated before it exceeds max size, and backups over max are removed.")
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte(fmt.Sprintf("line%02d\n", i))); err != nil {
+			t.Fatal(err)
+		}
+		// backups are named by time in milliseconds
+		time.Sleep(2 * time.Millisecond)
+	}
+	if lines := readLines(t, path); len(lines) != 1 || lines[0] != "line04" {
+		t.Fatalf("unexpected current file: %v", lines)
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("want 2 backups, got: %v", backups)
+	}
+	if lines := readLines(t, backups[1]); lines[0] != "line03" {
+		t.Fatalf("unexpected latest backup: %v", lines)
+	}
+
+	t.Log("Case02: backups older than max age are removed.")
+	old := filepath.Join(dir, "app-20000102T150405.000.log")
+	if err := ioutil.WriteFile(old, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w2, err := logging.NewRotateWriter(path, logging.RotateOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.Close()
+	if err := w2.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expired backup is not removed: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeLayout = "20060102T150405.000"
+
+// RotateOptions rotation and retention of log file.
+type RotateOptions struct {
+	// MaxSize file is rotated before it exceeds max bytes, no size rotation if 0.
+	MaxSize int64
+	// Interval file is rotated at boundaries of interval (aligned to UTC), like 24h for daily, no time rotation if 0.
+	Interval time.Duration
+	// MaxBackups max rotated files are kept, no limit if 0.
+	MaxBackups int
+	// MaxAge rotated files older than it are removed, no limit if 0.
+	MaxAge time.Duration
+}
+
+// RotateWriter a log file writer rotates by size and time, and rotated files are named
+// like "app-20200102T150405.000.log" in the same dir. It's safe for concurrent use.
+type RotateWriter struct {
+	path  string
+	opts  RotateOptions
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+	// rotateAt next time to rotate by interval.
+	rotateAt time.Time
+	now      func() time.Time
+}
+
+// NewRotateWriter opens (appends) log file of path, and creates parent dirs if not exist.
+func NewRotateWriter(path string, opts RotateOptions) (*RotateWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	w := &RotateWriter{path: path, opts: opts, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes p to log file, and rotates it first if size or interval is exceeded.
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	now := w.now()
+	if (w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize) ||
+		(w.opts.Interval > 0 && !now.Before(w.rotateAt)) {
+		// keep writing to original file if rotate failed, and it's retried on next write
+		if err := w.rotate(now); err != nil && w.file == nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates log file now.
+func (w *RotateWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.rotate(w.now())
+}
+
+// Close closes log file.
+func (w *RotateWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotateWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	if w.opts.Interval > 0 {
+		w.rotateAt = w.now().Truncate(w.opts.Interval).Add(w.opts.Interval)
+	}
+	return nil
+}
+
+// rotate renames current file to backup, opens a new file, and then removes expired backups.
+// If it fails to rename or open, file of path is reopened to append, and rotation is retried on next write.
+func (w *RotateWriter) rotate(now time.Time) error {
+	err := w.file.Close()
+	w.file = nil
+	if err == nil {
+		ext := filepath.Ext(w.path)
+		backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(w.path, ext), now.Local().Format(backupTimeLayout), ext)
+		if err = os.Rename(w.path, backup); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = w.open()
+	}
+	if err != nil {
+		if openErr := w.open(); openErr != nil {
+			return fmt.Errorf("rotate failed: %v, and reopen failed: %v", err, openErr)
+		}
+		w.rotateAt = now
+		return fmt.Errorf("rotate failed: %v", err)
+	}
+	return w.removeBackups(now)
+}
+
+// removeBackups removes backups over MaxBackups or older than MaxAge.
+func (w *RotateWriter) removeBackups(now time.Time) error {
+	if w.opts.MaxBackups <= 0 && w.opts.MaxAge <= 0 {
+		return nil
+	}
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	// sorted by time desc
+	for i, backup := range backups {
+		if (w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups) ||
+			(w.opts.MaxAge > 0 && now.Sub(backup.time) > w.opts.MaxAge) {
+			if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type backupFile struct {
+	path string
+	time time.Time
+}
+
+// backups returns rotated files of log file sorted by time desc.
+func (w *RotateWriter) backups() ([]backupFile, error) {
+	ext := filepath.Ext(w.path)
+	prefix := strings.TrimSuffix(filepath.Base(w.path), ext) + "-"
+	entries, err := ioutil.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]backupFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeLayout, strings.TrimSuffix(name[len(prefix):], ext), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(filepath.Dir(w.path), name), time: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotateWriterRetry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	now := time.Date(2020, 1, 2, 15, 4, 5, 0, time.Local)
+	w, err := NewRotateWriter(path, RotateOptions{MaxSize: 10, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.now = func() time.Time { return now }
+
+	t.Log("Case01: original file is reopened and written if rename failed.")
+	// rename fails as backup is a not empty dir
+	backup := filepath.Join(dir, "app-20200102T150405.000.log")
+	if err := os.MkdirAll(filepath.Join(backup, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"line01\n", "line02\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Rotate(); err == nil {
+		t.Fatal("want rotate error")
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "line01\nline02\n" {
+		t.Fatalf("unexpected file: %q, %v", b, err)
+	}
+
+	t.Log("Case02: rotation is retried on next write.")
+	if err := os.RemoveAll(backup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("line03\n")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "line03\n" {
+		t.Fatalf("unexpected file: %q, %v", b, err)
+	}
+	if b, err := ioutil.ReadFile(backup); err != nil || string(b) != "line01\nline02\n" {
+		t.Fatalf("unexpected backup: %q, %v", b, err)
+	}
+}
@@ -3,12 +3,336 @@ package utils
 // dependency:
 // git submodule add "https://github.com/go-gomail/gomail.git" src/tools.app/vendor/gopkg.in/gomail.v2
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/smtp"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	gomail "gopkg.in/gomail.v2"
 )
 
+const (
+	// MailTLSNone plain smtp connection without tls.
+	MailTLSNone = "none"
+	// MailTLSStartTLS upgrades connection by STARTTLS, and fails if server does not support it.
+	MailTLSStartTLS = "starttls"
+	// MailTLSImplicit connects by tls (smtps, port 465).
+	MailTLSImplicit = "tls"
+)
+
+// MailerConfigFile config file of mailer used by SendMail.
+var MailerConfigFile = filepath.Join(GetCurPath(), "mailer.json")
+
+// MailerConfig smtp configs of mailer.
+type MailerConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// TLS none, starttls or tls. When empty, tls is used for port 465,
+	// and STARTTLS is used if server supports it for others.
+	TLS                string `json:"tls"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	// User and Password for smtp auth, and no auth if user is empty.
+	User     string `json:"user"`
+	Password string `json:"password"`
+	// From sender address like "Test <test@example.com>", default is user.
+	From string `json:"from"`
+	// TemplateDir dir of named templates: <name>.subject.tmpl, <name>.txt.tmpl and <name>.html.tmpl.
+	TemplateDir string `json:"template_dir"`
+	// Timeout of smtp connection in seconds, default is 10.
+	Timeout int `json:"timeout"`
+}
+
+// Mail a mail which subject and bodies are go templates rendered with data.
+type Mail struct {
+	To  []string
+	Cc  []string
+	Bcc []string
+	// Template name of templates in template dir, used when subject and bodies are all empty.
+	Template string
+	Subject  string
+	// Text (text/template) and HTML (html/template) bodies, and sent as alternatives if both are set.
+	Text string
+	HTML string
+	// RawHTML html body sent as it is instead of a template, and it replaces HTML if set.
+	RawHTML string
+	Data    interface{}
+	// InlineImages image files embedded in mail, and referenced in html by "cid:<file base name>".
+	InlineImages []string
+	Attachments  []string
+}
+
+// Mailer sends mails by smtp.
+type Mailer struct {
+	cfg MailerConfig
+}
+
+// LoadMailerConfig reads mailer configs from json file.
+func LoadMailerConfig(path string) (*MailerConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &MailerConfig{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("invalid mailer config file (%s): %v", path, err)
+	}
+	return cfg, nil
+}
+
+// NewMailerFromFile returns a mailer by json config file.
+func NewMailerFromFile(path string) (*Mailer, error) {
+	cfg, err := LoadMailerConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMailer(cfg)
+}
+
+// NewMailer returns a mailer by configs.
+func NewMailer(cfg *MailerConfig) (*Mailer, error) {
+	if len(cfg.Host) == 0 {
+		return nil, fmt.Errorf("smtp host is empty")
+	}
+	m := &Mailer{cfg: *cfg}
+	if m.cfg.Port == 0 {
+		m.cfg.Port = 25
+	}
+	if len(m.cfg.TLS) == 0 && m.cfg.Port == 465 {
+		m.cfg.TLS = MailTLSImplicit
+	}
+	switch m.cfg.TLS {
+	case "", MailTLSNone, MailTLSStartTLS, MailTLSImplicit:
+	default:
+		return nil, fmt.Errorf("invalid smtp tls mode: %s", m.cfg.TLS)
+	}
+	if len(m.cfg.From) == 0 {
+		m.cfg.From = m.cfg.User
+	}
+	if _, err := mail.ParseAddress(m.cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid mail sender (%s): %v", m.cfg.From, err)
+	}
+	if m.cfg.Timeout <= 0 {
+		m.cfg.Timeout = 10
+	}
+	return m, nil
+}
+
+// Send renders and sends a mail.
+func (m *Mailer) Send(ml *Mail) error {
+	rcpts := make([]string, 0, len(ml.To)+len(ml.Cc)+len(ml.Bcc))
+	for _, addrs := range [][]string{ml.To, ml.Cc, ml.Bcc} {
+		for _, addr := range addrs {
+			a, err := mail.ParseAddress(addr)
+			if err != nil {
+				return fmt.Errorf("invalid mail receiver (%s): %v", addr, err)
+			}
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+	if len(rcpts) == 0 {
+		return fmt.Errorf("mail receivers are empty")
+	}
+
+	msg, err := m.render(ml)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(m.cfg.From)
+	return m.sendSMTP(from.Address, rcpts, buf.Bytes())
+}
+
+// render returns message with rendered subject and bodies, inline images and attachments.
+func (m *Mailer) render(ml *Mail) (*gomail.Message, error) {
+	subject, text, html := ml.Subject, ml.Text, ml.HTML
+	if len(ml.Template) > 0 && len(subject) == 0 && len(text) == 0 && len(html) == 0 && len(ml.RawHTML) == 0 {
+		var err error
+		if subject, text, html, err = m.loadTemplates(ml.Template); err != nil {
+			return nil, err
+		}
+	}
+	if len(text) == 0 && len(html) == 0 && len(ml.RawHTML) == 0 {
+		return nil, fmt.Errorf("mail body is empty")
+	}
+
+	var err error
+	if subject, err = renderTextTemplate("subject", subject, ml.Data); err != nil {
+		return nil, err
+	}
+	if text, err = renderTextTemplate("text", text, ml.Data); err != nil {
+		return nil, err
+	}
+	if len(html) > 0 {
+		tmpl, err := htmltemplate.New("html").Parse(html)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, ml.Data); err != nil {
+			return nil, err
+		}
+		html = buf.String()
+	}
+	if len(ml.RawHTML) > 0 {
+		html = ml.RawHTML
+	}
+
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.cfg.From)
+	if len(ml.To) > 0 {
+		msg.SetHeader("To", ml.To...)
+	}
+	if len(ml.Cc) > 0 {
+		msg.SetHeader("Cc", ml.Cc...)
+	}
+	msg.SetHeader("Subject", subject)
+	msg.SetDateHeader("Date", time.Now())
+	switch {
+	case len(text) > 0 && len(html) > 0:
+		msg.SetBody("text/plain", text)
+		msg.AddAlternative("text/html", html)
+	case len(html) > 0:
+		msg.SetBody("text/html", html)
+	default:
+		msg.SetBody("text/plain", text)
+	}
+
+	for _, f := range ml.InlineImages {
+		if _, err := os.Stat(f); err != nil {
+			return nil, err
+		}
+		msg.Embed(f)
+	}
+	for _, f := range ml.Attachments {
+		if _, err := os.Stat(f); err != nil {
+			return nil, err
+		}
+		msg.Attach(f)
+	}
+	return msg, nil
+}
+
+// loadTemplates reads subject, text and html templates by name from template dir, and text or html may be absent.
+func (m *Mailer) loadTemplates(name string) (subject, text, html string, err error) {
+	if len(m.cfg.TemplateDir) == 0 {
+		return "", "", "", fmt.Errorf("mail template dir is not set")
+	}
+	read := func(ext string) (string, error) {
+		b, err := ioutil.ReadFile(filepath.Join(m.cfg.TemplateDir, name+ext))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	if subject, err = read(".subject.tmpl"); err != nil {
+		return
+	}
+	if text, err = read(".txt.tmpl"); err != nil {
+		return
+	}
+	if html, err = read(".html.tmpl"); err != nil {
+		return
+	}
+	if len(text) == 0 && len(html) == 0 {
+		err = fmt.Errorf("mail template not found: %s", name)
+	}
+	return
+}
+
+func renderTextTemplate(name, text string, data interface{}) (string, error) {
+	if len(text) == 0 {
+		return "", nil
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sendSMTP connects smtp server by tls mode, and sends a raw message.
+func (m *Mailer) sendSMTP(from string, rcpts []string, msg []byte) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	timeout := time.Duration(m.cfg.Timeout) * time.Second
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host, InsecureSkipVerify: m.cfg.InsecureSkipVerify}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if m.cfg.TLS == MailTLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if m.cfg.TLS != MailTLSImplicit && m.cfg.TLS != MailTLSNone {
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && m.cfg.TLS == MailTLSStartTLS {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", addr)
+		}
+		if ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if len(m.cfg.User) > 0 {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server %s does not support AUTH", addr)
+		}
+		// plain auth is refused on unencrypted connection except localhost
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.User, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
 // MailEntry entry for send an mail.
 type MailEntry struct {
 	Meta        string   `json:"meta"`
@@ -20,50 +344,47 @@ type MailEntry struct {
 	IsArchive   bool     `json:"archive"`
 }
 
-// SendMail sends a mail by smtp.
+// SendMail sends a html mail by mailer of MailerConfigFile, and ServerPwd is used if password is not set in config.
 func SendMail(entry *MailEntry) error {
-	const (
-		connUser = "zivieira@163.com"
-		connHost = "smtp.163.com"
-		connPort = 25
-	)
-
-	m := gomail.NewMessage()
-	m.SetHeader("From", fmt.Sprintf("ZJ Test<%s>", connUser)) // add a tag
-	m.SetHeader("To", entry.MailTo...)
-	m.SetHeader("Subject", entry.Subject)
-	m.SetBody("text/html", entry.Body)
+	cfg, err := LoadMailerConfig(MailerConfigFile)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Password) == 0 {
+		cfg.Password = entry.ServerPwd
+	}
+	mailer, err := NewMailer(cfg)
+	if err != nil {
+		return err
+	}
 
+	// subject and body are sent as they are, instead of templates
+	ml := &Mail{
+		To:          entry.MailTo,
+		Subject:     escapeTemplateText(entry.Subject),
+		RawHTML:     entry.Body,
+		Attachments: entry.AttachFiles,
+	}
 	if entry.IsArchive {
 		f, err := createArchiveFile(entry.AttachFiles)
 		if err != nil {
 			return err
 		}
-		m.Attach(f)
-	} else {
-		for _, f := range entry.AttachFiles {
-			m.Attach(f)
-		}
+		defer os.Remove(f)
+		ml.Attachments = []string{f}
 	}
+	return mailer.Send(ml)
+}
 
-	d := gomail.NewDialer(connHost, connPort, connUser, entry.ServerPwd)
-	return d.DialAndSend(m)
+// escapeTemplateText returns a template which outputs text as it is.
+func escapeTemplateText(text string) string {
+	return strings.Replace(text, "{{", `{{"{{"}}`, -1)
 }
 
 func createArchiveFile(paths []string) (string, error) {
-	var files []*os.File
-	for _, path := range paths {
-		f, err := os.Open(path)
-		if err != nil {
-			return "", err
-		}
-		files = append(files, f)
-	}
-
 	outputArchive := fmt.Sprintf("/tmp/archive_%s.tar.gz", GetCurrentDateTime())
-	err := CompressGzipFile(files, outputArchive)
-	if err != nil {
+	if err := CreateArchiveFile(outputArchive, paths, nil); err != nil {
 		return "", err
 	}
-	return outputArchive, err
+	return outputArchive, nil
 }
@@ -1,35 +1,340 @@
 package utils_test
 
 import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	myutils "src/tools.app/utils"
 )
 
+// mailPart a leaf part of received mail, and body is decoded.
+type mailPart struct {
+	contentType string
+	header      map[string][]string
+	body        string
+}
+
+func startSMTPSink(t *testing.T) *myutils.SMTPSink {
+	sink, err := myutils.NewSMTPSink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sink.Close()
+	})
+	return sink
+}
+
+func newSinkMailerConfig(sink *myutils.SMTPSink) *myutils.MailerConfig {
+	return &myutils.MailerConfig{
+		Host:     sink.Host(),
+		Port:     sink.Port(),
+		TLS:      myutils.MailTLSNone,
+		User:     "tester@example.com",
+		Password: "test",
+		From:     "Mail Test <tester@example.com>",
+		Timeout:  3,
+	}
+}
+
+// parseSinkMail returns headers and leaf parts of a received mail.
+func parseSinkMail(t *testing.T, m *myutils.SinkMail) (mail.Header, []mailPart) {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []mailPart
+	var walk func(contentType string, header map[string][]string, body []byte)
+	walk = func(contentType string, header map[string][]string, body []byte) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			// quoted-printable body of multipart is decoded by reader already
+			switch strings.ToLower(firstHeader(header, "Content-Transfer-Encoding")) {
+			case "base64":
+				body, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+			case "quoted-printable":
+				body, err = ioutil.ReadAll(quotedprintable.NewReader(bytes.NewReader(bytes.TrimRight(body, "\r\n"))))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts = append(parts, mailPart{contentType: mediaType, header: header, body: string(body)})
+			return
+		}
+		r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err != nil {
+				break
+			}
+			b, err := ioutil.ReadAll(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			walk(p.Header.Get("Content-Type"), p.Header, b)
+		}
+	}
+
+	body, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk(msg.Header.Get("Content-Type"), msg.Header, body)
+	return msg.Header, parts
+}
+
+func firstHeader(header map[string][]string, key string) string {
+	if values := header[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func findMailPart(parts []mailPart, contentType string) *mailPart {
+	for i := range parts {
+		if parts[i].contentType == contentType {
+			return &parts[i]
+		}
+	}
+	return nil
+}
+
+func TestMailer(t *testing.T) {
+	sink := startSMTPSink(t)
+	mailer, err := myutils.NewMailer(newSinkMailerConfig(sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Case01: send templated mail with text and html alternatives.")
+	ml := &myutils.Mail{
+		To:      []string{"Foo <foo@example.com>"},
+		Cc:      []string{"bar@example.com"},
+		Bcc:     []string{"baz@example.com"},
+		Subject: "Report of {{.Name}}",
+		Text:    "Hello {{.Name}}, total: {{.Total}}",
+		HTML:    "<p>Hello {{.Name}}, total: <b>{{.Total}}</b></p>",
+		Data:    map[string]interface{}{"Name": "<foo>", "Total": 3},
+	}
+	if err := mailer.Send(ml); err != nil {
+		t.Fatal(err)
+	}
+	mails := sink.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("want 1 mail, got %d", len(mails))
+	}
+	if mails[0].User != "tester@example.com" || mails[0].From != "tester@example.com" {
+		t.Fatalf("unexpected auth user or sender: %s, %s", mails[0].User, mails[0].From)
+	}
+	if rcpts := strings.Join(mails[0].To, ","); rcpts != "foo@example.com,bar@example.com,baz@example.com" {
+		t.Fatalf("unexpected receivers: %s", rcpts)
+	}
+
+	header, parts := parseSinkMail(t, mails[0])
+	if subject := header.Get("Subject"); subject != "Report of <foo>" {
+		t.Fatalf("unexpected subject: %s", subject)
+	}
+	if len(header.Get("Bcc")) > 0 {
+		t.Fatal("bcc should not be in headers")
+	}
+	if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, "multipart/alternative") {
+		t.Fatalf("unexpected content type: %s", ct)
+	}
+	if p := findMailPart(parts, "text/plain"); p == nil || p.body != "Hello <foo>, total: 3" {
+		t.Fatalf("unexpected text part: %+v", p)
+	}
+	if p := findMailPart(parts, "text/html"); p == nil || p.body != "<p>Hello &lt;foo&gt;, total: <b>3</b></p>" {
+		t.Fatalf("unexpected html part: %+v", p)
+	}
+
+	t.Log("Case02: send mail with inline image and attachment.")
+	sink.Reset()
+	dir := t.TempDir()
+	image := filepath.Join(dir, "logo.png")
+	attachment := filepath.Join(dir, "report.csv")
+	if err := ioutil.WriteFile(image, []byte("\x89PNG fake image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(attachment, []byte("id,name\n1,foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ml = &myutils.Mail{
+		To:           []string{"foo@example.com"},
+		Subject:      "Inline image",
+		HTML:         `<img src="cid:logo.png">`,
+		InlineImages: []string{image},
+		Attachments:  []string{attachment},
+	}
+	if err := mailer.Send(ml); err != nil {
+		t.Fatal(err)
+	}
+	_, parts = parseSinkMail(t, sink.Mails()[0])
+	p := findMailPart(parts, "image/png")
+	if p == nil || p.body != "\x89PNG fake image" || firstHeader(p.header, "Content-Id") != "<logo.png>" {
+		t.Fatalf("unexpected inline image part: %+v", p)
+	}
+	p = findMailPart(parts, "text/csv")
+	if p == nil || p.body != "id,name\n1,foo\n" || !strings.HasPrefix(firstHeader(p.header, "Content-Disposition"), "attachment") {
+		t.Fatalf("unexpected attachment part: %+v", p)
+	}
+
+	t.Log("Case03: invalid mails are rejected before sending.")
+	sink.Reset()
+	for _, ml := range []*myutils.Mail{
+		{Subject: "no receiver", Text: "body"},
+		{To: []string{"invalid"}, Text: "body"},
+		{To: []string{"foo@example.com"}, Subject: "no body"},
+		{To: []string{"foo@example.com"}, Text: "{{.Name"},
+		{To: []string{"foo@example.com"}, Text: "body", Attachments: []string{filepath.Join(dir, "not_exist")}},
+	} {
+		if err := mailer.Send(ml); err == nil {
+			t.Fatalf("want error for mail: %+v", ml)
+		}
+	}
+	if len(sink.Mails()) != 0 {
+		t.Fatal("invalid mails should not be sent")
+	}
+}
+
+func TestMailerTemplate(t *testing.T) {
+	sink := startSMTPSink(t)
+	dir := t.TempDir()
+	templates := map[string]string{
+		"welcome.subject.tmpl": "Welcome {{.}}\n",
+		"welcome.txt.tmpl":     "Hi {{.}}, welcome.\n",
+	}
+	for name, text := range templates {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Log("Case01: send mail by named templates in template dir.")
+	cfg := newSinkMailerConfig(sink)
+	cfg.TemplateDir = dir
+	mailer, err := myutils.NewMailer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(&myutils.Mail{To: []string{"foo@example.com"}, Template: "welcome", Data: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	header, parts := parseSinkMail(t, sink.Mails()[0])
+	if subject := header.Get("Subject"); subject != "Welcome foo" {
+		t.Fatalf("unexpected subject: %s", subject)
+	}
+	if len(parts) != 1 || parts[0].contentType != "text/plain" || parts[0].body != "Hi foo, welcome." {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+
+	t.Log("Case02: template not found.")
+	if err := mailer.Send(&myutils.Mail{To: []string{"foo@example.com"}, Template: "goodbye"}); err == nil {
+		t.Fatal("want template not found error")
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	t.Log("Case01: invalid configs.")
+	for _, cfg := range []*myutils.MailerConfig{
+		{From: "foo@example.com"},
+		{Host: "localhost", TLS: "ssl", From: "foo@example.com"},
+		{Host: "localhost"},
+	} {
+		if _, err := myutils.NewMailer(cfg); err == nil {
+			t.Fatalf("want error for config: %+v", cfg)
+		}
+	}
+
+	t.Log("Case02: STARTTLS is required but not supported by server.")
+	sink := startSMTPSink(t)
+	cfg := newSinkMailerConfig(sink)
+	cfg.TLS = myutils.MailTLSStartTLS
+	mailer, err := myutils.NewMailer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mailer.Send(&myutils.Mail{To: []string{"foo@example.com"}, Text: "body"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("want STARTTLS error, got: %v", err)
+	}
+}
+
+// setMailerConfigFile writes mailer config of sink, and sets it as MailerConfigFile of SendMail.
+func setMailerConfigFile(t *testing.T, sink *myutils.SMTPSink) {
+	path := filepath.Join(t.TempDir(), "mailer.json")
+	content := fmt.Sprintf(`{"host": "%s", "port": %d, "tls": "none", "user": "tester@example.com", "from": "ZJ Test <tester@example.com>"}`,
+		sink.Host(), sink.Port())
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	orig := myutils.MailerConfigFile
+	myutils.MailerConfigFile = path
+	t.Cleanup(func() {
+		myutils.MailerConfigFile = orig
+	})
+}
+
 func TestSendMail(t *testing.T) {
-	t.Skip("skip TestSendMail.")
+	sink := startSMTPSink(t)
+	setMailerConfigFile(t, sink)
+
 	t.Log("Case01: test send an email.")
 	entry := &myutils.MailEntry{
 		ServerPwd: "*******",
 		MailTo:    []string{"zhengjin@4paradigm.com"},
 		Subject:   "Go Mail Test",
-		Body:      "This is a go mail test.",
+		Body:      "This is a go mail test, {{.Name}} is not a template.",
+	}
+	if err := myutils.SendMail(entry); err != nil {
+		t.Fatal(err)
+	}
+	header, parts := parseSinkMail(t, sink.Mails()[0])
+	if subject := header.Get("Subject"); subject != entry.Subject {
+		t.Fatalf("unexpected subject: %s", subject)
 	}
+	if len(parts) != 1 || parts[0].contentType != "text/html" || parts[0].body != entry.Body {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+
+	t.Log("Case02: test send an email with raw html body, which is not valid as template.")
+	entry.Body = `<!--[if mso]><table><tr><td><![endif]--><a href="https://example.com/?a=1&b=2">link</a><!--[if mso]></td></tr></table><![endif]--><a href="`
 	if err := myutils.SendMail(entry); err != nil {
 		t.Fatal(err)
 	}
+	_, parts = parseSinkMail(t, sink.Mails()[1])
+	if len(parts) != 1 || parts[0].contentType != "text/html" || parts[0].body != entry.Body {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
 }
 
 func TestSendMailWithAttachFiles(t *testing.T) {
-	t.Skip("skip TestSendMailWithAttachFiles.")
+	sink := startSMTPSink(t)
+	setMailerConfigFile(t, sink)
+
 	t.Log("Case01: test send an email with attached files.")
-	baseDir := filepath.Join(os.Getenv("HOME"), "Downloads/tmp_files")
+	baseDir := t.TempDir()
 	files := []string{
 		filepath.Join(baseDir, "test.out"),
 		filepath.Join(baseDir, "test.json"),
 	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(f, []byte(filepath.Base(f)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	entry := &myutils.MailEntry{
 		ServerPwd:   "*******",
@@ -39,20 +344,36 @@ func TestSendMailWithAttachFiles(t *testing.T) {
 		AttachFiles: files,
 		IsArchive:   false,
 	}
-
 	if err := myutils.SendMail(entry); err != nil {
 		t.Fatal(err)
 	}
+	_, parts := parseSinkMail(t, sink.Mails()[0])
+	if len(parts) != 3 {
+		t.Fatalf("want 3 parts, got %d", len(parts))
+	}
+	for i, f := range files {
+		if body := parts[i+1].body; body != filepath.Base(f) {
+			t.Fatalf("unexpected attachment: %s", body)
+		}
+	}
 }
 
 func TestSendMailWithAttachArchive(t *testing.T) {
-	t.Skip("skip TestSendMailWithAttachArchive.")
+	sink := startSMTPSink(t)
+	setMailerConfigFile(t, sink)
+
 	t.Log("Case01: test send an email with attached archive file.")
-	baseDir := filepath.Join(os.Getenv("HOME"), "Downloads/tmp_files")
+	baseDir := t.TempDir()
 	files := []string{
 		filepath.Join(baseDir, "test_log.txt"),
 		filepath.Join(baseDir, "logs"),
 	}
+	if err := ioutil.WriteFile(files[0], []byte("test log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(files[1], 0755); err != nil {
+		t.Fatal(err)
+	}
 
 	entry := &myutils.MailEntry{
 		ServerPwd:   "*******",
@@ -62,8 +383,11 @@ func TestSendMailWithAttachArchive(t *testing.T) {
 		AttachFiles: files,
 		IsArchive:   true,
 	}
-
 	if err := myutils.SendMail(entry); err != nil {
 		t.Fatal(err)
 	}
+	_, parts := parseSinkMail(t, sink.Mails()[0])
+	if len(parts) != 2 || !strings.Contains(firstHeader(parts[1].header, "Content-Disposition"), ".tar.gz") {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
 }
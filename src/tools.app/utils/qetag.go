@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"hash"
+	"io"
+)
+
+// QETagBlockSize block size of qiniu etag.
+const QETagBlockSize = 4 * 1024 * 1024
+
+// qetagHash qiniu etag: content is split into 4MB blocks, and etag is url base64 of
+// 0x16 + sha1(block) for only one block, or 0x96 + sha1(sha1(block1) + sha1(block2) + ...).
+type qetagHash struct {
+	block    hash.Hash
+	blockLen int
+	// sha1 of full blocks
+	sums []byte
+}
+
+// NewQETagHash returns hash of qiniu etag, and sum is raw bytes (url base64 encoded as etag).
+func NewQETagHash() hash.Hash {
+	return &qetagHash{block: sha1.New()}
+}
+
+func (h *qetagHash) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		size := QETagBlockSize - h.blockLen
+		if size > len(p) {
+			size = len(p)
+		}
+		h.block.Write(p[:size])
+		h.blockLen += size
+		p = p[size:]
+		if h.blockLen == QETagBlockSize {
+			h.sums = h.block.Sum(h.sums)
+			h.block.Reset()
+			h.blockLen = 0
+		}
+	}
+	return n, nil
+}
+
+func (h *qetagHash) Sum(b []byte) []byte {
+	sums := h.sums
+	if h.blockLen > 0 || len(sums) == 0 {
+		sums = h.block.Sum(append([]byte(nil), h.sums...))
+	}
+	if len(sums) == sha1.Size {
+		return append(append(b, 0x16), sums...)
+	}
+	sum := sha1.Sum(sums)
+	return append(append(b, 0x96), sum[:]...)
+}
+
+func (h *qetagHash) Reset() {
+	h.block.Reset()
+	h.blockLen = 0
+	h.sums = nil
+}
+
+func (h *qetagHash) Size() int {
+	return 1 + sha1.Size
+}
+
+func (h *qetagHash) BlockSize() int {
+	return h.block.BlockSize()
+}
+
+// GetQETag returns qiniu etag of reader content.
+func GetQETag(r io.Reader) (string, error) {
+	return HashReader(r, HashQETag)
+}
+
+// GetFileQETag returns qiniu etag of file.
+func GetFileQETag(path string) (string, error) {
+	return HashFile(path, HashQETag)
+}
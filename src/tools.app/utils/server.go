@@ -1,51 +1,45 @@
 package utils
 
 import (
-	"encoding/json"
 	"net/http"
+
+	"src/tools.app/utils/httpresp"
 )
 
 // ********* HTTP Response
 
-// JSONResponse json http response
-type JSONResponse struct {
-	// Reserved field to add some meta information to the API response.
-	Meta interface{} `json:"meta"`
-	Data interface{} `json:"data"`
-}
+// JSONResponse json http response.
+//
+// Deprecated: use httpresp.Response.
+type JSONResponse = httpresp.Response
 
-// JSONErrResponse json http error response
-type JSONErrResponse struct {
-	Error *APIError `json:"error"`
-}
+// JSONErrResponse json http error response.
+//
+// Deprecated: use httpresp.ErrorResponse.
+type JSONErrResponse = httpresp.ErrorResponse
 
-// APIError json http error response
-type APIError struct {
-	Status int    `json:"status"`
-	Title  string `json:"title"`
-}
+// APIError json http error.
+//
+// Deprecated: use httpresp.Error.
+type APIError = httpresp.Error
 
 // WriteOKJSONResp writes the response as a standard JSON response with StatusOK.
+//
+// Deprecated: use httpresp.WriteOK.
 func WriteOKJSONResp(w http.ResponseWriter, m interface{}) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(&JSONResponse{Data: m}); err != nil {
-		WriteErrJSONResp(w, http.StatusInternalServerError, "Internal Server Error")
-	}
+	httpresp.WriteOK(w, nil, m)
 }
 
 // WriteOKHTMLResp returns html with StatusOK.
+//
+// Deprecated: use httpresp.WriteHTML.
 func WriteOKHTMLResp(w http.ResponseWriter, data []byte) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write(data); err != nil {
-		WriteErrJSONResp(w, http.StatusInternalServerError, "Internal Server Error")
-	}
+	httpresp.WriteHTML(w, http.StatusOK, data)
 }
 
 // WriteErrJSONResp writes the error response as a Standard API JSON response with a response code.
+//
+// Deprecated: use httpresp.WriteError.
 func WriteErrJSONResp(w http.ResponseWriter, errCode int, errMsg string) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(errCode)
-	json.NewEncoder(w).Encode(&JSONErrResponse{Error: &APIError{Status: errCode, Title: errMsg}})
+	httpresp.WriteError(w, nil, httpresp.NewError(errCode, errMsg))
 }
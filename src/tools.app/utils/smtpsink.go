@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SinkMail a mail received by smtp sink.
+type SinkMail struct {
+	// User of smtp auth, and empty if no auth.
+	User string
+	From string
+	To   []string
+	// Data raw message with headers, and "\r\n" line endings.
+	Data []byte
+}
+
+// SMTPSink an in-process fake smtp server which keeps received mails in memory, for tests.
+// It supports AUTH PLAIN and LOGIN (any password is accepted), and does not support STARTTLS.
+type SMTPSink struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	mails    []*SinkMail
+}
+
+// NewSMTPSink starts a smtp sink listens on 127.0.0.1 with a random port.
+func NewSMTPSink() (*SMTPSink, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &SMTPSink{listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Host returns host of sink.
+func (s *SMTPSink) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns port of sink.
+func (s *SMTPSink) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Mails returns received mails.
+func (s *SMTPSink) Mails() []*SinkMail {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	mails := make([]*SinkMail, len(s.mails))
+	copy(mails, s.mails)
+	return mails
+}
+
+// Reset removes received mails.
+func (s *SMTPSink) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.mails = nil
+}
+
+// Close stops sink, and waits for sessions exit.
+func (s *SMTPSink) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *SMTPSink) serve() {
+	defer s.wg.Done()
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer conn.Close()
+			s.session(textproto.NewConn(conn))
+		}()
+	}
+}
+
+// session serves smtp commands of a connection until QUIT or error.
+func (s *SMTPSink) session(conn *textproto.Conn) {
+	var (
+		user string
+		mail *SinkMail
+	)
+	reply := func(code int, msg string) error {
+		return conn.PrintfLine("%d %s", code, msg)
+	}
+
+	if err := reply(220, "localhost ESMTP sink"); err != nil {
+		return
+	}
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if idx := strings.IndexByte(line, ' '); idx > 0 {
+			verb, arg = line[:idx], strings.TrimSpace(line[idx+1:])
+		}
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			err = conn.PrintfLine("250-localhost\r\n250-8BITMIME\r\n250 AUTH PLAIN LOGIN")
+		case "HELO":
+			err = reply(250, "localhost")
+		case "AUTH":
+			var authErr error
+			if user, authErr = s.auth(conn, arg); authErr == nil {
+				err = reply(235, "authentication succeeded")
+			} else if e, ok := authErr.(*textproto.Error); ok {
+				err = reply(e.Code, e.Msg)
+			} else {
+				err = authErr
+			}
+		case "MAIL":
+			if !strings.HasPrefix(strings.ToUpper(arg), "FROM:") {
+				err = reply(501, "syntax: MAIL FROM:<address>")
+				break
+			}
+			mail = &SinkMail{User: user, From: trimMailAddress(arg[len("FROM:"):])}
+			err = reply(250, "ok")
+		case "RCPT":
+			if mail == nil {
+				err = reply(503, "need MAIL command")
+				break
+			}
+			if !strings.HasPrefix(strings.ToUpper(arg), "TO:") {
+				err = reply(501, "syntax: RCPT TO:<address>")
+				break
+			}
+			mail.To = append(mail.To, trimMailAddress(arg[len("TO:"):]))
+			err = reply(250, "ok")
+		case "DATA":
+			if mail == nil || len(mail.To) == 0 {
+				err = reply(503, "need RCPT command")
+				break
+			}
+			if err = reply(354, "end data with <CR><LF>.<CR><LF>"); err != nil {
+				return
+			}
+			var lines []string
+			if lines, err = conn.ReadDotLines(); err != nil {
+				return
+			}
+			mail.Data = []byte(strings.Join(lines, "\r\n") + "\r\n")
+			s.mutex.Lock()
+			s.mails = append(s.mails, mail)
+			s.mutex.Unlock()
+			mail = nil
+			err = reply(250, "ok: queued")
+		case "RSET":
+			mail = nil
+			err = reply(250, "ok")
+		case "NOOP":
+			err = reply(250, "ok")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			err = reply(502, "command not implemented")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// auth reads credentials of AUTH PLAIN or LOGIN, and returns user.
+func (s *SMTPSink) auth(conn *textproto.Conn, arg string) (string, error) {
+	mechanism, initial := arg, ""
+	if idx := strings.IndexByte(arg, ' '); idx > 0 {
+		mechanism, initial = arg[:idx], arg[idx+1:]
+	}
+	readResp := func(challenge string) (string, error) {
+		if err := conn.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge))); err != nil {
+			return "", err
+		}
+		line, err := conn.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		b, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return "", &textproto.Error{Code: 501, Msg: "invalid base64 response"}
+		}
+		return string(b), nil
+	}
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		var resp string
+		if len(initial) > 0 {
+			b, err := base64.StdEncoding.DecodeString(initial)
+			if err != nil {
+				return "", &textproto.Error{Code: 501, Msg: "invalid base64 response"}
+			}
+			resp = string(b)
+		} else {
+			var err error
+			if resp, err = readResp(""); err != nil {
+				return "", err
+			}
+		}
+		// authzid \0 authcid \0 password
+		parts := strings.Split(resp, "\x00")
+		if len(parts) != 3 || len(parts[1]) == 0 {
+			return "", &textproto.Error{Code: 535, Msg: "invalid credentials"}
+		}
+		return parts[1], nil
+	case "LOGIN":
+		user, err := readResp("Username:")
+		if err != nil {
+			return "", err
+		}
+		if _, err := readResp("Password:"); err != nil {
+			return "", err
+		}
+		return user, nil
+	default:
+		return "", &textproto.Error{Code: 504, Msg: "unrecognized auth mechanism: " + strconv.Quote(mechanism)}
+	}
+}
+
+// trimMailAddress returns address of "<address> params".
+func trimMailAddress(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if idx := strings.IndexByte(arg, '>'); strings.HasPrefix(arg, "<") && idx > 0 {
+		return arg[1:idx]
+	}
+	return arg
+}